@@ -44,10 +44,12 @@ type AeLoop struct {
 	fileEventFd     int
 	timeEventNextID int
 	stop            bool
+	beforeSleep     BeforeSleepProc // 每次等待事件之前调用
 }
 
 type FileProc func(loop *AeLoop, fd int, extra any)
 type TimeProc func(loop *AeLoop, fd int, extra any)
+type BeforeSleepProc func(loop *AeLoop)
 
 // 将fileEvent的事件映射为epoll事件  unix.EPOLLIN 可读事件  unix.EPOLLOUT 可写事件
 var fe2ep [3]uint32 = [3]uint32{0, unix.EPOLLIN, unix.EPOLLOUT}
@@ -135,6 +137,7 @@ func (loop *AeLoop) AddTimeEvent(mask TeType, interval int64, proc TimeProc, ext
 	var te AeTimeEvent
 	te.id = id
 	te.mask = mask
+	te.interval = interval
 	te.when = GetMsTime() + interval
 	te.proc = proc
 	te.extra = extra
@@ -255,9 +258,16 @@ func AeLoopCreate() (*AeLoop, error) {
 	}, nil
 }
 
+func (loop *AeLoop) SetBeforeSleepProc(proc BeforeSleepProc) {
+	loop.beforeSleep = proc
+}
+
 // 事件主函数
 func (loop *AeLoop) AEMain() {
 	for !loop.stop {
+		if loop.beforeSleep != nil {
+			loop.beforeSleep(loop)
+		}
 		// 获取当前已经ready的事件
 		tes, fes := loop.AeWait()
 		// 处理事件
@@ -0,0 +1,588 @@
+package main
+
+import (
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// bitmap最多可以寻址512MB的字符串
+const GodisMaxBitOffset int64 = GodisMaxStringSize*8 - 1
+
+const (
+	BitOpAnd = iota
+	BitOpOr
+	BitOpXor
+	BitOpNot
+)
+
+// BITFIELD溢出处理方式
+const (
+	BfOverflowWrap = iota
+	BfOverflowSat
+	BfOverflowFail
+)
+
+const ReplyBitOffsetErr = "-ERR bit offset is not an integer or out of range\r\n"
+
+// 获取字符串对象的字节，[]byte编码的对象不会发生拷贝，调用方不能修改返回值
+func stringBytes(o *Gobj) []byte {
+	if buf, ok := o.Val_.([]byte); ok {
+		return buf
+	}
+	return []byte(o.StrVal())
+}
+
+// 查找用于写入bit的字符串对象，保证对象为[]byte编码、只被数据库持有并且长度不小于size
+func lookupStringForWrite(c *GodisClient, key *Gobj, size int64) *Gobj {
+	o := findKeyWrite(c.db, key)
+	if o == nil {
+		o = CreateObject(GSTR, make([]byte, size))
+		setKey(c.db, key, o)
+		o.DecrRefCount()
+		return o
+	}
+	if checkType(c, o, GSTR) {
+		return nil
+	}
+
+	buf, isBytes := o.Val_.([]byte)
+	if !isBytes || o.refCount != 1 {
+		buf = []byte(o.StrVal())
+		if o.refCount != 1 {
+			o = CreateObject(GSTR, buf)
+			setKey(c.db, key, o)
+			o.DecrRefCount()
+		}
+	}
+	if int64(len(buf)) < size {
+		buf = append(buf, make([]byte, size-int64(len(buf)))...)
+	}
+	o.Val_ = buf
+	return o
+}
+
+// 解析bit偏移量，hash为true时支持#N的形式，表示第N个bits宽度的位置
+func getBitOffsetFromObjectOrReply(c *GodisClient, o *Gobj, hash bool, bits int64) (int64, bool) {
+	str := o.StrVal()
+	mul := int64(1)
+	if hash && strings.HasPrefix(str, "#") {
+		mul = bits
+		str = str[1:]
+	}
+
+	offset, err := strconv.ParseInt(str, 10, 64)
+	if err != nil || offset < 0 || offset > GodisMaxBitOffset/mul {
+		c.AddReplyStr(ReplyBitOffsetErr)
+		return 0, false
+	}
+	offset *= mul
+	if offset+bits-1 > GodisMaxBitOffset {
+		c.AddReplyStr(ReplyBitOffsetErr)
+		return 0, false
+	}
+	return offset, true
+}
+
+func getBit(buf []byte, offset int64) int {
+	idx := offset >> 3
+	if idx >= int64(len(buf)) {
+		return 0
+	}
+	return int(buf[idx]>>(7-uint(offset&7))) & 1
+}
+
+func setBit(buf []byte, offset int64, on int) {
+	idx := offset >> 3
+	mask := byte(1 << (7 - uint(offset&7)))
+	if on != 0 {
+		buf[idx] |= mask
+	} else {
+		buf[idx] &^= mask
+	}
+}
+
+func setbitCommand(c *GodisClient) {
+	offset, ok := getBitOffsetFromObjectOrReply(c, c.args[2], false, 1)
+	if !ok {
+		return
+	}
+	on, ok := c.args[3].TryIntVal()
+	if !ok || (on != 0 && on != 1) {
+		c.AddReplyError("bit is not an integer or out of range")
+		return
+	}
+
+	o := lookupStringForWrite(c, c.args[1], offset>>3+1)
+	if o == nil {
+		return
+	}
+	buf := o.Val_.([]byte)
+	old := getBit(buf, offset)
+	setBit(buf, offset, int(on))
+	c.AddReplyInt(int64(old))
+}
+
+func getbitCommand(c *GodisClient) {
+	offset, ok := getBitOffsetFromObjectOrReply(c, c.args[2], false, 1)
+	if !ok {
+		return
+	}
+
+	o := stringLookupRead(c, c.args[1], ":0\r\n")
+	if o == nil {
+		return
+	}
+	c.AddReplyInt(int64(getBit(stringBytes(o), offset)))
+}
+
+// 与GETRANGE一样处理负数下标，范围为空时返回false
+func normalizeBitRange(start, end, length int64) (int64, int64, bool) {
+	if start < 0 && end < 0 && start > end {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	return start, end, start <= end
+}
+
+// 解析BITCOUNT和BITPOS的[start end [BYTE|BIT]]参数，返回bit为单位的范围
+func parseBitRange(c *GodisClient, args []*Gobj, buf []byte, endGiven bool) (int64, int64, bool, bool) {
+	start, end := int64(0), int64(-1)
+	isBit := false
+	var ok bool
+	if len(args) > 0 {
+		if start, ok = getIntFromObjectOrReply(c, args[0]); !ok {
+			return 0, 0, false, false
+		}
+	}
+	if endGiven {
+		if end, ok = getIntFromObjectOrReply(c, args[1]); !ok {
+			return 0, 0, false, false
+		}
+	}
+	if len(args) > 2 {
+		unit := strings.ToLower(args[2].StrVal())
+		if unit == "bit" {
+			isBit = true
+		} else if unit != "byte" {
+			c.AddReplyStr(ReplySyntaxErr)
+			return 0, 0, false, false
+		}
+	}
+
+	length := int64(len(buf))
+	if isBit {
+		length *= 8
+	}
+	start, end, ok = normalizeBitRange(start, end, length)
+	if !isBit {
+		start *= 8
+		end = end*8 + 7
+	}
+	return start, end, ok, true
+}
+
+func bitcountCommand(c *GodisClient) {
+	if len(c.args) == 3 || len(c.args) > 5 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	o := stringLookupRead(c, c.args[1], ":0\r\n")
+	if o == nil {
+		return
+	}
+	buf := stringBytes(o)
+	start, end, nonEmpty, ok := parseBitRange(c, c.args[2:], buf, len(c.args) > 3)
+	if !ok {
+		return
+	}
+	if !nonEmpty {
+		c.AddReplyInt(0)
+		return
+	}
+
+	var count int64
+	for i := start; i <= end; {
+		// 完整的字节直接统计
+		if i&7 == 0 && i+7 <= end {
+			count += int64(bits.OnesCount8(buf[i>>3]))
+			i += 8
+		} else {
+			count += int64(getBit(buf, i))
+			i++
+		}
+	}
+	c.AddReplyInt(count)
+}
+
+func bitposCommand(c *GodisClient) {
+	bit, ok := c.args[2].TryIntVal()
+	if !ok || (bit != 0 && bit != 1) {
+		c.AddReplyError("The bit argument must be 1 or 0.")
+		return
+	}
+	if len(c.args) > 6 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	o := findKeyRead(c.db, c.args[1])
+	if o == nil {
+		// 不存在的key相当于全0的字符串
+		if bit == 1 {
+			c.AddReplyInt(-1)
+		} else {
+			c.AddReplyInt(0)
+		}
+		return
+	}
+	if checkType(c, o, GSTR) {
+		return
+	}
+
+	buf := stringBytes(o)
+	endGiven := len(c.args) > 4
+	start, end, nonEmpty, ok := parseBitRange(c, c.args[3:], buf, endGiven)
+	if !ok {
+		return
+	}
+	if !nonEmpty {
+		c.AddReplyInt(-1)
+		return
+	}
+
+	skip := byte(0)
+	if bit == 0 {
+		skip = 0xff
+	}
+	for i := start; i <= end; {
+		if i&7 == 0 && i+7 <= end && buf[i>>3] == skip {
+			i += 8
+			continue
+		}
+		if int64(getBit(buf, i)) == bit {
+			c.AddReplyInt(i)
+			return
+		}
+		i++
+	}
+
+	// 查找0并且没有指定end时，认为字符串右侧填充了0
+	if bit == 0 && !endGiven {
+		c.AddReplyInt(end + 1)
+		return
+	}
+	c.AddReplyInt(-1)
+}
+
+func bitopCommand(c *GodisClient) {
+	var op int
+	switch strings.ToLower(c.args[1].StrVal()) {
+	case "and":
+		op = BitOpAnd
+	case "or":
+		op = BitOpOr
+	case "xor":
+		op = BitOpXor
+	case "not":
+		op = BitOpNot
+	default:
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	keys := c.args[3:]
+	if op == BitOpNot && len(keys) != 1 {
+		c.AddReplyError("BITOP NOT must be called with a single source key.")
+		return
+	}
+
+	srcs := make([][]byte, len(keys))
+	maxLen := 0
+	for i, key := range keys {
+		o := findKeyRead(c.db, key)
+		if o == nil {
+			continue
+		}
+		if checkType(c, o, GSTR) {
+			return
+		}
+		srcs[i] = stringBytes(o)
+		if len(srcs[i]) > maxLen {
+			maxLen = len(srcs[i])
+		}
+	}
+
+	// 长度不足的字符串视为用0填充
+	res := make([]byte, maxLen)
+	for j := 0; j < maxLen; j++ {
+		var b byte
+		for i, src := range srcs {
+			var v byte
+			if j < len(src) {
+				v = src[j]
+			}
+			if i == 0 {
+				b = v
+				continue
+			}
+			switch op {
+			case BitOpAnd:
+				b &= v
+			case BitOpOr:
+				b |= v
+			case BitOpXor:
+				b ^= v
+			}
+		}
+		if op == BitOpNot {
+			b = ^b
+		}
+		res[j] = b
+	}
+
+	dst := c.args[2]
+	deleteKey(c.db, dst)
+	if maxLen > 0 {
+		o := CreateObject(GSTR, res)
+		setKey(c.db, dst, o)
+		o.DecrRefCount()
+	}
+	c.AddReplyInt(int64(maxLen))
+}
+
+type bitfieldOp struct {
+	opType   string // get、set或者incrby
+	offset   int64
+	bits     int64
+	signed   bool
+	value    int64
+	overflow int
+}
+
+func getUnsignedBitfield(buf []byte, offset, nbits int64) uint64 {
+	var val uint64
+	for i := int64(0); i < nbits; i++ {
+		val = val<<1 | uint64(getBit(buf, offset+i))
+	}
+	return val
+}
+
+func setUnsignedBitfield(buf []byte, offset, nbits int64, val uint64) {
+	for i := int64(0); i < nbits; i++ {
+		setBit(buf, offset+i, int(val>>(nbits-1-i))&1)
+	}
+}
+
+func getSignedBitfield(buf []byte, offset, nbits int64) int64 {
+	val := getUnsignedBitfield(buf, offset, nbits)
+	// 符号位扩展
+	if nbits < 64 && val&(1<<(nbits-1)) != 0 {
+		val |= math.MaxUint64 << nbits
+	}
+	return int64(val)
+}
+
+// 检查value+incr是否溢出，溢出时返回true以及按照overflow处理后的值
+func checkUnsignedBitfieldOverflow(value uint64, incr int64, nbits int64, overflow int) (uint64, bool) {
+	max := uint64(math.MaxUint64)
+	if nbits < 64 {
+		max = 1<<nbits - 1
+	}
+	maxIncr := max - value
+
+	if value > max || (incr > 0 && uint64(incr) > maxIncr) {
+		if overflow == BfOverflowWrap {
+			return (value + uint64(incr)) & max, true
+		}
+		return max, true
+	} else if incr < 0 && uint64(-incr) > value {
+		if overflow == BfOverflowWrap {
+			return (value + uint64(incr)) & max, true
+		}
+		return 0, true
+	}
+	return value + uint64(incr), false
+}
+
+func checkSignedBitfieldOverflow(value, incr, nbits int64, overflow int) (int64, bool) {
+	max := int64(math.MaxInt64)
+	if nbits < 64 {
+		max = 1<<(nbits-1) - 1
+	}
+	min := -max - 1
+
+	wrap := func() int64 {
+		res := uint64(value) + uint64(incr)
+		if nbits < 64 {
+			mask := uint64(math.MaxUint64) << nbits
+			if res&(1<<(nbits-1)) != 0 {
+				res |= mask
+			} else {
+				res &^= mask
+			}
+		}
+		return int64(res)
+	}
+
+	if value > max || (incr > 0 && value > max-incr) {
+		if overflow == BfOverflowWrap {
+			return wrap(), true
+		}
+		return max, true
+	} else if value < min || (incr < 0 && value < min-incr) {
+		if overflow == BfOverflowWrap {
+			return wrap(), true
+		}
+		return min, true
+	}
+	return value + incr, false
+}
+
+// 解析i8、u16这样的类型
+func parseBitfieldType(s string) (int64, bool, bool) {
+	if len(s) < 2 {
+		return 0, false, false
+	}
+	signed := s[0] == 'i' || s[0] == 'I'
+	if !signed && s[0] != 'u' && s[0] != 'U' {
+		return 0, false, false
+	}
+	nbits, err := strconv.ParseInt(s[1:], 10, 64)
+	if err != nil || nbits < 1 || (signed && nbits > 64) || (!signed && nbits > 63) {
+		return 0, false, false
+	}
+	return nbits, signed, true
+}
+
+func bitfieldCommand(c *GodisClient) {
+	var ops []bitfieldOp
+	overflow := BfOverflowWrap
+	readonly := true
+	for i := 2; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		remaining := len(c.args) - i - 1
+		if opt == "overflow" && remaining >= 1 {
+			switch strings.ToLower(c.args[i+1].StrVal()) {
+			case "wrap":
+				overflow = BfOverflowWrap
+			case "sat":
+				overflow = BfOverflowSat
+			case "fail":
+				overflow = BfOverflowFail
+			default:
+				c.AddReplyError("Invalid OVERFLOW type specified")
+				return
+			}
+			i++
+			continue
+		}
+
+		if !((opt == "get" && remaining >= 2) || ((opt == "set" || opt == "incrby") && remaining >= 3)) {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+
+		op := bitfieldOp{opType: opt, overflow: overflow}
+		var ok bool
+		if op.bits, op.signed, ok = parseBitfieldType(c.args[i+1].StrVal()); !ok {
+			c.AddReplyError("Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+			return
+		}
+		if op.offset, ok = getBitOffsetFromObjectOrReply(c, c.args[i+2], true, op.bits); !ok {
+			return
+		}
+		if opt != "get" {
+			if op.value, ok = getIntFromObjectOrReply(c, c.args[i+3]); !ok {
+				return
+			}
+			readonly = false
+			i++
+		}
+		ops = append(ops, op)
+		i += 2
+	}
+
+	var buf []byte
+	if readonly {
+		o := findKeyRead(c.db, c.args[1])
+		if o != nil {
+			if checkType(c, o, GSTR) {
+				return
+			}
+			buf = stringBytes(o)
+		}
+	} else {
+		// 计算需要写入的最大字节数，保证字符串足够长
+		var maxByte int64
+		for _, op := range ops {
+			if op.opType != "get" && (op.offset+op.bits-1)>>3+1 > maxByte {
+				maxByte = (op.offset+op.bits-1)>>3 + 1
+			}
+		}
+		o := lookupStringForWrite(c, c.args[1], maxByte)
+		if o == nil {
+			return
+		}
+		buf = o.Val_.([]byte)
+	}
+
+	c.AddReplyArrayLen(len(ops))
+	for _, op := range ops {
+		if op.opType == "get" {
+			if op.signed {
+				c.AddReplyInt(getSignedBitfield(buf, op.offset, op.bits))
+			} else {
+				c.AddReplyInt(int64(getUnsignedBitfield(buf, op.offset, op.bits)))
+			}
+			continue
+		}
+
+		var oldVal, newVal int64
+		var overflowed bool
+		if op.signed {
+			oldVal = getSignedBitfield(buf, op.offset, op.bits)
+			if op.opType == "incrby" {
+				newVal, overflowed = checkSignedBitfieldOverflow(oldVal, op.value, op.bits, op.overflow)
+			} else {
+				newVal, overflowed = checkSignedBitfieldOverflow(op.value, 0, op.bits, op.overflow)
+			}
+		} else {
+			oldVal = int64(getUnsignedBitfield(buf, op.offset, op.bits))
+			var res uint64
+			if op.opType == "incrby" {
+				res, overflowed = checkUnsignedBitfieldOverflow(uint64(oldVal), op.value, op.bits, op.overflow)
+			} else {
+				res, overflowed = checkUnsignedBitfieldOverflow(uint64(op.value), 0, op.bits, op.overflow)
+			}
+			newVal = int64(res)
+		}
+
+		if overflowed && op.overflow == BfOverflowFail {
+			c.AddReplyStr(ReplyNull)
+			continue
+		}
+		setUnsignedBitfield(buf, op.offset, op.bits, uint64(newVal))
+		if op.opType == "incrby" {
+			c.AddReplyInt(newVal)
+		} else {
+			c.AddReplyInt(oldVal)
+		}
+	}
+}
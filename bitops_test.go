@@ -0,0 +1,86 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetGetBit(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":0\r\n", execCommand(c, "setbit", "b", "7", "1"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "setbit", "b", "7", "0"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "setbit", "b", "17", "1"))
+	assert.Equal(t, "$3\r\n\x00\x00\x40\r\n", execCommand(c, "get", "b"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "getbit", "b", "17"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "getbit", "b", "1000"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "getbit", "none", "0"))
+	assert.Equal(t, "-ERR bit is not an integer or out of range\r\n", execCommand(c, "setbit", "b", "0", "2"))
+	assert.Equal(t, ReplyBitOffsetErr, execCommand(c, "setbit", "b", "4294967296", "1"))
+
+	// 修改普通字符串的bit
+	execCommand(c, "set", "s", "a")
+	assert.Equal(t, ":0\r\n", execCommand(c, "setbit", "s", "6", "1"))
+	assert.Equal(t, "$1\r\nc\r\n", execCommand(c, "get", "s"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "append", "s", "d"))
+	assert.Equal(t, "$2\r\ncd\r\n", execCommand(c, "get", "s"))
+}
+
+func TestBitCountPos(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "set", "s", "foobar")
+	assert.Equal(t, ":26\r\n", execCommand(c, "bitcount", "s"))
+	assert.Equal(t, ":4\r\n", execCommand(c, "bitcount", "s", "0", "0"))
+	assert.Equal(t, ":6\r\n", execCommand(c, "bitcount", "s", "1", "1"))
+	assert.Equal(t, ":17\r\n", execCommand(c, "bitcount", "s", "5", "30", "bit"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "bitcount", "s", "3", "1"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "bitcount", "s", "1"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "bitcount", "s", "1", "2", "bits"))
+
+	execCommand(c, "set", "p", "\xff\xf0\x00")
+	assert.Equal(t, ":12\r\n", execCommand(c, "bitpos", "p", "0"))
+	assert.Equal(t, ":8\r\n", execCommand(c, "bitpos", "p", "1", "1"))
+	assert.Equal(t, ":-1\r\n", execCommand(c, "bitpos", "p", "1", "2"))
+	assert.Equal(t, ":16\r\n", execCommand(c, "bitpos", "p", "0", "2"))
+	assert.Equal(t, ":7\r\n", execCommand(c, "bitpos", "p", "1", "7", "15", "bit"))
+	execCommand(c, "set", "ones", "\xff")
+	assert.Equal(t, ":8\r\n", execCommand(c, "bitpos", "ones", "0"))
+	assert.Equal(t, ":-1\r\n", execCommand(c, "bitpos", "ones", "0", "0", "-1"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "bitpos", "none", "0"))
+	assert.Equal(t, ":-1\r\n", execCommand(c, "bitpos", "none", "1"))
+}
+
+func TestBitOp(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "set", "a", "abc")
+	execCommand(c, "set", "b", "\x0f")
+	assert.Equal(t, ":3\r\n", execCommand(c, "bitop", "and", "dst", "a", "b"))
+	assert.Equal(t, "$3\r\n\x01\x00\x00\r\n", execCommand(c, "get", "dst"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "bitop", "or", "dst", "a", "b", "none"))
+	assert.Equal(t, "$3\r\nobc\r\n", execCommand(c, "get", "dst"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "bitop", "not", "dst", "b"))
+	assert.Equal(t, "$1\r\n\xf0\r\n", execCommand(c, "get", "dst"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "bitop", "xor", "dst", "none"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "get", "dst"))
+	assert.Equal(t, "-ERR BITOP NOT must be called with a single source key.\r\n", execCommand(c, "bitop", "not", "dst", "a", "b"))
+}
+
+func TestBitField(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, "*2\r\n:0\r\n:0\r\n", execCommand(c, "bitfield", "none", "get", "u8", "0", "get", "i64", "#3"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "get", "none"))
+
+	assert.Equal(t, "*2\r\n:0\r\n:100\r\n", execCommand(c, "bitfield", "bf", "set", "i8", "#1", "100", "get", "i8", "8"))
+	assert.Equal(t, "*1\r\n:-106\r\n", execCommand(c, "bitfield", "bf", "incrby", "i8", "8", "50"))
+	assert.Equal(t, "*1\r\n:127\r\n", execCommand(c, "bitfield", "bf", "overflow", "sat", "incrby", "i8", "8", "1000"))
+	assert.Equal(t, "*1\r\n$-1\r\n", execCommand(c, "bitfield", "bf", "overflow", "fail", "incrby", "i8", "8", "1"))
+	assert.Equal(t, "*2\r\n:15\r\n:0\r\n", execCommand(c, "bitfield", "bf", "incrby", "u4", "0", "15", "incrby", "u4", "0", "1"))
+	assert.Equal(t, "*1\r\n:0\r\n", execCommand(c, "bitfield", "bf", "overflow", "sat", "incrby", "u4", "0", "-5"))
+	assert.Equal(t, "*1\r\n:1\r\n", execCommand(c, "bitfield", "bf", "incrby", "u2", "100", "1"))
+	assert.Equal(t, ":13\r\n", execCommand(c, "strlen", "bf"))
+
+	assert.Equal(t, "-ERR Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.\r\n",
+		execCommand(c, "bitfield", "bf", "get", "u64", "0"))
+	assert.Equal(t, "-ERR Invalid OVERFLOW type specified\r\n", execCommand(c, "bitfield", "bf", "overflow", "none"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "bitfield", "bf", "set", "u8", "0"))
+}
@@ -0,0 +1,180 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// blockingState 被阻塞的客户端等待的key和超时时间
+type blockingState struct {
+	btype   GType
+	keys    []*Gobj
+	timeout int64 // 超时的毫秒时间戳，0表示永久阻塞
+	timerID int
+	// XREAD阻塞时每个stream开始读取的ID，被唤醒后重新执行命令时'$'使用这里保存的ID
+	streamIDs []StreamID
+}
+
+type readyKey struct {
+	db  *GodisDB
+	key *Gobj
+}
+
+// 解析以秒为单位的超时时间，返回超时的毫秒时间戳，0表示永久阻塞
+func getTimeoutFromObjectOrReply(c *GodisClient, o *Gobj) (int64, bool) {
+	secs, err := strconv.ParseFloat(o.StrVal(), 64)
+	if err != nil || math.IsNaN(secs) || math.IsInf(secs, 0) || secs*1000 > math.MaxInt64/2 {
+		c.AddReplyError("timeout is not a float or out of range")
+		return 0, false
+	}
+	if secs < 0 {
+		c.AddReplyError("timeout is negative")
+		return 0, false
+	}
+	ms := int64(math.Ceil(secs * 1000))
+	if ms == 0 {
+		return 0, true
+	}
+	return GetMsTime() + ms, true
+}
+
+// 解析以毫秒为单位的超时时间（XREAD的BLOCK选项），返回值和getTimeoutFromObjectOrReply相同
+func getMsTimeoutFromObjectOrReply(c *GodisClient, o *Gobj) (int64, bool) {
+	ms, ok := o.TryIntVal()
+	if !ok || ms > math.MaxInt64/2 {
+		c.AddReplyError("timeout is not an integer or out of range")
+		return 0, false
+	}
+	if ms < 0 {
+		c.AddReplyError("timeout is negative")
+		return 0, false
+	}
+	if ms == 0 {
+		return 0, true
+	}
+	return GetMsTime() + ms, true
+}
+
+// blockForKeys 阻塞客户端直到keys中的某个key可以被处理或者超时
+// 客户端被唤醒后会重新执行当前命令，此时保留最初的超时时间
+func blockForKeys(c *GodisClient, btype GType, keys []*Gobj, timeout int64) {
+	if c.bstate == nil {
+		c.bstate = &blockingState{btype: btype, timeout: timeout, timerID: -1}
+		if timeout > 0 {
+			c.bstate.timerID = server.aeLoop.AddTimeEvent(AEOnce, timeout-GetMsTime(), blockTimeoutProc, c)
+		}
+	}
+	c.bstate.keys = keys
+	for _, key := range keys {
+		key.IncrRefCount()
+		name := key.StrVal()
+		c.db.blockingKeys[name] = append(c.db.blockingKeys[name], c)
+	}
+	c.blocked = true
+}
+
+// 将客户端从等待队列中移除，但不清除超时设置
+func removeFromBlockingKeys(c *GodisClient) {
+	for _, key := range c.bstate.keys {
+		name := key.StrVal()
+		clients := c.db.blockingKeys[name]
+		for i, bc := range clients {
+			if bc == c {
+				clients = append(clients[:i], clients[i+1:]...)
+				break
+			}
+		}
+		if len(clients) == 0 {
+			delete(c.db.blockingKeys, name)
+		} else {
+			c.db.blockingKeys[name] = clients
+		}
+		key.DecrRefCount()
+	}
+	c.bstate.keys = nil
+	c.blocked = false
+}
+
+// unblockClient 解除阻塞，缓冲区中剩余的命令在下一次事件循环之前处理
+func unblockClient(c *GodisClient) {
+	if c.blocked {
+		removeFromBlockingKeys(c)
+	}
+	if c.bstate.timerID >= 0 {
+		server.aeLoop.RemoveTimeEvent(c.bstate.timerID)
+	}
+	c.bstate = nil
+	resetClient(c)
+	if c.queryLen > 0 {
+		server.unblockedClients = append(server.unblockedClients, c)
+	}
+}
+
+// 处理被唤醒的客户端在阻塞期间收到的命令
+func processUnblockedClients() {
+	for len(server.unblockedClients) > 0 {
+		c := server.unblockedClients[0]
+		server.unblockedClients = server.unblockedClients[1:]
+		if server.clients[c.fd] != c || c.bstate != nil {
+			continue
+		}
+		if err := ProcessQueryBuf(c); err != nil {
+			freeClient(c)
+		}
+	}
+}
+
+func blockTimeoutProc(loop *AeLoop, id int, extra any) {
+	c := extra.(*GodisClient)
+	if c.bstate == nil || c.bstate.timerID != id {
+		return
+	}
+	c.bstate.timerID = -1
+	c.AddReplyStr(ReplyNullArray)
+	unblockClient(c)
+}
+
+// signalKeyAsReady 有客户端阻塞在key上时，记录下key等待命令执行完后处理
+func signalKeyAsReady(db *GodisDB, key *Gobj) {
+	if _, ok := db.blockingKeys[key.StrVal()]; !ok {
+		return
+	}
+	for _, rk := range server.readyKeys {
+		if rk.db == db && GStrEqual(rk.key, key) {
+			return
+		}
+	}
+	key.IncrRefCount()
+	server.readyKeys = append(server.readyKeys, readyKey{db, key})
+}
+
+// handleClientsBlockedOnKeys 按阻塞的先后顺序重新执行等待就绪key的客户端的命令
+// 执行过程中可能产生新的就绪key（例如BLMOVE写入目标list），因此循环处理
+func handleClientsBlockedOnKeys() {
+	for len(server.readyKeys) > 0 {
+		readyKeys := server.readyKeys
+		server.readyKeys = nil
+		for _, rk := range readyKeys {
+			clients := append([]*GodisClient(nil), rk.db.blockingKeys[rk.key.StrVal()]...)
+			for _, c := range clients {
+				if !c.blocked {
+					continue
+				}
+				o := rk.db.data.Get(rk.key)
+				if o == nil {
+					break
+				}
+				if o.Type_ != c.bstate.btype {
+					continue
+				}
+
+				removeFromBlockingKeys(c)
+				c.cmd.proc(c)
+				if !c.blocked {
+					unblockClient(c)
+				}
+			}
+			rk.key.DecrRefCount()
+		}
+	}
+}
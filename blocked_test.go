@@ -0,0 +1,123 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockingPop(t *testing.T) {
+	c1 := createTestClient(t)
+	c2 := CreateClient(-1)
+	c3 := CreateClient(-1)
+
+	execCommand(c2, "rpush", "l", "a", "b")
+	assert.Equal(t, "*2\r\n$1\r\nl\r\n$1\r\na\r\n", execCommand(c1, "blpop", "none", "l", "0"))
+	assert.Equal(t, "*2\r\n$1\r\nl\r\n$1\r\nb\r\n", execCommand(c1, "brpop", "l", "0"))
+
+	// 按阻塞的先后顺序唤醒客户端
+	assert.Equal(t, "", execCommand(c1, "blpop", "none", "q", "0"))
+	assert.Equal(t, "", execCommand(c3, "brpop", "q", "0"))
+	assert.True(t, c1.blocked)
+	assert.Equal(t, ":2\r\n", execCommand(c2, "rpush", "q", "1", "2"))
+	assert.Equal(t, "*2\r\n$1\r\nq\r\n$1\r\n1\r\n", readReply(c1))
+	assert.Equal(t, "*2\r\n$1\r\nq\r\n$1\r\n2\r\n", readReply(c3))
+	assert.False(t, c1.blocked)
+	assert.Nil(t, c1.bstate)
+	assert.Equal(t, ":0\r\n", execCommand(c2, "llen", "q"))
+	assert.Equal(t, 0, len(c1.db.blockingKeys))
+
+	// 类型不匹配时保持阻塞
+	assert.Equal(t, "", execCommand(c1, "bzpopmin", "z", "0"))
+	assert.Equal(t, ":1\r\n", execCommand(c2, "rpush", "z2", "x"))
+	assert.Equal(t, "", execCommand(c3, "blpop", "z", "0"))
+	assert.Equal(t, ":2\r\n", execCommand(c2, "zadd", "z", "2", "b", "1", "a"))
+	assert.Equal(t, "*3\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\n1\r\n", readReply(c1))
+	assert.Equal(t, "", readReply(c3))
+	assert.True(t, c3.blocked)
+	assert.Equal(t, "*3\r\n$1\r\nz\r\n$1\r\nb\r\n$1\r\n2\r\n", execCommand(c2, "bzpopmax", "z", "0"))
+	assert.Equal(t, ":1\r\n", execCommand(c2, "lpush", "z", "v"))
+	assert.Equal(t, "*2\r\n$1\r\nz\r\n$1\r\nv\r\n", readReply(c3))
+
+	assert.Equal(t, "-ERR timeout is negative\r\n", execCommand(c1, "blpop", "l", "-1"))
+	assert.Equal(t, "-ERR timeout is not a float or out of range\r\n", execCommand(c1, "blpop", "l", "x"))
+	execCommand(c2, "set", "s", "v")
+	assert.Equal(t, ReplyWrongType, execCommand(c1, "blpop", "none", "s", "0"))
+}
+
+func TestBlockingMove(t *testing.T) {
+	c1 := createTestClient(t)
+	c2 := CreateClient(-1)
+	c3 := CreateClient(-1)
+
+	assert.Equal(t, "", execCommand(c1, "blmove", "src", "dst", "left", "right", "0"))
+	assert.Equal(t, "", execCommand(c3, "blpop", "dst", "0"))
+	assert.Equal(t, ":1\r\n", execCommand(c2, "lpush", "src", "v"))
+	assert.Equal(t, "$1\r\nv\r\n", readReply(c1))
+	// BLMOVE写入dst后唤醒了等待dst的客户端
+	assert.Equal(t, "*2\r\n$3\r\ndst\r\n$1\r\nv\r\n", readReply(c3))
+	assert.Equal(t, ":0\r\n", execCommand(c2, "llen", "dst"))
+
+	execCommand(c2, "rpush", "l", "a", "b", "c")
+	assert.Equal(t, "$1\r\na\r\n", execCommand(c2, "lmove", "l", "l", "left", "right"))
+	assert.Equal(t, "*3\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\na\r\n", execCommand(c2, "lrange", "l", "0", "-1"))
+	assert.Equal(t, "$1\r\na\r\n", execCommand(c2, "lmove", "l", "l2", "right", "left"))
+	assert.Equal(t, ReplyNull, execCommand(c2, "lmove", "none", "l2", "right", "left"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c2, "lmove", "l", "l2", "up", "left"))
+}
+
+func TestBlockingXRead(t *testing.T) {
+	c1 := createTestClient(t)
+	c2 := CreateClient(-1)
+	c3 := CreateClient(-1)
+
+	// 阻塞时stream不存在，'$'从0-0开始读取
+	assert.Equal(t, "", execCommand(c1, "xread", "BLOCK", "0", "streams", "s", "$"))
+	assert.True(t, c1.blocked)
+	assert.Equal(t, "$3\r\n1-1\r\n", execCommand(c2, "xadd", "s", "1-1", "f", "v"))
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n*2\r\n$3\r\n1-1\r\n*2\r\n$1\r\nf\r\n$1\r\nv\r\n", readReply(c1))
+	assert.Nil(t, c1.bstate)
+
+	// 向已经存在的stream写入也会唤醒客户端，'$'使用阻塞时的最后一个ID
+	assert.Equal(t, "", execCommand(c1, "xread", "block", "0", "streams", "s", "$"))
+	execCommand(c2, "xgroup", "create", "s", "g", "$")
+	assert.Equal(t, "", execCommand(c3, "xreadgroup", "group", "g", "alice", "block", "0", "streams", "s", ">"))
+	assert.Equal(t, "$3\r\n2-1\r\n", execCommand(c2, "xadd", "s", "2-1", "f", "w"))
+	entry := "*2\r\n$3\r\n2-1\r\n*2\r\n$1\r\nf\r\n$1\r\nw\r\n"
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry, readReply(c1))
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry, readReply(c3))
+	assert.Equal(t, 0, len(c1.db.blockingKeys))
+
+	// 已经有数据时不阻塞
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry, execCommand(c1, "xread", "block", "0", "streams", "s", "1-1"))
+	assert.Equal(t, "-ERR timeout is negative\r\n", execCommand(c1, "xread", "block", "-1", "streams", "s", "$"))
+	assert.Equal(t, "-ERR timeout is not an integer or out of range\r\n", execCommand(c1, "xread", "block", "x", "streams", "s", "$"))
+
+	assert.Equal(t, "", execCommand(c1, "xread", "block", "10", "streams", "s", "$"))
+	time.Sleep(20 * time.Millisecond)
+	blockTimeoutProc(server.aeLoop, c1.bstate.timerID, c1)
+	assert.Equal(t, ReplyNullArray, readReply(c1))
+	assert.Nil(t, c1.bstate)
+}
+
+func TestBlockingTimeout(t *testing.T) {
+	c1 := createTestClient(t)
+	assert.Equal(t, "", execCommand(c1, "blpop", "l", "0.01"))
+	assert.True(t, c1.bstate.timerID >= 0)
+
+	time.Sleep(20 * time.Millisecond)
+	blockTimeoutProc(server.aeLoop, c1.bstate.timerID, c1)
+	assert.Equal(t, ReplyNullArray, readReply(c1))
+	assert.Nil(t, c1.bstate)
+	assert.Equal(t, 0, len(c1.db.blockingKeys))
+}
+
+func TestZPop(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "zadd", "z", "1", "a", "2", "b", "3", "c")
+	assert.Equal(t, "*2\r\n$1\r\na\r\n$1\r\n1\r\n", execCommand(c, "zpopmin", "z"))
+	assert.Equal(t, "*4\r\n$1\r\nc\r\n$1\r\n3\r\n$1\r\nb\r\n$1\r\n2\r\n", execCommand(c, "zpopmax", "z", "5"))
+	assert.Equal(t, ReplyEmptyArray, execCommand(c, "zpopmin", "z"))
+	assert.Equal(t, "-ERR value is out of range, must be positive\r\n", execCommand(c, "zpopmin", "z", "-1"))
+}
@@ -2,12 +2,44 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"io"
 	"os"
 )
 
+const (
+	GodisDefaultDBNum = 16
+	HashSipHash       = "siphash"
+	HashFNV           = "fnv"
+)
+
 type Config struct {
-	Port int `json:"port"`
+	Port         int    `json:"port"`
+	Databases    int    `json:"databases"`     // 逻辑数据库的数量
+	HashFunction string `json:"hash-function"` // key使用的哈希函数，siphash或fnv
+
+	// 小对象使用紧凑编码的阈值，超过之后转换为普通编码
+	ListMaxListpackSize    int `json:"list-max-listpack-size"` // 正数限制元素数量，-1到-5限制大小为4KB到64KB
+	ListCompressDepth      int `json:"list-compress-depth"`    // quicklist两端不压缩的节点数量，为0时不压缩
+	HashMaxListpackEntries int `json:"hash-max-listpack-entries"`
+	HashMaxListpackValue   int `json:"hash-max-listpack-value"`
+	SetMaxIntsetEntries    int `json:"set-max-intset-entries"`
+	ZSetMaxListpackEntries int `json:"zset-max-listpack-entries"`
+	ZSetMaxListpackValue   int `json:"zset-max-listpack-value"`
+}
+
+func DefaultConfig() *Config {
+	return &Config{
+		Databases:              GodisDefaultDBNum,
+		HashFunction:           HashSipHash,
+		ListMaxListpackSize:    -2,
+		ListCompressDepth:      0,
+		HashMaxListpackEntries: 128,
+		HashMaxListpackValue:   64,
+		SetMaxIntsetEntries:    512,
+		ZSetMaxListpackEntries: 128,
+		ZSetMaxListpackValue:   64,
+	}
 }
 
 func LoadConfig(path string) (config *Config, err error) {
@@ -23,11 +55,14 @@ func LoadConfig(path string) (config *Config, err error) {
 		return
 	}
 
-	config = &Config{}
+	config = DefaultConfig()
 	err = json.Unmarshal(jsonStr, config)
 	if err != nil {
 		return nil, err
 	}
+	if config.Databases < 1 {
+		return nil, errors.New("databases must be at least 1")
+	}
 
 	return
 }
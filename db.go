@@ -0,0 +1,471 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+func delGenericCommand(c *GodisClient) {
+	var deleted int64
+	for _, key := range c.args[1:] {
+		if findKeyWrite(c.db, key) != nil {
+			deleteKey(c.db, key)
+			deleted++
+		}
+	}
+	c.AddReplyInt(deleted)
+}
+
+func delCommand(c *GodisClient) {
+	delGenericCommand(c)
+}
+
+// 对象的内存由Go的GC回收，UNLINK与DEL的行为相同
+func unlinkCommand(c *GodisClient) {
+	delGenericCommand(c)
+}
+
+// 重复的key会被重复计数
+func existsCommand(c *GodisClient) {
+	var count int64
+	for _, key := range c.args[1:] {
+		if findKeyRead(c.db, key) != nil {
+			count++
+		}
+	}
+	c.AddReplyInt(count)
+}
+
+func touchCommand(c *GodisClient) {
+	existsCommand(c)
+}
+
+func typeCommand(c *GodisClient) {
+	o := findKeyRead(c.db, c.args[1])
+	if o == nil {
+		c.AddReplyStr("+none\r\n")
+		return
+	}
+	c.AddReplyStr("+" + o.TypeName() + "\r\n")
+}
+
+// 将src重命名为dst，过期时间随key一起移动，nx为true时dst存在则不做修改
+func renameGenericCommand(c *GodisClient, nx bool) {
+	src, dst := c.args[1], c.args[2]
+	o := findKeyWrite(c.db, src)
+	if o == nil {
+		c.AddReplyStr(ReplyNoSuchKey)
+		return
+	}
+
+	if GStrEqual(src, dst) {
+		if nx {
+			c.AddReplyInt(0)
+		} else {
+			c.AddReplyStr(ReplyOK)
+		}
+		return
+	}
+	if findKeyWrite(c.db, dst) != nil {
+		if nx {
+			c.AddReplyInt(0)
+			return
+		}
+		deleteKey(c.db, dst)
+	}
+
+	when := getExpire(c.db, src)
+	o.IncrRefCount()
+	deleteKey(c.db, src)
+	setKey(c.db, dst, o)
+	o.DecrRefCount()
+	if when != -1 {
+		setExpire(c.db, dst, when)
+	}
+
+	if nx {
+		c.AddReplyInt(1)
+	} else {
+		c.AddReplyStr(ReplyOK)
+	}
+}
+
+func renameCommand(c *GodisClient) {
+	renameGenericCommand(c, false)
+}
+
+func renamenxCommand(c *GodisClient) {
+	renameGenericCommand(c, true)
+}
+
+// COPY source destination [DB destination-db] [REPLACE]
+func copyCommand(c *GodisClient) {
+	replace := false
+	dstDB := c.db
+	for i := 3; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		if opt == "replace" {
+			replace = true
+		} else if opt == "db" && i+1 < len(c.args) {
+			var ok bool
+			if dstDB, ok = getDBOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			i++
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	src, dst := c.args[1], c.args[2]
+	if dstDB == c.db && GStrEqual(src, dst) {
+		c.AddReplyError("source and destination objects are the same")
+		return
+	}
+	o := findKeyRead(c.db, src)
+	if o == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if findKeyWrite(dstDB, dst) != nil {
+		if !replace {
+			c.AddReplyInt(0)
+			return
+		}
+		deleteKey(dstDB, dst)
+	}
+
+	dup := DupObject(o)
+	setKey(dstDB, dst, dup)
+	dup.DecrRefCount()
+	if when := getExpire(c.db, src); when != -1 {
+		setExpire(dstDB, dst, when)
+	}
+	c.AddReplyInt(1)
+}
+
+// 随机返回一个未过期的key，尝试一定次数后仍然只取到过期的key时返回null
+func randomkeyCommand(c *GodisClient) {
+	const maxTries = 100
+	for i := 0; i < maxTries; i++ {
+		e := c.db.data.RandomGet()
+		if e == nil {
+			break
+		}
+		key := e.Key
+		key.IncrRefCount()
+		if findKeyRead(c.db, key) != nil {
+			c.AddReplyBulk(key)
+			key.DecrRefCount()
+			return
+		}
+		key.DecrRefCount()
+	}
+	c.AddReplyStr(ReplyNull)
+}
+
+// KEYS会遍历整个数据库，只适合在数据量较小时使用
+func keysCommand(c *GodisClient) {
+	pattern := c.args[1].StrVal()
+	allKeys := pattern == "*"
+	now := GetMsTime()
+
+	var keys []*Gobj
+	iter := c.db.data.SafeIterator()
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		if !allKeys && !stringMatch(pattern, e.Key.StrVal(), false) {
+			continue
+		}
+		if when := getExpire(c.db, e.Key); when != -1 && when <= now {
+			continue
+		}
+		keys = append(keys, e.Key)
+	}
+	iter.Release()
+
+	c.AddReplyArrayLen(len(keys))
+	for _, key := range keys {
+		c.AddReplyBulk(key)
+	}
+}
+
+func dbsizeCommand(c *GodisClient) {
+	c.AddReplyInt(c.db.data.Size())
+}
+
+const ReplyEmptyScan = "*2\r\n$1\r\n0\r\n*0\r\n"
+
+// scanGenericCommand 处理SCAN、HSCAN、SSCAN和ZSCAN，o为nil时遍历整个数据库
+// 每次最多访问count*10个bucket，避免长时间阻塞事件循环
+func scanGenericCommand(c *GodisClient, o *Gobj, cursorIdx int) {
+	cursor, err := strconv.ParseUint(c.args[cursorIdx].StrVal(), 10, 64)
+	if err != nil {
+		c.AddReplyError("invalid cursor")
+		return
+	}
+
+	var count int64 = 10
+	pattern := ""
+	typeName := ""
+	for i := cursorIdx + 1; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		moreArgs := len(c.args) - 1 - i
+		if opt == "count" && moreArgs > 0 {
+			var ok bool
+			if count, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if count < 1 {
+				c.AddReplyStr(ReplySyntaxErr)
+				return
+			}
+			i++
+		} else if opt == "match" && moreArgs > 0 {
+			pattern = c.args[i+1].StrVal()
+			i++
+		} else if opt == "type" && o == nil && moreArgs > 0 {
+			typeName = strings.ToLower(c.args[i+1].StrVal())
+			switch typeName {
+			case "string", "list", "set", "zset", "hash", "stream":
+			default:
+				c.AddReplyError("unknown type name '" + c.args[i+1].StrVal() + "'")
+				return
+			}
+			i++
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+	if pattern == "*" {
+		pattern = ""
+	}
+
+	// 先收集entry再过滤，过滤时可能删除过期的key，因此需要增加引用计数
+	var items []*Gobj
+	var scan func(cursor uint64) uint64
+	withVals := false
+	if o != nil && o.Type_ != GSet {
+		withVals = true
+	}
+	switch {
+	case o != nil && o.Encoding() != EncodingHashtable && o.Encoding() != EncodingSkiplist:
+		// listpack和intset编码的对象很小，一次返回所有元素
+		scan = func(cursor uint64) uint64 {
+			add := func(member, val *Gobj) {
+				member.IncrRefCount()
+				items = append(items, member)
+				if val != nil {
+					val.IncrRefCount()
+					items = append(items, val)
+				}
+			}
+			switch o.Type_ {
+			case GSet:
+				setTypeForEach(o, func(member *Gobj) { add(member, nil) })
+			case GDict:
+				hashTypeForEach(o, add)
+			case GZSet:
+				o.Val_.(*ZSet).ForEach(func(member *Gobj, score float64) {
+					val := CreateFromFloat(score)
+					add(member, val)
+					val.DecrRefCount()
+				})
+			}
+			return 0
+		}
+	case o != nil && o.Type_ == GZSet:
+		scan = func(cursor uint64) uint64 {
+			return o.Val_.(*ZSet).dict.Scan(cursor, func(e *Entry[*Gobj, float64]) {
+				e.Key.IncrRefCount()
+				items = append(items, e.Key, CreateFromFloat(e.Val))
+			})
+		}
+	default:
+		dict := c.db.data
+		if o != nil {
+			dict = o.Val_.(*Dict[*Gobj, *Gobj])
+		}
+		scan = func(cursor uint64) uint64 {
+			return dict.Scan(cursor, func(e *Entry[*Gobj, *Gobj]) {
+				e.Key.IncrRefCount()
+				items = append(items, e.Key)
+				if withVals {
+					e.Val.IncrRefCount()
+					items = append(items, e.Val)
+				}
+			})
+		}
+	}
+
+	maxIterations := count * 10
+	for {
+		cursor = scan(cursor)
+		maxIterations--
+		if cursor == 0 || maxIterations <= 0 || int64(len(items)) >= count {
+			break
+		}
+	}
+
+	step := 1
+	if withVals {
+		step = 2
+	}
+	var result []*Gobj
+	for i := 0; i < len(items); i += step {
+		key := items[i]
+		keep := pattern == "" || stringMatch(pattern, key.StrVal(), false)
+		if keep && o == nil {
+			val := findKeyRead(c.db, key)
+			keep = val != nil && (typeName == "" || val.TypeName() == typeName)
+		}
+		if keep {
+			result = append(result, items[i:i+step]...)
+		}
+	}
+
+	c.AddReplyArrayLen(2)
+	c.AddReplyBulkStr(strconv.FormatUint(cursor, 10))
+	c.AddReplyArrayLen(len(result))
+	for _, item := range result {
+		c.AddReplyBulk(item)
+	}
+	for _, item := range items {
+		item.DecrRefCount()
+	}
+}
+
+func scanCommand(c *GodisClient) {
+	scanGenericCommand(c, nil, 1)
+}
+
+// 根据编号获取数据库，编号不合法时回复错误
+func getDBOrReply(c *GodisClient, o *Gobj) (*GodisDB, bool) {
+	id, ok := o.TryIntVal()
+	if !ok {
+		c.AddReplyError("invalid DB index")
+		return nil, false
+	}
+	if id < 0 || id >= int64(len(server.dbs)) {
+		c.AddReplyError("DB index is out of range")
+		return nil, false
+	}
+	return server.dbs[id], true
+}
+
+func selectCommand(c *GodisClient) {
+	db, ok := getDBOrReply(c, c.args[1])
+	if !ok {
+		return
+	}
+	c.db = db
+	c.AddReplyStr(ReplyOK)
+}
+
+// 将key移动到另一个数据库，目标数据库中已经存在时不做修改
+func moveCommand(c *GodisClient) {
+	dst, ok := getDBOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	if dst == c.db {
+		c.AddReplyError("source and destination objects are the same")
+		return
+	}
+
+	key := c.args[1]
+	o := findKeyWrite(c.db, key)
+	if o == nil || findKeyWrite(dst, key) != nil {
+		c.AddReplyInt(0)
+		return
+	}
+
+	when := getExpire(c.db, key)
+	o.IncrRefCount()
+	deleteKey(c.db, key)
+	setKey(dst, key, o)
+	o.DecrRefCount()
+	if when != -1 {
+		setExpire(dst, key, when)
+	}
+	c.AddReplyInt(1)
+}
+
+// 交换后有数据的key可能满足阻塞在这个数据库上的客户端
+func scanDatabaseForReadyKeys(db *GodisDB) {
+	for name := range db.blockingKeys {
+		key := CreateObject(GSTR, name)
+		if findKeyRead(db, key) != nil {
+			signalKeyAsReady(db, key)
+		}
+		key.DecrRefCount()
+	}
+}
+
+// 只交换两个数据库中的数据，客户端以及阻塞在key上的客户端仍然属于原来的数据库
+func swapdbCommand(c *GodisClient) {
+	db1, ok := getDBOrReply(c, c.args[1])
+	if !ok {
+		return
+	}
+	db2, ok := getDBOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+
+	if db1 != db2 {
+		db1.data, db2.data = db2.data, db1.data
+		db1.expire, db2.expire = db2.expire, db1.expire
+		scanDatabaseForReadyKeys(db1)
+		scanDatabaseForReadyKeys(db2)
+	}
+	c.AddReplyStr(ReplyOK)
+}
+
+// expire中的key与data共享同一个Gobj，val为毫秒时间戳
+var expireDictType = DictType[*Gobj, int64]{
+	HashFunc:      GStrHash,
+	EqualFunc:     GStrEqual,
+	KeyDup:        gobjDup,
+	KeyDestructor: gobjRelease,
+}
+
+// 旧的dict交给GC回收，因此ASYNC和SYNC的行为相同
+func emptyDB(db *GodisDB) {
+	db.data = DictCreate(GobjDictType)
+	db.expire = DictCreate(expireDictType)
+}
+
+func parseFlushFlagsOrReply(c *GodisClient) bool {
+	if len(c.args) > 2 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return false
+	}
+	if len(c.args) == 2 {
+		opt := strings.ToLower(c.args[1].StrVal())
+		if opt != "async" && opt != "sync" {
+			c.AddReplyStr(ReplySyntaxErr)
+			return false
+		}
+	}
+	return true
+}
+
+func flushdbCommand(c *GodisClient) {
+	if !parseFlushFlagsOrReply(c) {
+		return
+	}
+	emptyDB(c.db)
+	c.AddReplyStr(ReplyOK)
+}
+
+func flushallCommand(c *GodisClient) {
+	if !parseFlushFlagsOrReply(c) {
+		return
+	}
+	for _, db := range server.dbs {
+		emptyDB(db)
+	}
+	c.AddReplyStr(ReplyOK)
+}
@@ -0,0 +1,274 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDelExistsType(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "set", "s", "v")
+	execCommand(c, "rpush", "l", "a")
+	execCommand(c, "sadd", "set", "a")
+	execCommand(c, "zadd", "z", "1", "a")
+	execCommand(c, "hset", "h", "f", "v")
+	execCommand(c, "xadd", "x", "1-1", "f", "v")
+
+	assert.Equal(t, "+string\r\n", execCommand(c, "type", "s"))
+	assert.Equal(t, "+list\r\n", execCommand(c, "type", "l"))
+	assert.Equal(t, "+set\r\n", execCommand(c, "type", "set"))
+	assert.Equal(t, "+zset\r\n", execCommand(c, "type", "z"))
+	assert.Equal(t, "+hash\r\n", execCommand(c, "type", "h"))
+	assert.Equal(t, "+stream\r\n", execCommand(c, "type", "x"))
+	assert.Equal(t, "+none\r\n", execCommand(c, "type", "none"))
+
+	assert.Equal(t, ":6\r\n", execCommand(c, "dbsize"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "exists", "s", "s", "l", "none"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "touch", "s", "l", "none"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "del", "s", "l", "none"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "unlink", "set", "s"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "exists", "s", "l", "set"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "dbsize"))
+
+	// 过期的key不存在
+	execCommand(c, "set", "e", "v", "px", "1")
+	setExpire(c.db, CreateObject(GSTR, "e"), GetMsTime()-1)
+	assert.Equal(t, ":0\r\n", execCommand(c, "exists", "e"))
+}
+
+func TestRename(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ReplyNoSuchKey, execCommand(c, "rename", "a", "b"))
+
+	execCommand(c, "set", "a", "1", "ex", "100")
+	assert.Equal(t, ReplyOK, execCommand(c, "rename", "a", "a"))
+	assert.Equal(t, ReplyOK, execCommand(c, "rename", "a", "b"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "exists", "a"))
+	assert.Equal(t, "$1\r\n1\r\n", execCommand(c, "get", "b"))
+	assert.True(t, getExpire(c.db, CreateObject(GSTR, "b")) > GetMsTime())
+	assert.Equal(t, int64(-1), getExpire(c.db, CreateObject(GSTR, "a")))
+
+	execCommand(c, "set", "c", "2")
+	assert.Equal(t, ":0\r\n", execCommand(c, "renamenx", "b", "c"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "renamenx", "b", "d"))
+	assert.Equal(t, ReplyOK, execCommand(c, "rename", "d", "c"))
+	assert.Equal(t, "$1\r\n1\r\n", execCommand(c, "get", "c"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "dbsize"))
+}
+
+func TestCopy(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "rpush", "l", "a", "b")
+	assert.Equal(t, ":1\r\n", execCommand(c, "copy", "l", "l2"))
+	execCommand(c, "rpush", "l2", "c")
+	assert.Equal(t, ":2\r\n", execCommand(c, "llen", "l"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "llen", "l2"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "copy", "l", "l2"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "copy", "l", "l2", "replace"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "llen", "l2"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "copy", "none", "l2"))
+	assert.Equal(t, "-ERR source and destination objects are the same\r\n", execCommand(c, "copy", "l", "l"))
+
+	// 拷贝后修改字符串不会影响原来的值
+	execCommand(c, "setbit", "b", "0", "1")
+	assert.Equal(t, ":1\r\n", execCommand(c, "copy", "b", "b2"))
+	execCommand(c, "setbit", "b2", "1", "1")
+	assert.Equal(t, ":1\r\n", execCommand(c, "bitcount", "b"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "bitcount", "b2"))
+
+	execCommand(c, "zadd", "z", "1", "a", "2", "b")
+	execCommand(c, "hset", "h", "f", "v")
+	execCommand(c, "sadd", "s", "a", "b")
+	execCommand(c, "xadd", "x", "1-1", "f", "v")
+	execCommand(c, "xgroup", "create", "x", "g", "0")
+	execCommand(c, "xreadgroup", "group", "g", "alice", "streams", "x", ">")
+	for _, key := range []string{"z", "h", "s", "x"} {
+		assert.Equal(t, ":1\r\n", execCommand(c, "copy", key, key+"2", "db", "0"))
+	}
+	assert.Equal(t, "*4\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$1\r\n2\r\n", execCommand(c, "zrange", "z2", "0", "-1", "withscores"))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "hget", "h2", "f"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "scard", "s2"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "xack", "x2", "g", "1-1"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "xack", "x", "g", "1-1"))
+	assert.Equal(t, "-ERR DB index is out of range\r\n", execCommand(c, "copy", "z", "z3", "db", "16"))
+}
+
+func TestRandomKey(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ReplyNull, execCommand(c, "randomkey"))
+	execCommand(c, "set", "a", "1")
+	assert.Equal(t, "$1\r\na\r\n", execCommand(c, "randomkey"))
+	execCommand(c, "set", "b", "1")
+	reply := execCommand(c, "randomkey")
+	assert.True(t, reply == "$1\r\na\r\n" || reply == "$1\r\nb\r\n")
+}
+
+// 使用SCAN系列命令遍历直到游标为0，返回所有的元素
+func scanAll(c *GodisClient, args ...string) []string {
+	var items []string
+	cursor := "0"
+	for {
+		var cmd []string
+		if args[0] == "scan" {
+			cmd = append([]string{"scan", cursor}, args[1:]...)
+		} else {
+			cmd = append([]string{args[0], args[1], cursor}, args[2:]...)
+		}
+		reply := execCommand(c, cmd...)
+		lines := strings.Split(reply, "\r\n")
+		cursor = lines[2]
+		for i := 5; i < len(lines); i += 2 {
+			items = append(items, lines[i])
+		}
+		if cursor == "0" {
+			return items
+		}
+	}
+}
+
+func TestScan(t *testing.T) {
+	c := createTestClient(t)
+	for i := 0; i < 50; i++ {
+		execCommand(c, "set", fmt.Sprintf("key:%v", i), "v")
+	}
+	execCommand(c, "rpush", "list", "a")
+
+	assert.Len(t, scanAll(c, "scan"), 51)
+	assert.Len(t, scanAll(c, "scan", "count", "3"), 51)
+	assert.Len(t, scanAll(c, "scan", "match", "key:1*"), 11)
+	assert.Equal(t, []string{"list"}, scanAll(c, "scan", "type", "list"))
+	assert.Equal(t, "-ERR invalid cursor\r\n", execCommand(c, "scan", "x"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "scan", "0", "count", "0"))
+	assert.Equal(t, "-ERR unknown type name 'foo'\r\n", execCommand(c, "scan", "0", "type", "foo"))
+
+	execCommand(c, "hset", "h", "f1", "v1", "f2", "v2")
+	assert.ElementsMatch(t, []string{"f1", "v1", "f2", "v2"}, scanAll(c, "hscan", "h"))
+	assert.ElementsMatch(t, []string{"f2", "v2"}, scanAll(c, "hscan", "h", "match", "*2"))
+	execCommand(c, "sadd", "s", "a", "b", "c")
+	assert.ElementsMatch(t, []string{"a", "b", "c"}, scanAll(c, "sscan", "s"))
+	execCommand(c, "zadd", "z", "1", "a", "2.5", "b")
+	assert.ElementsMatch(t, []string{"a", "1", "b", "2.5"}, scanAll(c, "zscan", "z"))
+	assert.Equal(t, ReplyEmptyScan, execCommand(c, "zscan", "none", "0"))
+	assert.Equal(t, ReplyWrongType, execCommand(c, "sscan", "z", "0"))
+}
+
+func TestKeys(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ReplyEmptyArray, execCommand(c, "keys", "*"))
+	execCommand(c, "set", "hello", "v")
+	execCommand(c, "set", "hallo", "v")
+	execCommand(c, "set", "hxllo", "v")
+	execCommand(c, "set", "world", "v")
+
+	assert.Len(t, sortedBulks(execCommand(c, "keys", "*")), 4)
+	assert.Equal(t, []string{"hallo", "hello"}, sortedBulks(execCommand(c, "keys", "h[ae]llo")))
+	assert.Equal(t, []string{"hxllo"}, sortedBulks(execCommand(c, "keys", "h[^ae]llo")))
+	assert.Equal(t, []string{"world"}, sortedBulks(execCommand(c, "keys", "w*")))
+
+	setExpire(c.db, CreateObject(GSTR, "world"), GetMsTime()-1)
+	assert.Equal(t, ReplyEmptyArray, execCommand(c, "keys", "w*"))
+}
+
+func TestSelectMove(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "set", "k", "v", "ex", "100")
+	assert.Equal(t, ":1\r\n", execCommand(c, "move", "k", "1"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "move", "k", "1"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "dbsize"))
+
+	assert.Equal(t, ReplyOK, execCommand(c, "select", "1"))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "get", "k"))
+	assert.Equal(t, ":100\r\n", execCommand(c, "ttl", "k"))
+	assert.Equal(t, "-ERR source and destination objects are the same\r\n", execCommand(c, "move", "k", "1"))
+	assert.Equal(t, "-ERR DB index is out of range\r\n", execCommand(c, "select", "16"))
+	assert.Equal(t, "-ERR invalid DB index\r\n", execCommand(c, "select", "x"))
+
+	// 目标数据库中已经存在时不移动
+	execCommand(c, "set", "k2", "v1")
+	c2 := CreateClient(-1)
+	execCommand(c2, "set", "k2", "v0")
+	assert.Equal(t, ":0\r\n", execCommand(c, "move", "k2", "0"))
+	assert.Equal(t, "$2\r\nv1\r\n", execCommand(c, "get", "k2"))
+
+	assert.Equal(t, ":1\r\n", execCommand(c, "copy", "k", "k", "db", "0"))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c2, "get", "k"))
+}
+
+func TestSwapDBFlush(t *testing.T) {
+	c := createTestClient(t)
+	c2 := CreateClient(-1)
+	execCommand(c2, "select", "1")
+	execCommand(c, "set", "a", "0")
+	execCommand(c2, "set", "b", "1")
+
+	// 阻塞在db1上的客户端在交换后被唤醒
+	assert.Equal(t, "", execCommand(c2, "blpop", "l", "0"))
+	execCommand(c, "rpush", "l", "x")
+	assert.Equal(t, ReplyOK, execCommand(c, "swapdb", "0", "1"))
+	assert.Equal(t, "*2\r\n$1\r\nl\r\n$1\r\nx\r\n", readReply(c2))
+	assert.Equal(t, "$1\r\n1\r\n", execCommand(c, "get", "b"))
+	assert.Equal(t, "$1\r\n0\r\n", execCommand(c2, "get", "a"))
+
+	assert.Equal(t, ReplyOK, execCommand(c, "flushdb"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "dbsize"))
+	assert.Equal(t, ":1\r\n", execCommand(c2, "dbsize"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "flushall", "now"))
+	assert.Equal(t, ReplyOK, execCommand(c, "flushall", "async"))
+	assert.Equal(t, ":0\r\n", execCommand(c2, "dbsize"))
+}
+
+func TestObjectEncoding(t *testing.T) {
+	c := createTestClient(t)
+	encoding := func(key string) string {
+		return execCommand(c, "object", "encoding", key)
+	}
+
+	execCommand(c, "set", "s", "12345")
+	assert.Equal(t, "$3\r\nint\r\n", encoding("s"))
+	execCommand(c, "set", "s", "abc")
+	assert.Equal(t, "$6\r\nembstr\r\n", encoding("s"))
+	execCommand(c, "set", "s", strings.Repeat("a", 45))
+	assert.Equal(t, "$3\r\nraw\r\n", encoding("s"))
+
+	// 小整数使用共享对象
+	execCommand(c, "set", "a", "100")
+	execCommand(c, "set", "b", "100")
+	a := c.db.data.Get(CreateObject(GSTR, "a"))
+	assert.True(t, a == c.db.data.Get(CreateObject(GSTR, "b")))
+	assert.Equal(t, fmt.Sprintf(":%d\r\n", SharedRefCount), execCommand(c, "object", "refcount", "a"))
+
+	execCommand(c, "rpush", "l", "a", "1")
+	assert.Equal(t, "$8\r\nlistpack\r\n", encoding("l"))
+	execCommand(c, "rpush", "l", strings.Repeat("x", 9000))
+	assert.Equal(t, "$9\r\nquicklist\r\n", encoding("l"))
+	assert.Equal(t, "$1\r\n1\r\n", execCommand(c, "lindex", "l", "1"))
+	execCommand(c, "rpop", "l")
+	assert.Equal(t, "$8\r\nlistpack\r\n", encoding("l"))
+
+	execCommand(c, "hset", "h", "f", "v")
+	assert.Equal(t, "$8\r\nlistpack\r\n", encoding("h"))
+	execCommand(c, "hset", "h", "f2", strings.Repeat("v", 65))
+	assert.Equal(t, "$9\r\nhashtable\r\n", encoding("h"))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "hget", "h", "f"))
+
+	execCommand(c, "sadd", "set", "1", "2")
+	assert.Equal(t, "$6\r\nintset\r\n", encoding("set"))
+	execCommand(c, "sadd", "set", "x")
+	assert.Equal(t, "$9\r\nhashtable\r\n", encoding("set"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "scard", "set"))
+
+	for i := 0; i < 128; i++ {
+		execCommand(c, "zadd", "z", strconv.Itoa(i), "m"+strconv.Itoa(i))
+	}
+	assert.Equal(t, "$8\r\nlistpack\r\n", encoding("z"))
+	execCommand(c, "zadd", "z", "200", "m200")
+	assert.Equal(t, "$8\r\nskiplist\r\n", encoding("z"))
+	assert.Equal(t, ":128\r\n", execCommand(c, "zrank", "z", "m200"))
+
+	assert.Equal(t, ReplyNull, encoding("none"))
+	assert.Equal(t, "-ERR unknown subcommand or wrong number of arguments for 'freq'\r\n", execCommand(c, "object", "freq", "s"))
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"errors"
 	"math"
+	"math/bits"
 	"math/rand"
 )
 
@@ -11,6 +12,8 @@ const (
 	ForceRatio  int64 = 2
 	GrowRatio   int64 = 2
 	DefaultStep int   = 1
+	// used/size低于该百分比时缩容
+	MinFillPercent int64 = 10
 )
 
 var (
@@ -19,48 +22,72 @@ var (
 	NkErr = errors.New("key doesn't exist error")
 )
 
-type Entry struct {
-	Key  *Gobj
-	Val  *Gobj
-	next *Entry
+// 哈希函数的种子，服务启动时随机生成，攻击者无法提前构造出大量冲突的key
+var dictHashSeed [16]byte
+
+func DictSetHashSeed(seed []byte) {
+	copy(dictHashSeed[:], seed)
+}
+
+func DictGenHash(s string) uint64 {
+	return siphash(s, &dictHashSeed)
+}
+
+func DictGenCaseHash(s string) uint64 {
+	return siphashNocase(s, &dictHashSeed)
+}
+
+type Entry[K, V any] struct {
+	Key  K
+	Val  V
+	next *Entry[K, V]
 }
 
-type htable struct {
-	table []*Entry
+type htable[K, V any] struct {
+	table []*Entry[K, V]
 	size  int64
 	mask  int64
 	used  int64
 }
 
-type DictType struct {
-	HashFunc  func(key *Gobj) int64
-	EqualFunc func(key1, key2 *Gobj) bool
+// DictType 中的Dup和Destructor是可选的，在entry写入和删除时调用，用于管理key和val的生命周期（例如Gobj的引用计数）
+type DictType[K, V any] struct {
+	HashFunc      func(key K) int64
+	EqualFunc     func(key1, key2 K) bool
+	KeyDup        func(key K) K
+	ValDup        func(val V) V
+	KeyDestructor func(key K)
+	ValDestructor func(val V)
 }
 
-type Dict struct {
-	DictType
-	hts       [2]*htable
-	rehashIdx int64
-	// iterators
+type Dict[K, V any] struct {
+	DictType[K, V]
+	hts         [2]*htable[K, V]
+	rehashIdx   int64
+	pauseRehash int // 存活的安全迭代器的数量，大于0时暂停渐进式rehash
 }
 
-func DictCreate(dictType DictType) *Dict {
-	var dict Dict
+func DictCreate[K, V any](dictType DictType[K, V]) *Dict[K, V] {
+	var dict Dict[K, V]
 	dict.DictType = dictType
 	dict.rehashIdx = -1
 	return &dict
 }
 
-func (dict *Dict) isRehashing() bool {
+func (dict *Dict[K, V]) isRehashing() bool {
 	return dict.rehashIdx != -1
 }
 
 // 每次只变化一小次  这里的step可以自己进行设置
-func (dict *Dict) rehashStep() {
-	dict.rehash(DefaultStep)
+func (dict *Dict[K, V]) rehashStep() {
+	if dict.pauseRehash == 0 {
+		dict.rehash(DefaultStep)
+	}
 }
 
-func (dict *Dict) rehash(step int) {
+func (dict *Dict[K, V]) rehash(step int) {
+	// 限制一次最多访问的空slot数量，缩容后的表可能非常稀疏
+	emptyVisits := step * 10
 	for step > 0 {
 		// 如果第一张表为空（迁移完了），说明第一张的表的内容已经完全复制到第二张表了，则直接使用第一张表，删除第二张表
 		if dict.hts[0].used == 0 {
@@ -73,6 +100,10 @@ func (dict *Dict) rehash(step int) {
 		// find an nonull slot  找到有值的slot
 		for dict.hts[0].table[dict.rehashIdx] == nil {
 			dict.rehashIdx += 1
+			emptyVisits--
+			if emptyVisits == 0 {
+				return
+			}
 		}
 
 		entry := dict.hts[0].table[dict.rehashIdx]
@@ -102,17 +133,17 @@ func nextPower(size int64) int64 {
 	return -1
 }
 
-func (dict *Dict) expand(size int64) error {
+func (dict *Dict[K, V]) expand(size int64) error {
 	sz := nextPower(size)
-	if dict.isRehashing() || (dict.hts[0] != nil && dict.hts[0].size >= sz) {
+	if dict.isRehashing() || (dict.hts[0] != nil && dict.hts[0].size == sz) {
 		return EpErr
 	}
 
-	var ht htable
+	var ht htable[K, V]
 	ht.size = sz
 	ht.mask = sz - 1
 	ht.used = 0
-	ht.table = make([]*Entry, sz)
+	ht.table = make([]*Entry[K, V], sz)
 
 	if dict.hts[0] == nil {
 		dict.hts[0] = &ht
@@ -124,7 +155,7 @@ func (dict *Dict) expand(size int64) error {
 	return nil
 }
 
-func (dict *Dict) expandIfNeed() error {
+func (dict *Dict[K, V]) expandIfNeed() error {
 	if dict.isRehashing() {
 		return nil
 	}
@@ -140,8 +171,49 @@ func (dict *Dict) expandIfNeed() error {
 	return nil
 }
 
+// Resize 将表缩小到能容纳所有元素的最小大小
+func (dict *Dict[K, V]) Resize() error {
+	if dict.isRehashing() || dict.pauseRehash > 0 {
+		return EpErr
+	}
+	return dict.expand(dict.hts[0].used)
+}
+
+func (dict *Dict[K, V]) needsResize() bool {
+	if dict.hts[0] == nil {
+		return false
+	}
+	size, used := dict.hts[0].size, dict.hts[0].used
+	return size > InitSize && used*100/size < MinFillPercent
+}
+
+// ShrinkIfNeed 元素数量大幅减少之后缩容，释放多余的bucket
+func (dict *Dict[K, V]) ShrinkIfNeed() {
+	if !dict.isRehashing() && dict.needsResize() {
+		dict.Resize()
+	}
+}
+
+// RehashMilliseconds 在ms毫秒内尽可能多地推进rehash，返回执行的step数
+func (dict *Dict[K, V]) RehashMilliseconds(ms int64) int {
+	if dict.pauseRehash > 0 {
+		return 0
+	}
+
+	start := GetMsTime()
+	rehashes := 0
+	for dict.isRehashing() {
+		dict.rehash(100)
+		rehashes += 100
+		if GetMsTime()-start > ms {
+			break
+		}
+	}
+	return rehashes
+}
+
 // return the index of a free slot, return -1 if the key exists or err.
-func (dict *Dict) keyIndex(key *Gobj) int64 {
+func (dict *Dict[K, V]) keyIndex(key K) int64 {
 	err := dict.expandIfNeed()
 	if err != nil {
 		return -1
@@ -167,7 +239,7 @@ func (dict *Dict) keyIndex(key *Gobj) int64 {
 	return idx
 }
 
-func (dict *Dict) AddRaw(key *Gobj) *Entry {
+func (dict *Dict[K, V]) AddRaw(key K) *Entry[K, V] {
 	if dict.isRehashing() {
 		dict.rehashStep()
 	}
@@ -177,16 +249,18 @@ func (dict *Dict) AddRaw(key *Gobj) *Entry {
 		return nil
 	}
 
-	var ht *htable
+	var ht *htable[K, V]
 	if dict.isRehashing() {
 		ht = dict.hts[1]
 	} else {
 		ht = dict.hts[0]
 	}
 
-	var e Entry
+	var e Entry[K, V]
 	e.Key = key
-	key.IncrRefCount()
+	if dict.KeyDup != nil {
+		e.Key = dict.KeyDup(key)
+	}
 	e.next = ht.table[idx]
 	ht.table[idx] = &e
 	ht.used++
@@ -194,18 +268,24 @@ func (dict *Dict) AddRaw(key *Gobj) *Entry {
 }
 
 // Add add a new key-val pair, return err if key exists
-func (dict *Dict) Add(key, val *Gobj) error {
+func (dict *Dict[K, V]) Add(key K, val V) error {
 	entry := dict.AddRaw(key)
 	if entry == nil {
 		return ExErr
 	}
 
-	entry.Val = val
-	val.IncrRefCount()
+	dict.setVal(entry, val)
 	return nil
 }
 
-func (dict *Dict) Find(key *Gobj) *Entry {
+func (dict *Dict[K, V]) setVal(entry *Entry[K, V], val V) {
+	entry.Val = val
+	if dict.ValDup != nil {
+		entry.Val = dict.ValDup(val)
+	}
+}
+
+func (dict *Dict[K, V]) Find(key K) *Entry[K, V] {
 	if dict.hts[0] == nil {
 		return nil
 	}
@@ -231,24 +311,31 @@ func (dict *Dict) Find(key *Gobj) *Entry {
 	return nil
 }
 
-func (dict *Dict) Set(key, val *Gobj) {
+func (dict *Dict[K, V]) Set(key K, val V) {
 	if err := dict.Add(key, val); err == nil {
 		return
 	}
 
 	entry := dict.Find(key)
-	// 该key对应的旧val的引用需要-1
-	entry.Val.DecrRefCount()
-	entry.Val = val
-	val.IncrRefCount()
+	// 先写入新的val再释放旧的val，两者可能是同一个对象
+	old := entry.Val
+	dict.setVal(entry, val)
+	if dict.ValDestructor != nil {
+		dict.ValDestructor(old)
+	}
 }
 
-func freeEntry(e *Entry) {
-	e.Key.DecrRefCount()
-	e.Val.DecrRefCount()
+// 通过AddRaw添加的entry可以没有val（例如set），此时Val为零值
+func (dict *Dict[K, V]) freeEntry(e *Entry[K, V]) {
+	if dict.KeyDestructor != nil {
+		dict.KeyDestructor(e.Key)
+	}
+	if dict.ValDestructor != nil {
+		dict.ValDestructor(e.Val)
+	}
 }
 
-func (dict *Dict) Delete(key *Gobj) error {
+func (dict *Dict[K, V]) Delete(key K) error {
 	if dict.hts[0] == nil {
 		return NkErr
 	}
@@ -261,7 +348,7 @@ func (dict *Dict) Delete(key *Gobj) error {
 	for i := 0; i <= 1; i++ {
 		idx := h & dict.hts[i].mask
 		e := dict.hts[i].table[idx]
-		var prev *Entry
+		var prev *Entry[K, V]
 		for e != nil {
 			if dict.EqualFunc(e.Key, key) {
 				if prev == nil {
@@ -270,7 +357,9 @@ func (dict *Dict) Delete(key *Gobj) error {
 					prev.next = e.next
 				}
 
-				freeEntry(e)
+				dict.hts[i].used--
+				dict.freeEntry(e)
+				dict.ShrinkIfNeed()
 				return nil
 			}
 
@@ -286,16 +375,133 @@ func (dict *Dict) Delete(key *Gobj) error {
 	return NkErr
 }
 
-func (dict *Dict) Get(key *Gobj) *Gobj {
+// Get 返回key对应的val，key不存在时返回零值
+func (dict *Dict[K, V]) Get(key K) V {
 	entry := dict.Find(key)
 	if entry == nil {
-		return nil
+		var zero V
+		return zero
 	}
 
 	return entry.Val
 }
 
-func (dict *Dict) RandomGet() *Entry {
+// Size 返回dict中entry的数量
+func (dict *Dict[K, V]) Size() int64 {
+	var size int64
+	for _, ht := range dict.hts {
+		if ht != nil {
+			size += ht.used
+		}
+	}
+	return size
+}
+
+// ForEach 依次访问dict中的每个entry，fn中可以查找dict（安全迭代器会暂停rehash），但不允许修改dict
+func (dict *Dict[K, V]) ForEach(fn func(e *Entry[K, V])) {
+	iter := dict.SafeIterator()
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		fn(e)
+	}
+	iter.Release()
+}
+
+// DictIterator 安全迭代器在存活期间暂停rehash，迭代过程中可以修改dict（例如删除当前entry）；
+// 非安全迭代器只允许读，Release时通过指纹检查迭代过程中dict没有被修改
+type DictIterator[K, V any] struct {
+	dict        *Dict[K, V]
+	table       int
+	index       int64
+	safe        bool
+	entry       *Entry[K, V]
+	nextEntry   *Entry[K, V]
+	fingerprint int64
+}
+
+func (dict *Dict[K, V]) Iterator() *DictIterator[K, V] {
+	return &DictIterator[K, V]{dict: dict, index: -1}
+}
+
+func (dict *Dict[K, V]) SafeIterator() *DictIterator[K, V] {
+	iter := dict.Iterator()
+	iter.safe = true
+	return iter
+}
+
+// Next 返回下一个entry，遍历结束时返回nil
+func (iter *DictIterator[K, V]) Next() *Entry[K, V] {
+	dict := iter.dict
+	for {
+		if iter.entry == nil {
+			if iter.index == -1 && iter.table == 0 {
+				if iter.safe {
+					dict.pauseRehash++
+				} else {
+					iter.fingerprint = dict.fingerprint()
+				}
+			}
+			iter.index++
+			ht := dict.hts[iter.table]
+			if ht == nil || iter.index >= ht.size {
+				if dict.isRehashing() && iter.table == 0 {
+					iter.table++
+					iter.index = 0
+					ht = dict.hts[1]
+				} else {
+					return nil
+				}
+			}
+			iter.entry = ht.table[iter.index]
+		} else {
+			iter.entry = iter.nextEntry
+		}
+
+		// 提前保存next，当前entry被删除后仍然可以继续遍历
+		if iter.entry != nil {
+			iter.nextEntry = iter.entry.next
+			return iter.entry
+		}
+	}
+}
+
+func (iter *DictIterator[K, V]) Release() {
+	if iter.index == -1 && iter.table == 0 {
+		return
+	}
+	if iter.safe {
+		iter.dict.pauseRehash--
+	} else if iter.fingerprint != iter.dict.fingerprint() {
+		panic("dict was modified while using an unsafe iterator")
+	}
+}
+
+// fingerprint 根据dict的状态计算出一个64位的指纹，dict被修改后指纹会发生变化
+func (dict *Dict[K, V]) fingerprint() int64 {
+	var integers [6]int64
+	for i, ht := range dict.hts {
+		if ht != nil {
+			integers[i*3] = ht.size
+			integers[i*3+1] = ht.used
+			integers[i*3+2] = dict.rehashIdx
+		}
+	}
+
+	// Thomas Wang的64位整数哈希
+	var hash uint64
+	for _, v := range integers {
+		hash += uint64(v)
+		hash = ^hash + (hash << 21)
+		hash = hash ^ (hash >> 24)
+		hash = (hash + (hash << 3)) + (hash << 8)
+		hash = hash ^ (hash >> 14)
+		hash = (hash + (hash << 2)) + (hash << 4)
+		hash = hash ^ (hash >> 28)
+		hash = hash + (hash << 31)
+	}
+	return int64(hash)
+}
+
+func (dict *Dict[K, V]) RandomGet() *Entry[K, V] {
 	if dict.hts[0] == nil {
 		return nil
 	}
@@ -336,3 +542,51 @@ func (dict *Dict) RandomGet() *Entry {
 
 	return p
 }
+
+// 对游标的高位加1，即反转后加1再反转回来
+func reverseIncr(v, mask uint64) uint64 {
+	v |= ^mask
+	v = bits.Reverse64(v)
+	v++
+	return bits.Reverse64(v)
+}
+
+func scanBucket[K, V any](ht *htable[K, V], idx uint64, fn func(e *Entry[K, V])) {
+	e := ht.table[idx&uint64(ht.mask)]
+	for e != nil {
+		next := e.next
+		fn(e)
+		e = next
+	}
+}
+
+// Scan 访问游标对应的bucket中的entry并返回下一个游标，返回0时表示遍历结束
+// 游标从高位开始递增，表的大小在两次调用之间发生变化（包括rehash的过程中）时，
+// 遍历开始时就存在的entry至少会被访问一次，但可能被访问多次。fn中不允许修改dict
+func (dict *Dict[K, V]) Scan(cursor uint64, fn func(e *Entry[K, V])) uint64 {
+	if dict.Size() == 0 {
+		return 0
+	}
+
+	if !dict.isRehashing() {
+		t0 := dict.hts[0]
+		scanBucket(t0, cursor, fn)
+		return reverseIncr(cursor, uint64(t0.mask))
+	}
+
+	// 先访问小表中的bucket，再访问大表中所有由它扩展出来的bucket
+	t0, t1 := dict.hts[0], dict.hts[1]
+	if t0.size > t1.size {
+		t0, t1 = t1, t0
+	}
+	m0, m1 := uint64(t0.mask), uint64(t1.mask)
+	scanBucket(t0, cursor, fn)
+	for {
+		scanBucket(t1, cursor, fn)
+		cursor = reverseIncr(cursor, m1)
+		if cursor&(m0^m1) == 0 {
+			break
+		}
+	}
+	return cursor
+}
@@ -7,7 +7,7 @@ import (
 )
 
 func TestDict(t *testing.T) {
-	dict := DictCreate(DictType{EqualFunc: GStrEqual, HashFunc: GStrHash})
+	dict := DictCreate(GobjDictType)
 	entry := dict.RandomGet()
 	assert.Nil(t, entry)
 
@@ -26,6 +26,7 @@ func TestDict(t *testing.T) {
 	assert.Nil(t, e)
 	entry = dict.Find(k1)
 	assert.Nil(t, e)
+	assert.Equal(t, int64(0), dict.Size())
 	assert.Equal(t, 1, k1.refCount)
 	assert.Equal(t, 1, v1.refCount)
 
@@ -42,10 +43,7 @@ func TestDict(t *testing.T) {
 }
 
 func TestRehash(t *testing.T) {
-	dict := DictCreate(DictType{
-		HashFunc:  GStrHash,
-		EqualFunc: GStrEqual,
-	})
+	dict := DictCreate(GobjDictType)
 
 	entry := dict.RandomGet()
 	assert.Nil(t, entry)
@@ -79,3 +77,166 @@ func TestRehash(t *testing.T) {
 		assert.Equal(t, fmt.Sprintf("v%v", i), entry.Val.StrVal())
 	}
 }
+
+func TestDictScan(t *testing.T) {
+	dict := DictCreate(GobjDictType)
+	assert.Equal(t, uint64(0), dict.Scan(0, func(e *Entry[*Gobj, *Gobj]) {}))
+
+	for i := 0; i < 100; i++ {
+		dict.AddRaw(CreateObject(GSTR, fmt.Sprintf("k%v", i)))
+	}
+	seen := make(map[string]int)
+	var cursor uint64
+	for {
+		cursor = dict.Scan(cursor, func(e *Entry[*Gobj, *Gobj]) {
+			seen[e.Key.StrVal()]++
+		})
+		if cursor == 0 {
+			break
+		}
+	}
+	assert.Len(t, seen, 100)
+	for _, cnt := range seen {
+		assert.Equal(t, 1, cnt)
+	}
+
+	// 遍历过程中表发生扩容，开始时就存在的key都要被访问到
+	seen = make(map[string]int)
+	cursor = 0
+	added := 100
+	for {
+		cursor = dict.Scan(cursor, func(e *Entry[*Gobj, *Gobj]) {
+			seen[e.Key.StrVal()]++
+		})
+		if cursor == 0 {
+			break
+		}
+		for i := 0; i < 20; i++ {
+			dict.AddRaw(CreateObject(GSTR, fmt.Sprintf("k%v", added)))
+			added++
+		}
+	}
+	for i := 0; i < 100; i++ {
+		assert.True(t, seen[fmt.Sprintf("k%v", i)] > 0)
+	}
+}
+
+func TestDictIterator(t *testing.T) {
+	dict := DictCreate(GobjDictType)
+	iter := dict.Iterator()
+	assert.Nil(t, iter.Next())
+	iter.Release()
+
+	value := int(InitSize * (ForceRatio + 1))
+	for i := 0; i <= value; i++ {
+		dict.AddRaw(CreateObject(GSTR, fmt.Sprintf("k%v", i)))
+	}
+	assert.True(t, dict.isRehashing())
+
+	// 安全迭代器存活期间暂停rehash，并且允许删除当前entry
+	iter = dict.SafeIterator()
+	rehashIdx := dict.rehashIdx
+	seen := make(map[string]int)
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		seen[e.Key.StrVal()]++
+		dict.Find(e.Key)
+		assert.Equal(t, rehashIdx, dict.rehashIdx)
+		if len(seen)%2 == 0 {
+			dict.Delete(e.Key)
+		}
+	}
+	iter.Release()
+	assert.Len(t, seen, value+1)
+	for _, cnt := range seen {
+		assert.Equal(t, 1, cnt)
+	}
+	assert.Equal(t, int64(value+1-len(seen)/2), dict.Size())
+	assert.Equal(t, 0, dict.pauseRehash)
+
+	cnt := 0
+	dict.ForEach(func(e *Entry[*Gobj, *Gobj]) {
+		cnt++
+	})
+	assert.Equal(t, int(dict.Size()), cnt)
+
+	// 非安全迭代器遍历期间修改dict会在Release时panic
+	iter = dict.Iterator()
+	e := iter.Next()
+	dict.Delete(e.Key)
+	assert.Panics(t, func() {
+		iter.Release()
+	})
+}
+
+func TestDictShrink(t *testing.T) {
+	dict := DictCreate(GobjDictType)
+	for i := 0; i < 1000; i++ {
+		dict.AddRaw(CreateObject(GSTR, fmt.Sprintf("k%v", i)))
+	}
+	dict.RehashMilliseconds(100)
+	assert.False(t, dict.isRehashing())
+	size := dict.hts[0].size
+
+	// 删除大部分key之后触发缩容，由RehashMilliseconds完成剩余的迁移
+	for i := 0; i < 990; i++ {
+		dict.Delete(CreateObject(GSTR, fmt.Sprintf("k%v", i)))
+	}
+	assert.True(t, dict.isRehashing())
+	assert.True(t, dict.RehashMilliseconds(100) > 0)
+	assert.False(t, dict.isRehashing())
+	assert.True(t, dict.hts[0].size < size)
+	for i := 990; i < 1000; i++ {
+		assert.NotNil(t, dict.Find(CreateObject(GSTR, fmt.Sprintf("k%v", i))))
+	}
+
+	// 安全迭代器存活期间不缩容也不rehash
+	iter := dict.SafeIterator()
+	for e := iter.Next(); e != nil; e = iter.Next() {
+		dict.Delete(e.Key)
+	}
+	assert.False(t, dict.isRehashing())
+	iter.Release()
+	assert.Equal(t, int64(0), dict.Size())
+	dict.ShrinkIfNeed()
+	assert.True(t, dict.isRehashing())
+	dict.RehashMilliseconds(1)
+	assert.Equal(t, InitSize, dict.hts[0].size)
+}
+
+func TestDictGeneric(t *testing.T) {
+	dict := DictCreate(DictType[int64, string]{
+		HashFunc:  func(key int64) int64 { return key },
+		EqualFunc: func(a, b int64) bool { return a == b },
+	})
+	for i := int64(0); i < 100; i++ {
+		assert.Nil(t, dict.Add(i, fmt.Sprintf("v%v", i)))
+	}
+	assert.Equal(t, ExErr, dict.Add(1, "v"))
+	dict.Set(1, "new")
+	assert.Equal(t, "new", dict.Get(1))
+	assert.Equal(t, "", dict.Get(100))
+	assert.Nil(t, dict.Delete(2))
+	assert.Nil(t, dict.Find(2))
+	assert.Equal(t, int64(99), dict.Size())
+
+	list := ListCreate(ListType[int]{EqualFunc: func(a, b int) bool { return a == b }})
+	list.Append(2)
+	list.LPush(1)
+	list.Append(3)
+	list.Delete(2)
+	assert.Equal(t, 2, list.Length())
+	assert.Equal(t, 1, list.First().Val)
+	assert.Equal(t, 3, list.Last().Val)
+}
+
+func TestSiphash(t *testing.T) {
+	var k [16]byte
+	assert.Equal(t, uint64(4644417185603328019), siphash("a", &k))
+	assert.Equal(t, uint64(16350172494705860510), siphash("hello", &k))
+	assert.Equal(t, uint64(4574395652268504554), siphash("abcdefgh", &k))
+	assert.Equal(t, uint64(2296019964329922183), siphash("hello world, godis!", &k))
+	assert.Equal(t, siphash("hello world, godis!", &k), siphashNocase("Hello World, GODIS!", &k))
+
+	k[0] = 1
+	assert.NotEqual(t, uint64(16350172494705860510), siphash("hello", &k))
+}
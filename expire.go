@@ -0,0 +1,160 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+const (
+	ExpireNX = 1 << iota
+	ExpireXX
+	ExpireGT
+	ExpireLT
+)
+
+// 解析EXPIRE系列命令的NX/XX/GT/LT参数
+func parseExpireFlags(c *GodisClient, args []*Gobj) (int, bool) {
+	flags := 0
+	for _, arg := range args {
+		switch strings.ToLower(arg.StrVal()) {
+		case "nx":
+			flags |= ExpireNX
+		case "xx":
+			flags |= ExpireXX
+		case "gt":
+			flags |= ExpireGT
+		case "lt":
+			flags |= ExpireLT
+		default:
+			c.AddReplyError("Unsupported option " + arg.StrVal())
+			return 0, false
+		}
+	}
+
+	if flags&ExpireNX != 0 && flags&(ExpireXX|ExpireGT|ExpireLT) != 0 {
+		c.AddReplyError("NX and XX, GT or LT options at the same time are not compatible")
+		return 0, false
+	}
+	if flags&ExpireGT != 0 && flags&ExpireLT != 0 {
+		c.AddReplyError("GT and LT options at the same time are not compatible")
+		return 0, false
+	}
+	return flags, true
+}
+
+// expireGenericCommand 处理EXPIRE、PEXPIRE、EXPIREAT和PEXPIREAT
+// basetime为毫秒时间戳，为0时表示参数是绝对时间，unit为参数的单位换算成毫秒的倍数
+func expireGenericCommand(c *GodisClient, basetime, unit int64) {
+	key := c.args[1]
+	when, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	flags, ok := parseExpireFlags(c, c.args[3:])
+	if !ok {
+		return
+	}
+
+	// 换算成毫秒时间戳时不能溢出
+	if when > math.MaxInt64/unit || when < math.MinInt64/unit {
+		c.AddReplyError("invalid expire time in '" + strings.ToLower(c.args[0].StrVal()) + "' command")
+		return
+	}
+	when *= unit
+	if (when > 0 && basetime > math.MaxInt64-when) || (when < 0 && basetime < math.MinInt64-when) {
+		c.AddReplyError("invalid expire time in '" + strings.ToLower(c.args[0].StrVal()) + "' command")
+		return
+	}
+	when += basetime
+
+	if findKeyWrite(c.db, key) == nil {
+		c.AddReplyInt(0)
+		return
+	}
+
+	// 没有过期时间的key视为永不过期
+	current := getExpire(c.db, key)
+	if (flags&ExpireNX != 0 && current != -1) ||
+		(flags&ExpireXX != 0 && current == -1) ||
+		(flags&ExpireGT != 0 && (current == -1 || when <= current)) ||
+		(flags&ExpireLT != 0 && current != -1 && when >= current) {
+		c.AddReplyInt(0)
+		return
+	}
+
+	if when <= GetMsTime() {
+		deleteKey(c.db, key)
+	} else {
+		setExpire(c.db, key, when)
+	}
+	c.AddReplyInt(1)
+}
+
+func expireCommand(c *GodisClient) {
+	expireGenericCommand(c, GetMsTime(), 1000)
+}
+
+func pexpireCommand(c *GodisClient) {
+	expireGenericCommand(c, GetMsTime(), 1)
+}
+
+func expireatCommand(c *GodisClient) {
+	expireGenericCommand(c, 0, 1000)
+}
+
+func pexpireatCommand(c *GodisClient) {
+	expireGenericCommand(c, 0, 1)
+}
+
+// key不存在时回复-2，没有过期时间时回复-1
+func ttlGenericCommand(c *GodisClient, outputMs, absolute bool) {
+	key := c.args[1]
+	if findKeyRead(c.db, key) == nil {
+		c.AddReplyInt(-2)
+		return
+	}
+	when := getExpire(c.db, key)
+	if when == -1 {
+		c.AddReplyInt(-1)
+		return
+	}
+
+	if !absolute {
+		when -= GetMsTime()
+		if when < 0 {
+			when = 0
+		}
+		if !outputMs {
+			when = (when + 500) / 1000
+		}
+	} else if !outputMs {
+		when /= 1000
+	}
+	c.AddReplyInt(when)
+}
+
+func ttlCommand(c *GodisClient) {
+	ttlGenericCommand(c, false, false)
+}
+
+func pttlCommand(c *GodisClient) {
+	ttlGenericCommand(c, true, false)
+}
+
+func expiretimeCommand(c *GodisClient) {
+	ttlGenericCommand(c, false, true)
+}
+
+func pexpiretimeCommand(c *GodisClient) {
+	ttlGenericCommand(c, true, true)
+}
+
+func persistCommand(c *GodisClient) {
+	key := c.args[1]
+	if findKeyWrite(c.db, key) == nil || getExpire(c.db, key) == -1 {
+		c.AddReplyInt(0)
+		return
+	}
+	removeExpire(c.db, key)
+	c.AddReplyInt(1)
+}
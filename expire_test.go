@@ -0,0 +1,61 @@
+package main
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpire(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":0\r\n", execCommand(c, "expire", "k", "100"))
+	assert.Equal(t, ":-2\r\n", execCommand(c, "ttl", "k"))
+
+	execCommand(c, "set", "k", "v")
+	assert.Equal(t, ":-1\r\n", execCommand(c, "ttl", "k"))
+	assert.Equal(t, ":-1\r\n", execCommand(c, "pexpiretime", "k"))
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "expire", "k", "abc"))
+	assert.Equal(t, ":-1\r\n", execCommand(c, "ttl", "k"))
+
+	assert.Equal(t, ":1\r\n", execCommand(c, "expire", "k", "100"))
+	assert.Equal(t, ":100\r\n", execCommand(c, "ttl", "k"))
+	reply := execCommand(c, "pttl", "k")
+	pttl, _ := strconv.Atoi(reply[1 : len(reply)-2])
+	assert.True(t, pttl > 99000 && pttl <= 100000)
+
+	assert.Equal(t, ":0\r\n", execCommand(c, "expire", "k", "200", "nx"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "expire", "k", "200", "xx"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "expire", "k", "100", "gt"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "expire", "k", "50", "lt"))
+	assert.Equal(t, ":50\r\n", execCommand(c, "ttl", "k"))
+	assert.Equal(t, "-ERR NX and XX, GT or LT options at the same time are not compatible\r\n",
+		execCommand(c, "expire", "k", "50", "nx", "xx"))
+	assert.Equal(t, "-ERR GT and LT options at the same time are not compatible\r\n",
+		execCommand(c, "expire", "k", "50", "gt", "lt"))
+	assert.Equal(t, "-ERR Unsupported option foo\r\n", execCommand(c, "expire", "k", "50", "foo"))
+	assert.Equal(t, "-ERR invalid expire time in 'expire' command\r\n", execCommand(c, "expire", "k", "9223372036854775807"))
+
+	assert.Equal(t, ":1\r\n", execCommand(c, "persist", "k"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "persist", "k"))
+	assert.Equal(t, ":-1\r\n", execCommand(c, "ttl", "k"))
+	// 没有过期时间的key视为永不过期
+	assert.Equal(t, ":0\r\n", execCommand(c, "expire", "k", "50", "gt"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "pexpire", "k", "50000", "lt"))
+	assert.Equal(t, ":50\r\n", execCommand(c, "ttl", "k"))
+
+	assert.Equal(t, ":1\r\n", execCommand(c, "expire", "k", "-1"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "exists", "k"))
+}
+
+func TestExpireAt(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "set", "k", "v")
+	assert.Equal(t, ":1\r\n", execCommand(c, "expireat", "k", "33177600000"))
+	assert.Equal(t, ":33177600000\r\n", execCommand(c, "expiretime", "k"))
+	assert.Equal(t, ":33177600000000\r\n", execCommand(c, "pexpiretime", "k"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "pexpireat", "k", "33177600000123"))
+	assert.Equal(t, ":33177600000\r\n", execCommand(c, "expiretime", "k"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "pexpireat", "k", "1000"))
+	assert.Equal(t, ":-2\r\n", execCommand(c, "pttl", "k"))
+}
@@ -0,0 +1,558 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	GeoShapeCircular = iota
+	GeoShapeRectangle
+)
+
+const (
+	GeoSortNone = iota
+	GeoSortAsc
+	GeoSortDesc
+)
+
+// geoShape 搜索的范围，radius、width和height的单位由conversion换算为米
+type geoShape struct {
+	typ        int
+	lon, lat   float64
+	radius     float64
+	width      float64
+	height     float64
+	conversion float64
+}
+
+type geoPoint struct {
+	member *Gobj
+	dist   float64
+	score  float64
+	lon    float64
+	lat    float64
+}
+
+func extractUnitOrReply(c *GodisClient, unit *Gobj) (float64, bool) {
+	switch strings.ToLower(unit.StrVal()) {
+	case "m":
+		return 1, true
+	case "km":
+		return 1000, true
+	case "ft":
+		return 0.3048, true
+	case "mi":
+		return 1609.34, true
+	}
+	c.AddReplyError("unsupported unit provided. please use M, KM, FT, MI")
+	return 0, false
+}
+
+// 解析经纬度，超出范围时回复错误
+func extractLongLatOrReply(c *GodisClient, args []*Gobj) (float64, float64, bool) {
+	lon, ok := getFloatFromObjectOrReply(c, args[0])
+	if !ok {
+		return 0, 0, false
+	}
+	lat, ok := getFloatFromObjectOrReply(c, args[1])
+	if !ok {
+		return 0, 0, false
+	}
+	if lon < GeoLongMin || lon > GeoLongMax || lat < GeoLatMin || lat > GeoLatMax {
+		c.AddReplyError(fmt.Sprintf("invalid longitude,latitude pair %f,%f", lon, lat))
+		return 0, 0, false
+	}
+	return lon, lat, true
+}
+
+func geoDecodeScore(score float64) (float64, float64) {
+	return GeoHashDecodeToLongLat(GeoHashBits{Bits: uint64(score), Step: GeoStepMax})
+}
+
+func formatGeoCoord(val float64) string {
+	return strconv.FormatFloat(val, 'f', -1, 64)
+}
+
+func formatGeoDist(val float64) string {
+	return strconv.FormatFloat(val, 'f', 4, 64)
+}
+
+func geoaddCommand(c *GodisClient) {
+	idx := 2
+	flags := 0
+	ch := false
+	for ; idx < len(c.args); idx++ {
+		opt := strings.ToLower(c.args[idx].StrVal())
+		if opt == "nx" {
+			flags |= ZAddNX
+		} else if opt == "xx" {
+			flags |= ZAddXX
+		} else if opt == "ch" {
+			ch = true
+		} else {
+			break
+		}
+	}
+
+	elements := len(c.args) - idx
+	if elements == 0 || elements%3 != 0 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+	if flags&ZAddNX != 0 && flags&ZAddXX != 0 {
+		c.AddReplyError("XX and NX options at the same time are not compatible")
+		return
+	}
+
+	// 先校验所有坐标，保证出错时不会修改数据
+	scores := make([]float64, elements/3)
+	for i := range scores {
+		lon, lat, ok := extractLongLatOrReply(c, c.args[idx+i*3:])
+		if !ok {
+			return
+		}
+		hash, _ := GeoHashEncodeWGS84(lon, lat)
+		scores[i] = float64(hash.Bits)
+	}
+
+	key := c.args[1]
+	zobj := findKeyWrite(c.db, key)
+	if zobj == nil {
+		if flags&ZAddXX != 0 {
+			c.AddReplyInt(0)
+			return
+		}
+		zobj = CreateZSetObject()
+		setKey(c.db, key, zobj)
+		zobj.DecrRefCount()
+	} else if checkType(c, zobj, GZSet) {
+		return
+	}
+
+	zs := zobj.Val_.(*ZSet)
+	var added, updated int64
+	for i, score := range scores {
+		_, res := zs.Add(score, c.args[idx+i*3+2], flags)
+		if res&ZAddAdded != 0 {
+			added++
+		}
+		if res&ZAddUpdated != 0 {
+			updated++
+		}
+	}
+
+	if ch {
+		c.AddReplyInt(added + updated)
+	} else {
+		c.AddReplyInt(added)
+	}
+}
+
+func geodistCommand(c *GodisClient) {
+	conversion := 1.0
+	if len(c.args) == 5 {
+		var ok bool
+		if conversion, ok = extractUnitOrReply(c, c.args[4]); !ok {
+			return
+		}
+	} else if len(c.args) > 5 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	zs := zsetLookupRead(c, c.args[1], ReplyNull)
+	if zs == nil {
+		return
+	}
+	score1, ok1 := zs.Score(c.args[2])
+	score2, ok2 := zs.Score(c.args[3])
+	if !ok1 || !ok2 {
+		c.AddReplyStr(ReplyNull)
+		return
+	}
+
+	lon1, lat1 := geoDecodeScore(score1)
+	lon2, lat2 := geoDecodeScore(score2)
+	c.AddReplyBulkStr(formatGeoDist(GeoHashGetDistance(lon1, lat1, lon2, lat2) / conversion))
+}
+
+func geoposCommand(c *GodisClient) {
+	zobj := findKeyRead(c.db, c.args[1])
+	if zobj != nil && checkType(c, zobj, GZSet) {
+		return
+	}
+
+	c.AddReplyArrayLen(len(c.args) - 2)
+	for _, member := range c.args[2:] {
+		var score float64
+		ok := false
+		if zobj != nil {
+			score, ok = zobj.Val_.(*ZSet).Score(member)
+		}
+		if !ok {
+			c.AddReplyStr(ReplyNullArray)
+			continue
+		}
+		lon, lat := geoDecodeScore(score)
+		c.AddReplyArrayLen(2)
+		c.AddReplyBulkStr(formatGeoCoord(lon))
+		c.AddReplyBulkStr(formatGeoCoord(lat))
+	}
+}
+
+func geohashCommand(c *GodisClient) {
+	const geoAlphabet = "0123456789bcdefghjkmnpqrstuvwxyz"
+
+	zobj := findKeyRead(c.db, c.args[1])
+	if zobj != nil && checkType(c, zobj, GZSet) {
+		return
+	}
+
+	c.AddReplyArrayLen(len(c.args) - 2)
+	for _, member := range c.args[2:] {
+		var score float64
+		ok := false
+		if zobj != nil {
+			score, ok = zobj.Val_.(*ZSet).Score(member)
+		}
+		if !ok {
+			c.AddReplyStr(ReplyNull)
+			continue
+		}
+
+		// 标准的geohash使用[-90, 90]的纬度范围，需要重新编码
+		lon, lat := geoDecodeScore(score)
+		hash, _ := GeoHashEncode(geoLongRange, GeoHashRange{Min: -90, Max: 90}, lon, lat, GeoStepMax)
+		buf := make([]byte, 11)
+		for i := range buf {
+			idx := 0
+			// 只有52位，最后一个字符固定为0
+			if i < 10 {
+				idx = int((hash.Bits >> (52 - uint((i+1)*5))) & 0x1f)
+			}
+			buf[i] = geoAlphabet[idx]
+		}
+		c.AddReplyBulkStr(string(buf))
+	}
+}
+
+// 计算覆盖搜索范围的矩形边界：minLon, minLat, maxLon, maxLat
+func geohashBoundingBox(shape *geoShape) [4]float64 {
+	height := shape.conversion * shape.radius
+	width := height
+	if shape.typ == GeoShapeRectangle {
+		height = shape.conversion * shape.height / 2
+		width = shape.conversion * shape.width / 2
+	}
+
+	latDelta := radDeg(height / EarthRadiusInMeter)
+	longDeltaTop := radDeg(width / EarthRadiusInMeter / math.Cos(degRad(shape.lat+latDelta)))
+	longDeltaBottom := radDeg(width / EarthRadiusInMeter / math.Cos(degRad(shape.lat-latDelta)))
+	// 南北半球的方向相反，使用不同的点作为经度的边界
+	if shape.lat < 0 {
+		return [4]float64{shape.lon - longDeltaBottom, shape.lat - latDelta, shape.lon + longDeltaBottom, shape.lat + latDelta}
+	}
+	return [4]float64{shape.lon - longDeltaTop, shape.lat - latDelta, shape.lon + longDeltaTop, shape.lat + latDelta}
+}
+
+// 获取覆盖搜索范围的中心区域以及周围的8个区域
+func geohashAreasByShape(shape *geoShape) []GeoHashBits {
+	radius := shape.radius * shape.conversion
+	if shape.typ == GeoShapeRectangle {
+		radius = math.Sqrt(math.Pow(shape.width*shape.conversion/2, 2) + math.Pow(shape.height*shape.conversion/2, 2))
+	}
+	bounds := geohashBoundingBox(shape)
+	steps := geohashEstimateStepsByRadius(radius, shape.lat)
+
+	hash, _ := GeoHashEncode(geoLongRange, geoLatRange, shape.lon, shape.lat, steps)
+	neighbors := GeoHashGetNeighbors(hash)
+
+	// 搜索范围靠近区域边缘时，估算的精度可能不够，需要降低一级
+	north := GeoHashDecode(geoLongRange, geoLatRange, neighbors.North)
+	south := GeoHashDecode(geoLongRange, geoLatRange, neighbors.South)
+	east := GeoHashDecode(geoLongRange, geoLatRange, neighbors.East)
+	west := GeoHashDecode(geoLongRange, geoLatRange, neighbors.West)
+	if steps > 1 && (north.Latitude.Max < bounds[3] || south.Latitude.Min > bounds[1] ||
+		east.Longitude.Max < bounds[2] || west.Longitude.Min > bounds[0]) {
+		steps--
+		hash, _ = GeoHashEncode(geoLongRange, geoLatRange, shape.lon, shape.lat, steps)
+		neighbors = GeoHashGetNeighbors(hash)
+	}
+
+	candidates := []GeoHashBits{hash, neighbors.North, neighbors.South, neighbors.East, neighbors.West,
+		neighbors.NorthEast, neighbors.NorthWest, neighbors.SouthEast, neighbors.SouthWest}
+	var areas []GeoHashBits
+	for _, area := range candidates {
+		dup := false
+		for _, a := range areas {
+			if a == area {
+				dup = true
+				break
+			}
+		}
+		if !dup {
+			areas = append(areas, area)
+		}
+	}
+	return areas
+}
+
+// 判断点是否在搜索范围内，返回与中心点的距离（米）
+func geoWithinShape(shape *geoShape, lon, lat float64) (float64, bool) {
+	if shape.typ == GeoShapeCircular {
+		dist := GeoHashGetDistance(shape.lon, shape.lat, lon, lat)
+		return dist, dist <= shape.radius*shape.conversion
+	}
+
+	// 先计算开销较小的纬度距离
+	if geohashGetLatDistance(lat, shape.lat) > shape.height*shape.conversion/2 {
+		return 0, false
+	}
+	if GeoHashGetDistance(lon, lat, shape.lon, lat) > shape.width*shape.conversion/2 {
+		return 0, false
+	}
+	return GeoHashGetDistance(shape.lon, shape.lat, lon, lat), true
+}
+
+// 在区域对应的score范围内查找满足条件的成员，limit>0时找到limit个就停止
+func geoMembersOfShape(zs *ZSet, shape *geoShape, limit int) []geoPoint {
+	var points []geoPoint
+	for _, area := range geohashAreasByShape(shape) {
+		if area.Bits == 0 && area.Step == 0 {
+			continue
+		}
+		shift := uint(GeoStepMax*2) - area.Step*2
+		r := &ZRangeSpec{
+			Min:   float64(area.Bits << shift),
+			Max:   float64((area.Bits + 1) << shift),
+			MaxEx: true,
+		}
+		for _, item := range zs.RangeByScore(r, false, 0, -1) {
+			lon, lat := geoDecodeScore(item.score)
+			dist, ok := geoWithinShape(shape, lon, lat)
+			if !ok {
+				continue
+			}
+			points = append(points, geoPoint{member: item.member, dist: dist, score: item.score, lon: lon, lat: lat})
+			if limit > 0 && len(points) >= limit {
+				return points
+			}
+		}
+	}
+	return points
+}
+
+// geosearchGenericCommand 处理GEOSEARCH和GEOSEARCHSTORE，dst为nil时直接回复结果
+func geosearchGenericCommand(c *GodisClient, src, dst *Gobj, optIdx int) {
+	var shape geoShape
+	shape.typ = -1
+	var fromMember *Gobj
+	fromLonLat := false
+	withDist, withHash, withCoord, storeDist, any := false, false, false, false, false
+	sortType := GeoSortNone
+	var count int64
+
+	for i := optIdx; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		remaining := len(c.args) - i - 1
+		switch {
+		case opt == "withdist" && dst == nil:
+			withDist = true
+		case opt == "withhash" && dst == nil:
+			withHash = true
+		case opt == "withcoord" && dst == nil:
+			withCoord = true
+		case opt == "storedist" && dst != nil:
+			storeDist = true
+		case opt == "any":
+			any = true
+		case opt == "asc":
+			sortType = GeoSortAsc
+		case opt == "desc":
+			sortType = GeoSortDesc
+		case opt == "count" && remaining >= 1:
+			var ok bool
+			if count, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if count <= 0 {
+				c.AddReplyError("COUNT must be > 0")
+				return
+			}
+			i++
+		case opt == "frommember" && remaining >= 1:
+			if fromLonLat || fromMember != nil {
+				c.AddReplyError("exactly one of FROMMEMBER or FROMLONLAT can be specified for " + c.args[0].StrVal())
+				return
+			}
+			fromMember = c.args[i+1]
+			i++
+		case opt == "fromlonlat" && remaining >= 2:
+			if fromLonLat || fromMember != nil {
+				c.AddReplyError("exactly one of FROMMEMBER or FROMLONLAT can be specified for " + c.args[0].StrVal())
+				return
+			}
+			var ok bool
+			if shape.lon, shape.lat, ok = extractLongLatOrReply(c, c.args[i+1:]); !ok {
+				return
+			}
+			fromLonLat = true
+			i += 2
+		case opt == "byradius" && remaining >= 2:
+			if shape.typ != -1 {
+				c.AddReplyError("exactly one of BYRADIUS and BYBOX can be specified for " + c.args[0].StrVal())
+				return
+			}
+			var ok bool
+			if shape.radius, ok = getFloatFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if shape.radius < 0 {
+				c.AddReplyError("radius cannot be negative")
+				return
+			}
+			if shape.conversion, ok = extractUnitOrReply(c, c.args[i+2]); !ok {
+				return
+			}
+			shape.typ = GeoShapeCircular
+			i += 2
+		case opt == "bybox" && remaining >= 3:
+			if shape.typ != -1 {
+				c.AddReplyError("exactly one of BYRADIUS and BYBOX can be specified for " + c.args[0].StrVal())
+				return
+			}
+			var ok bool
+			if shape.width, ok = getFloatFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if shape.height, ok = getFloatFromObjectOrReply(c, c.args[i+2]); !ok {
+				return
+			}
+			if shape.width < 0 || shape.height < 0 {
+				c.AddReplyError("height or width cannot be negative")
+				return
+			}
+			if shape.conversion, ok = extractUnitOrReply(c, c.args[i+3]); !ok {
+				return
+			}
+			shape.typ = GeoShapeRectangle
+			i += 3
+		default:
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	if !fromLonLat && fromMember == nil {
+		c.AddReplyError("exactly one of FROMMEMBER or FROMLONLAT can be specified for " + c.args[0].StrVal())
+		return
+	}
+	if shape.typ == -1 {
+		c.AddReplyError("exactly one of BYRADIUS and BYBOX can be specified for " + c.args[0].StrVal())
+		return
+	}
+	if any && count == 0 {
+		c.AddReplyError("the ANY argument requires COUNT argument")
+		return
+	}
+
+	zobj := findKeyRead(c.db, src)
+	if zobj == nil {
+		if dst != nil {
+			deleteKey(c.db, dst)
+			c.AddReplyInt(0)
+		} else {
+			c.AddReplyStr(ReplyEmptyArray)
+		}
+		return
+	}
+	if checkType(c, zobj, GZSet) {
+		return
+	}
+
+	zs := zobj.Val_.(*ZSet)
+	if fromMember != nil {
+		score, ok := zs.Score(fromMember)
+		if !ok {
+			c.AddReplyError("could not decode requested zset member")
+			return
+		}
+		shape.lon, shape.lat = geoDecodeScore(score)
+	}
+
+	// 指定COUNT但没有指定ANY时，需要排序后再截取
+	if count > 0 && !any && sortType == GeoSortNone {
+		sortType = GeoSortAsc
+	}
+	limit := 0
+	if any {
+		limit = int(count)
+	}
+	points := geoMembersOfShape(zs, &shape, limit)
+	if sortType == GeoSortAsc {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].dist < points[j].dist })
+	} else if sortType == GeoSortDesc {
+		sort.SliceStable(points, func(i, j int) bool { return points[i].dist > points[j].dist })
+	}
+	if count > 0 && int64(len(points)) > count {
+		points = points[:count]
+	}
+
+	if dst != nil {
+		result := ZSetCreate()
+		for _, p := range points {
+			score := p.score
+			if storeDist {
+				score = p.dist / shape.conversion
+			}
+			result.Add(score, p.member, 0)
+		}
+		deleteKey(c.db, dst)
+		if result.Length() > 0 {
+			o := CreateObject(GZSet, result)
+			setKey(c.db, dst, o)
+			o.DecrRefCount()
+		}
+		c.AddReplyInt(result.Length())
+		return
+	}
+
+	options := 0
+	for _, with := range []bool{withDist, withHash, withCoord} {
+		if with {
+			options++
+		}
+	}
+	c.AddReplyArrayLen(len(points))
+	for _, p := range points {
+		if options == 0 {
+			c.AddReplyBulk(p.member)
+			continue
+		}
+		c.AddReplyArrayLen(options + 1)
+		c.AddReplyBulk(p.member)
+		if withDist {
+			c.AddReplyBulkStr(formatGeoDist(p.dist / shape.conversion))
+		}
+		if withHash {
+			c.AddReplyInt(int64(p.score))
+		}
+		if withCoord {
+			c.AddReplyArrayLen(2)
+			c.AddReplyBulkStr(formatGeoCoord(p.lon))
+			c.AddReplyBulkStr(formatGeoCoord(p.lat))
+		}
+	}
+}
+
+func geosearchCommand(c *GodisClient) {
+	geosearchGenericCommand(c, c.args[1], nil, 2)
+}
+
+func geosearchstoreCommand(c *GodisClient) {
+	geosearchGenericCommand(c, c.args[2], c.args[1], 3)
+}
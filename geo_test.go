@@ -0,0 +1,56 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoHash(t *testing.T) {
+	hash, ok := GeoHashEncodeWGS84(13.361389, 38.115556)
+	assert.True(t, ok)
+	lon, lat := GeoHashDecodeToLongLat(hash)
+	assert.InDelta(t, 13.361389, lon, 0.00001)
+	assert.InDelta(t, 38.115556, lat, 0.00001)
+
+	_, ok = GeoHashEncodeWGS84(13.361389, 86)
+	assert.False(t, ok)
+
+	assert.InDelta(t, 166274.2578, GeoHashGetDistance(13.361389, 38.115556, 15.087269, 37.502669), 0.001)
+}
+
+func TestGeoCommands(t *testing.T) {
+	c := createTestClient(t)
+
+	assert.Equal(t, ":2\r\n", execCommand(c, "geoadd", "Sicily", "13.361389", "38.115556", "Palermo", "15.087269", "37.502669", "Catania"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "geoadd", "Sicily", "13.361389", "38.115556", "Palermo"))
+	assert.Equal(t, "-ERR invalid longitude,latitude pair 200.000000,38.000000\r\n", execCommand(c, "geoadd", "Sicily", "200", "38", "x"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "geoadd", "Sicily", "13", "38", "a", "b"))
+
+	assert.Equal(t, "$11\r\n166274.1516\r\n", execCommand(c, "geodist", "Sicily", "Palermo", "Catania"))
+	assert.Equal(t, "$8\r\n166.2742\r\n", execCommand(c, "geodist", "Sicily", "Palermo", "Catania", "km"))
+	assert.Equal(t, ReplyNull, execCommand(c, "geodist", "Sicily", "Palermo", "Rome"))
+
+	assert.Equal(t, "*3\r\n$11\r\nsqc8b49rny0\r\n$11\r\nsqdtr74hyu0\r\n$-1\r\n", execCommand(c, "geohash", "Sicily", "Palermo", "Catania", "Rome"))
+
+	reply := execCommand(c, "geopos", "Sicily", "Palermo", "Rome")
+	assert.Equal(t, "*2\r\n*2\r\n$18\r\n13.361389338970184\r\n$16\r\n38.1155563954963\r\n*-1\r\n", reply)
+
+	assert.Equal(t, "*2\r\n*2\r\n$7\r\nCatania\r\n$7\r\n56.4413\r\n*2\r\n$7\r\nPalermo\r\n$8\r\n190.4424\r\n",
+		execCommand(c, "geosearch", "Sicily", "fromlonlat", "15", "37", "byradius", "200", "km", "asc", "withdist"))
+	assert.Equal(t, "*1\r\n$7\r\nCatania\r\n",
+		execCommand(c, "geosearch", "Sicily", "fromlonlat", "15", "37", "byradius", "100", "km"))
+	assert.Equal(t, "*1\r\n$7\r\nCatania\r\n",
+		execCommand(c, "geosearch", "Sicily", "fromlonlat", "15", "37", "bybox", "400", "400", "km", "count", "1"))
+	assert.Equal(t, "*2\r\n$7\r\nPalermo\r\n$7\r\nCatania\r\n",
+		execCommand(c, "geosearch", "Sicily", "frommember", "Palermo", "byradius", "200", "km", "asc"))
+	assert.Equal(t, "-ERR could not decode requested zset member\r\n",
+		execCommand(c, "geosearch", "Sicily", "frommember", "Rome", "byradius", "200", "km"))
+	assert.Equal(t, "-ERR the ANY argument requires COUNT argument\r\n",
+		execCommand(c, "geosearch", "Sicily", "fromlonlat", "15", "37", "byradius", "200", "km", "any"))
+
+	assert.Equal(t, ":2\r\n", execCommand(c, "geosearchstore", "dst", "Sicily", "fromlonlat", "15", "37", "byradius", "200", "km", "storedist"))
+	assert.Equal(t, "*2\r\n$7\r\nCatania\r\n$7\r\nPalermo\r\n", execCommand(c, "zrange", "dst", "0", "-1"))
+	assert.Equal(t, "*1\r\n$7\r\nCatania\r\n", execCommand(c, "zrangebyscore", "dst", "56", "57"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "geosearchstore", "dst", "Sicily", "fromlonlat", "15", "37", "byradius", "200", "km", "withdist"))
+}
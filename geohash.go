@@ -0,0 +1,217 @@
+package main
+
+import "math"
+
+// geohash的实现与redis保持一致，经纬度各使用26位，交错后得到52位的score
+const (
+	GeoStepMax         = 26
+	GeoLatMin          = -85.05112878
+	GeoLatMax          = 85.05112878
+	GeoLongMin         = -180.0
+	GeoLongMax         = 180.0
+	EarthRadiusInMeter = 6372797.560856
+	MercatorMax        = 20037726.37
+)
+
+type GeoHashRange struct {
+	Min, Max float64
+}
+
+type GeoHashBits struct {
+	Bits uint64
+	Step uint
+}
+
+type GeoHashArea struct {
+	Hash      GeoHashBits
+	Longitude GeoHashRange
+	Latitude  GeoHashRange
+}
+
+type GeoHashNeighbors struct {
+	North, East, West, South                   GeoHashBits
+	NorthEast, SouthEast, NorthWest, SouthWest GeoHashBits
+}
+
+var (
+	geoLongRange = GeoHashRange{Min: GeoLongMin, Max: GeoLongMax}
+	geoLatRange  = GeoHashRange{Min: GeoLatMin, Max: GeoLatMax}
+)
+
+// 将x放在偶数位，y放在奇数位
+func interleave64(xlo, ylo uint32) uint64 {
+	B := [...]uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F,
+		0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF}
+	S := [...]uint{1, 2, 4, 8, 16}
+
+	x, y := uint64(xlo), uint64(ylo)
+	for i := 4; i >= 0; i-- {
+		x = (x | (x << S[i])) & B[i]
+		y = (y | (y << S[i])) & B[i]
+	}
+	return x | (y << 1)
+}
+
+// interleave64的逆操作，偶数位放在低32位，奇数位放在高32位
+func deinterleave64(interleaved uint64) uint64 {
+	B := [...]uint64{0x5555555555555555, 0x3333333333333333, 0x0F0F0F0F0F0F0F0F,
+		0x00FF00FF00FF00FF, 0x0000FFFF0000FFFF, 0x00000000FFFFFFFF}
+	S := [...]uint{0, 1, 2, 4, 8, 16}
+
+	x, y := interleaved, interleaved>>1
+	for i := 0; i < 6; i++ {
+		x = (x | (x >> S[i])) & B[i]
+		y = (y | (y >> S[i])) & B[i]
+	}
+	return x | (y << 32)
+}
+
+func GeoHashEncode(longRange, latRange GeoHashRange, longitude, latitude float64, step uint) (GeoHashBits, bool) {
+	if step > 32 || step == 0 || longitude < GeoLongMin || longitude > GeoLongMax ||
+		latitude < GeoLatMin || latitude > GeoLatMax ||
+		latitude < latRange.Min || latitude > latRange.Max ||
+		longitude < longRange.Min || longitude > longRange.Max {
+		return GeoHashBits{}, false
+	}
+
+	latOffset := (latitude - latRange.Min) / (latRange.Max - latRange.Min)
+	longOffset := (longitude - longRange.Min) / (longRange.Max - longRange.Min)
+	latOffset *= float64(uint64(1) << step)
+	longOffset *= float64(uint64(1) << step)
+	return GeoHashBits{Bits: interleave64(uint32(latOffset), uint32(longOffset)), Step: step}, true
+}
+
+func GeoHashEncodeWGS84(longitude, latitude float64) (GeoHashBits, bool) {
+	return GeoHashEncode(geoLongRange, geoLatRange, longitude, latitude, GeoStepMax)
+}
+
+func GeoHashDecode(longRange, latRange GeoHashRange, hash GeoHashBits) GeoHashArea {
+	area := GeoHashArea{Hash: hash}
+	sep := deinterleave64(hash.Bits)
+	latScale := latRange.Max - latRange.Min
+	longScale := longRange.Max - longRange.Min
+	ilato := uint32(sep)
+	ilono := uint32(sep >> 32)
+	div := float64(uint64(1) << hash.Step)
+
+	area.Latitude.Min = latRange.Min + (float64(ilato)/div)*latScale
+	area.Latitude.Max = latRange.Min + ((float64(ilato)+1)/div)*latScale
+	area.Longitude.Min = longRange.Min + (float64(ilono)/div)*longScale
+	area.Longitude.Max = longRange.Min + ((float64(ilono)+1)/div)*longScale
+	return area
+}
+
+// GeoHashDecodeToLongLat 返回区域中心点的经纬度
+func GeoHashDecodeToLongLat(hash GeoHashBits) (float64, float64) {
+	area := GeoHashDecode(geoLongRange, geoLatRange, hash)
+	lon := math.Min(math.Max((area.Longitude.Min+area.Longitude.Max)/2, GeoLongMin), GeoLongMax)
+	lat := math.Min(math.Max((area.Latitude.Min+area.Latitude.Max)/2, GeoLatMin), GeoLatMax)
+	return lon, lat
+}
+
+func geohashMoveX(hash *GeoHashBits, d int) {
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0x5555555555555555) >> (64 - hash.Step*2)
+	if d > 0 {
+		x = x + (zz + 1)
+	} else {
+		x = x | zz
+		x = x - (zz + 1)
+	}
+	x &= uint64(0xaaaaaaaaaaaaaaaa) >> (64 - hash.Step*2)
+	hash.Bits = x | y
+}
+
+func geohashMoveY(hash *GeoHashBits, d int) {
+	x := hash.Bits & 0xaaaaaaaaaaaaaaaa
+	y := hash.Bits & 0x5555555555555555
+	zz := uint64(0xaaaaaaaaaaaaaaaa) >> (64 - hash.Step*2)
+	if d > 0 {
+		y = y + (zz + 1)
+	} else {
+		y = y | zz
+		y = y - (zz + 1)
+	}
+	y &= uint64(0x5555555555555555) >> (64 - hash.Step*2)
+	hash.Bits = x | y
+}
+
+func geohashMove(hash GeoHashBits, dx, dy int) GeoHashBits {
+	if dx != 0 {
+		geohashMoveX(&hash, dx)
+	}
+	if dy != 0 {
+		geohashMoveY(&hash, dy)
+	}
+	return hash
+}
+
+func GeoHashGetNeighbors(hash GeoHashBits) GeoHashNeighbors {
+	return GeoHashNeighbors{
+		North:     geohashMove(hash, 0, 1),
+		East:      geohashMove(hash, 1, 0),
+		West:      geohashMove(hash, -1, 0),
+		South:     geohashMove(hash, 0, -1),
+		NorthEast: geohashMove(hash, 1, 1),
+		SouthEast: geohashMove(hash, 1, -1),
+		NorthWest: geohashMove(hash, -1, 1),
+		SouthWest: geohashMove(hash, -1, -1),
+	}
+}
+
+func degRad(deg float64) float64 {
+	return deg * math.Pi / 180
+}
+
+func radDeg(rad float64) float64 {
+	return rad * 180 / math.Pi
+}
+
+func geohashGetLatDistance(lat1, lat2 float64) float64 {
+	return EarthRadiusInMeter * math.Abs(degRad(lat2)-degRad(lat1))
+}
+
+// GeoHashGetDistance 使用haversine公式计算两点之间的距离，单位为米
+func GeoHashGetDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lon1r := degRad(lon1)
+	lon2r := degRad(lon2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	if v == 0 {
+		return geohashGetLatDistance(lat1, lat2)
+	}
+	lat1r := degRad(lat1)
+	lat2r := degRad(lat2)
+	u := math.Sin((lat2r - lat1r) / 2)
+	a := u*u + math.Cos(lat1r)*math.Cos(lat2r)*v*v
+	return 2.0 * EarthRadiusInMeter * math.Asin(math.Sqrt(a))
+}
+
+// 根据搜索半径估算需要的精度，半径越大精度越低
+func geohashEstimateStepsByRadius(rangeMeters, lat float64) uint {
+	if rangeMeters == 0 {
+		return GeoStepMax
+	}
+	step := 1
+	for rangeMeters < MercatorMax {
+		rangeMeters *= 2
+		step++
+	}
+	step -= 2
+
+	// 靠近两极时需要更大的范围
+	if lat > 66 || lat < -66 {
+		step--
+		if lat > 80 || lat < -80 {
+			step--
+		}
+	}
+
+	if step < 1 {
+		step = 1
+	}
+	if step > GeoStepMax {
+		step = GeoStepMax
+	}
+	return uint(step)
+}
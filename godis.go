@@ -1,13 +1,14 @@
 package main
 
 import (
+	crand "crypto/rand"
 	"errors"
 	"fmt"
 	"hash/fnv"
 	"log"
+	"math"
 	"strconv"
 	"strings"
-	"time"
 )
 
 type CmdType int
@@ -18,6 +19,19 @@ const (
 	CommonBulk   CmdType = 0x02
 )
 
+const (
+	ReplyOK         = "+OK\r\n"
+	ReplyNull       = "$-1\r\n"
+	ReplyNullArray  = "*-1\r\n"
+	ReplyEmptyArray = "*0\r\n"
+	ReplyWrongType  = "-WRONGTYPE Operation against a key holding the wrong kind of value\r\n"
+	ReplySyntaxErr  = "-ERR syntax error\r\n"
+	ReplyNotInteger = "-ERR value is not an integer or out of range\r\n"
+	ReplyNoSuchKey  = "-ERR no such key\r\n"
+	ReplyOutOfRange = "-ERR index out of range\r\n"
+	ReplyNotFloat   = "-ERR value is not a valid float\r\n"
+)
+
 const (
 	GodisIOBuf     int = 1024 * 16
 	GodisMaxBulk   int = 1024 * 4
@@ -25,93 +39,283 @@ const (
 )
 
 type GodisServer struct {
-	fd      int
-	port    int
-	db      *GodisDB
-	clients map[int]*GodisClient
-	aeLoop  *AeLoop
+	fd        int
+	port      int
+	dbs       []*GodisDB
+	clients   map[int]*GodisClient
+	commands  *Dict[string, *GodisCommand]
+	aeLoop    *AeLoop
+	readyKeys []readyKey // 有阻塞客户端等待并且被写入的key
+
+	unblockedClients []*GodisClient // 被唤醒后还有未处理命令的客户端
+
+	// 编码转换的阈值，含义见Config
+	listMaxListpackSize    int
+	listCompressDepth      int
+	hashMaxListpackEntries int
+	hashMaxListpackValue   int
+	setMaxIntsetEntries    int
+	zsetMaxListpackEntries int
+	zsetMaxListpackValue   int
 }
 
 var server GodisServer
 
-func expireIfNeed(key *Gobj) {
-	entry := server.db.expire.Find(key)
+func expireIfNeed(db *GodisDB, key *Gobj) {
+	entry := db.expire.Find(key)
 	if entry == nil {
 		return
 	}
 
-	when := entry.Val.IntVal()
-	if when > GetMsTime() {
+	if entry.Val > GetMsTime() {
 		return
 	}
-	server.db.expire.Delete(key)
-	server.db.data.Delete(key)
+	db.expire.Delete(key)
+	db.data.Delete(key)
+}
+
+func findKeyRead(db *GodisDB, key *Gobj) *Gobj {
+	expireIfNeed(db, key)
+	return db.data.Get(key)
+}
+
+func findKeyWrite(db *GodisDB, key *Gobj) *Gobj {
+	expireIfNeed(db, key)
+	return db.data.Get(key)
+}
+
+// 写入key，覆盖原有的值
+func setKey(db *GodisDB, key, val *Gobj) {
+	db.data.Set(key, tryObjectEncoding(val))
+	signalKeyAsReady(db, key)
+}
+
+// 设置key的过期时间，when为毫秒时间戳
+func setExpire(db *GodisDB, key *Gobj, when int64) {
+	db.expire.Set(key, when)
+}
+
+// 获取key的过期时间，没有设置时返回-1
+func getExpire(db *GodisDB, key *Gobj) int64 {
+	entry := db.expire.Find(key)
+	if entry == nil {
+		return -1
+	}
+	return entry.Val
+}
+
+func removeExpire(db *GodisDB, key *Gobj) {
+	db.expire.Delete(key)
 }
 
-func findKeyRead(key *Gobj) *Gobj {
-	expireIfNeed(key)
-	return server.db.data.Get(key)
+func deleteKey(db *GodisDB, key *Gobj) {
+	db.expire.Delete(key)
+	db.data.Delete(key)
 }
 
-func getCommand(c *GodisClient) {
-	key := c.args[1]
-	val := findKeyRead(key)
-	if val == nil {
-		c.AddReplyStr("$-1\r\n")
-	} else if val.Type_ != GSTR {
-		c.AddReplyStr("-ERR: wrong type\r\n")
-	} else {
-		str := val.StrVal()
-		c.AddReplyStr(fmt.Sprintf("$%d%v\r\n", len(str), str))
+// 类型不匹配时回复WRONGTYPE并返回true
+func checkType(c *GodisClient, o *Gobj, typ GType) bool {
+	if o.Type_ != typ {
+		c.AddReplyStr(ReplyWrongType)
+		return true
 	}
+	return false
 }
 
-func setCommand(c *GodisClient) {
-	key := c.args[1]
-	val := c.args[2]
-	if val.Type_ != GSTR {
-		c.AddReplyStr("-ERR: wrong type\r\n")
+func getIntFromObjectOrReply(c *GodisClient, o *Gobj) (int64, bool) {
+	val, ok := o.TryIntVal()
+	if !ok {
+		c.AddReplyStr(ReplyNotInteger)
 	}
-	server.db.data.Set(key, val)
-	server.db.expire.Delete(key)
-	c.AddReplyStr("+OK\r\n")
+	return val, ok
 }
 
-func expireCommand(client *GodisClient) {
-	key := client.args[1]
-	val := client.args[2]
-	if val.Type_ != GSTR {
-		client.AddReplyStr("-ERR: wrong type\r\n")
+// 解析float参数，NaN被视为非法值
+func getFloatFromObjectOrReply(c *GodisClient, o *Gobj) (float64, bool) {
+	val, err := strconv.ParseFloat(o.StrVal(), 64)
+	if err != nil || math.IsNaN(val) {
+		c.AddReplyStr(ReplyNotFloat)
+		return 0, false
 	}
-	expire := GetMsTime() + (val.IntVal() * 1000)
-	expireObj := CreateFromInt(expire)
-	server.db.expire.Set(key, expireObj)
-	expireObj.DecrRefCount()
-	client.AddReplyStr("+OK\r\n")
+	return val, true
 }
 
+// arity为负数时表示参数个数至少为-arity
 var cmdTable []GodisCommand = []GodisCommand{
 	{"get", getCommand, 2},
-	{"set", setCommand, 3},
-	{"expire", expireCommand, 3},
+	{"set", setCommand, -3},
+	{"mget", mgetCommand, -2},
+	{"mset", msetCommand, -3},
+	{"msetnx", msetnxCommand, -3},
+	{"setnx", setnxCommand, 3},
+	{"setex", setexCommand, 4},
+	{"psetex", psetexCommand, 4},
+	{"getset", getsetCommand, 3},
+	{"getdel", getdelCommand, 2},
+	{"getex", getexCommand, -2},
+	{"append", appendCommand, 3},
+	{"strlen", strlenCommand, 2},
+	{"getrange", getrangeCommand, 4},
+	{"setrange", setrangeCommand, 4},
+	{"setbit", setbitCommand, 4},
+	{"getbit", getbitCommand, 3},
+	{"bitcount", bitcountCommand, -2},
+	{"bitpos", bitposCommand, -3},
+	{"bitop", bitopCommand, -4},
+	{"bitfield", bitfieldCommand, -2},
+	{"pfadd", pfaddCommand, -2},
+	{"pfcount", pfcountCommand, -2},
+	{"pfmerge", pfmergeCommand, -2},
+	{"incr", incrCommand, 2},
+	{"decr", decrCommand, 2},
+	{"incrby", incrbyCommand, 3},
+	{"decrby", decrbyCommand, 3},
+	{"incrbyfloat", incrbyfloatCommand, 3},
+	{"expire", expireCommand, -3},
+	{"pexpire", pexpireCommand, -3},
+	{"expireat", expireatCommand, -3},
+	{"pexpireat", pexpireatCommand, -3},
+	{"ttl", ttlCommand, 2},
+	{"pttl", pttlCommand, 2},
+	{"expiretime", expiretimeCommand, 2},
+	{"pexpiretime", pexpiretimeCommand, 2},
+	{"persist", persistCommand, 2},
+	{"lpush", lpushCommand, -3},
+	{"rpush", rpushCommand, -3},
+	{"lpop", lpopCommand, -2},
+	{"rpop", rpopCommand, -2},
+	{"lrange", lrangeCommand, 4},
+	{"llen", llenCommand, 2},
+	{"lindex", lindexCommand, 3},
+	{"lset", lsetCommand, 4},
+	{"ltrim", ltrimCommand, 4},
+	{"lrem", lremCommand, 4},
+	{"linsert", linsertCommand, 5},
+	{"lmove", lmoveCommand, 5},
+	{"blpop", blpopCommand, -3},
+	{"brpop", brpopCommand, -3},
+	{"blmove", blmoveCommand, 6},
+	{"hset", hsetCommand, -4},
+	{"hget", hgetCommand, 3},
+	{"hdel", hdelCommand, -3},
+	{"hgetall", hgetallCommand, 2},
+	{"hincrby", hincrbyCommand, 4},
+	{"hexists", hexistsCommand, 3},
+	{"hlen", hlenCommand, 2},
+	{"hkeys", hkeysCommand, 2},
+	{"hvals", hvalsCommand, 2},
+	{"hmget", hmgetCommand, -3},
+	{"sadd", saddCommand, -3},
+	{"srem", sremCommand, -3},
+	{"smembers", smembersCommand, 2},
+	{"sismember", sismemberCommand, 3},
+	{"scard", scardCommand, 2},
+	{"spop", spopCommand, -2},
+	{"srandmember", srandmemberCommand, -2},
+	{"sinter", sinterCommand, -2},
+	{"sinterstore", sinterstoreCommand, -3},
+	{"sunion", sunionCommand, -2},
+	{"sunionstore", sunionstoreCommand, -3},
+	{"sdiff", sdiffCommand, -2},
+	{"sdiffstore", sdiffstoreCommand, -3},
+	{"zadd", zaddCommand, -4},
+	{"zincrby", zincrbyCommand, 4},
+	{"zrem", zremCommand, -3},
+	{"zcard", zcardCommand, 2},
+	{"zscore", zscoreCommand, 3},
+	{"zrank", zrankCommand, 3},
+	{"zrevrank", zrevrankCommand, 3},
+	{"zcount", zcountCommand, 4},
+	{"zrange", zrangeCommand, -4},
+	{"zrevrange", zrevrangeCommand, -4},
+	{"zrangebyscore", zrangebyscoreCommand, -4},
+	{"zrevrangebyscore", zrevrangebyscoreCommand, -4},
+	{"zrangebylex", zrangebylexCommand, -4},
+	{"zrevrangebylex", zrevrangebylexCommand, -4},
+	{"zremrangebyrank", zremrangebyrankCommand, 4},
+	{"zremrangebyscore", zremrangebyscoreCommand, 4},
+	{"zpopmin", zpopminCommand, -2},
+	{"zpopmax", zpopmaxCommand, -2},
+	{"bzpopmin", bzpopminCommand, -3},
+	{"bzpopmax", bzpopmaxCommand, -3},
+	{"xadd", xaddCommand, -5},
+	{"xlen", xlenCommand, 2},
+	{"xrange", xrangeCommand, -4},
+	{"xrevrange", xrevrangeCommand, -4},
+	{"xdel", xdelCommand, -3},
+	{"xtrim", xtrimCommand, -4},
+	{"xread", xreadCommand, -4},
+	{"xreadgroup", xreadgroupCommand, -7},
+	{"xgroup", xgroupCommand, -2},
+	{"xack", xackCommand, -4},
+	{"xpending", xpendingCommand, -3},
+	{"xclaim", xclaimCommand, -6},
+	{"xautoclaim", xautoclaimCommand, -6},
+	{"geoadd", geoaddCommand, -5},
+	{"geodist", geodistCommand, -4},
+	{"geopos", geoposCommand, -2},
+	{"geohash", geohashCommand, -2},
+	{"geosearch", geosearchCommand, -7},
+	{"geosearchstore", geosearchstoreCommand, -8},
+	{"del", delCommand, -2},
+	{"unlink", unlinkCommand, -2},
+	{"exists", existsCommand, -2},
+	{"type", typeCommand, 2},
+	{"object", objectCommand, -2},
+	{"rename", renameCommand, 3},
+	{"renamenx", renamenxCommand, 3},
+	{"copy", copyCommand, -3},
+	{"touch", touchCommand, -2},
+	{"randomkey", randomkeyCommand, 1},
+	{"dbsize", dbsizeCommand, 1},
+	{"keys", keysCommand, 2},
+	{"select", selectCommand, 2},
+	{"move", moveCommand, 3},
+	{"swapdb", swapdbCommand, 3},
+	{"flushdb", flushdbCommand, -1},
+	{"flushall", flushallCommand, -1},
+	{"scan", scanCommand, -2},
+	{"hscan", hscanCommand, -3},
+	{"sscan", sscanCommand, -3},
+	{"zscan", zscanCommand, -3},
+	{"zunion", zunionCommand, -3},
+	{"zunionstore", zunionstoreCommand, -4},
+	{"zinter", zinterCommand, -3},
+	{"zinterstore", zinterstoreCommand, -4},
+	{"zdiff", zdiffCommand, -3},
+	{"zdiffstore", zdiffstoreCommand, -4},
 }
 
 type GodisDB struct {
-	data   *Dict
-	expire *Dict
+	id           int
+	data         *Dict[*Gobj, *Gobj]
+	expire       *Dict[*Gobj, int64]
+	blockingKeys map[string][]*GodisClient // 阻塞在key上的客户端，按阻塞的先后顺序排列
+}
+
+func createGodisDB(id int) *GodisDB {
+	db := &GodisDB{
+		id:           id,
+		blockingKeys: make(map[string][]*GodisClient),
+	}
+	emptyDB(db)
+	return db
 }
 
 type GodisClient struct {
 	fd       int
 	db       *GodisDB
 	args     []*Gobj
-	reply    *List
+	reply    *List[string]
 	sentLen  int    //防止一个reply发送的内容过多，记录下当前已经发送的内容
 	queryBuf []byte // 客户端命令缓冲区
 	queryLen int    // 客户端命令缓冲区中已读位置的index
 	cmdTy    CmdType
 	bulkNum  int
 	bulkLen  int
+	cmd      *GodisCommand
+	bstate   *blockingState // 不为nil时客户端处于阻塞状态或者正在被唤醒
+	blocked  bool
 }
 
 type CommandProc func(c *GodisClient)
@@ -151,7 +355,8 @@ func ReadQueryFromClient(loop *AeLoop, fd int, extra any) {
 }
 
 func ProcessQueryBuf(client *GodisClient) error {
-	for client.queryLen > 0 {
+	// 阻塞的客户端在被唤醒之前不处理后续的命令
+	for client.queryLen > 0 && client.bstate == nil {
 		if client.cmdTy == CommonUnkonw {
 			if client.queryBuf[0] == '*' {
 				client.cmdTy = CommonBulk
@@ -290,22 +495,39 @@ func (client *GodisClient) getNumInQuery(s, e int) (int, error) {
 }
 
 func (client *GodisClient) AddReplyStr(s string) {
-	o := CreateObject(GSTR, s)
-	client.AddReply(o)
-	o.DecrRefCount()
+	client.reply.Append(s)
+	server.aeLoop.AddFileEvent(client.fd, AEWriteable, SendReplyToClient, client)
+}
+
+func (client *GodisClient) AddReplyBulk(o *Gobj) {
+	client.AddReplyBulkStr(o.StrVal())
+}
+
+func (client *GodisClient) AddReplyBulkStr(s string) {
+	client.AddReplyStr(fmt.Sprintf("$%d\r\n%v\r\n", len(s), s))
+}
+
+func (client *GodisClient) AddReplyInt(n int64) {
+	client.AddReplyStr(fmt.Sprintf(":%d\r\n", n))
+}
+
+func (client *GodisClient) AddReplyArrayLen(n int) {
+	client.AddReplyStr(fmt.Sprintf("*%d\r\n", n))
+}
+
+func (client *GodisClient) AddReplyError(msg string) {
+	client.AddReplyStr(fmt.Sprintf("-ERR %v\r\n", msg))
 }
 
 func (client *GodisClient) AddReply(o *Gobj) {
-	client.reply.Append(o)
-	o.IncrRefCount()
-	server.aeLoop.AddFileEvent(client.fd, AEWriteable, SendReplyToClient, client)
+	client.AddReplyStr(o.StrVal())
 }
 
 func ProcessCommand(c *GodisClient) {
 	cmdStr := c.args[0].StrVal()
 	log.Printf("process command: %v\n", cmdStr)
 
-	if cmdStr == "quit" {
+	if strings.EqualFold(cmdStr, "quit") {
 		freeClient(c)
 		return
 	}
@@ -315,26 +537,46 @@ func ProcessCommand(c *GodisClient) {
 		c.AddReplyStr("-ERR: unknow command\r\n")
 		resetClient(c)
 		return
-	} else if cmd.arity != len(c.args) {
+	} else if (cmd.arity > 0 && cmd.arity != len(c.args)) || len(c.args) < -cmd.arity {
 		c.AddReplyStr("-ERR: wrong number of args\r\n")
 		resetClient(c)
 		return
 	}
 
+	c.cmd = cmd
 	cmd.proc(c)
-	resetClient(c)
+	// 被阻塞的客户端保留参数，被唤醒时重新执行命令
+	if c.bstate == nil {
+		resetClient(c)
+	}
+	handleClientsBlockedOnKeys()
+}
+
+// 命令名大小写不敏感
+func populateCommandTable() {
+	server.commands = DictCreate(DictType[string, *GodisCommand]{
+		HashFunc: func(name string) int64 {
+			return int64(DictGenCaseHash(name))
+		},
+		EqualFunc: strings.EqualFold,
+	})
+	for i := range cmdTable {
+		server.commands.Add(cmdTable[i].name, &cmdTable[i])
+	}
 }
 
 func lookupCommand(cmdStr string) *GodisCommand {
-	for _, c := range cmdTable {
-		if c.name == cmdStr {
-			return &c
-		}
-	}
-	return nil
+	return server.commands.Get(cmdStr)
 }
 
 func freeClient(client *GodisClient) {
+	if client.bstate != nil {
+		if client.blocked {
+			removeFromBlockingKeys(client)
+		}
+		server.aeLoop.RemoveTimeEvent(client.bstate.timerID)
+		client.bstate = nil
+	}
 	freeArgs(client)
 	delete(server.clients, client.fd)
 	server.aeLoop.RemoveFileEvent(client.fd, AEReadable)
@@ -358,9 +600,7 @@ func freeArgs(client *GodisClient) {
 
 func freeReplyList(client *GodisClient) {
 	for client.reply.length != 0 {
-		n := client.reply.head
-		client.reply.DelNode(n)
-		n.Val.DecrRefCount()
+		client.reply.DelNode(client.reply.head)
 	}
 }
 
@@ -369,7 +609,7 @@ func SendReplyToClient(loop *AeLoop, fd int, extra any) {
 	log.Printf("SendReplyToClient, reply len:%v\n", client.reply.Length())
 	for client.reply.Length() > 0 {
 		rep := client.reply.First()
-		buf := []byte(rep.Val.StrVal())
+		buf := []byte(rep.Val)
 		bufLen := len(buf)
 		if client.sentLen < bufLen {
 			n, err := Write(fd, buf[client.sentLen:])
@@ -382,7 +622,6 @@ func SendReplyToClient(loop *AeLoop, fd int, extra any) {
 			log.Printf("send %v bytes to client:%v\n", n, client.fd)
 			if client.sentLen == bufLen {
 				client.reply.DelNode(rep)
-				rep.Val.DecrRefCount()
 				client.sentLen = 0
 			} else {
 				break
@@ -397,11 +636,22 @@ func SendReplyToClient(loop *AeLoop, fd int, extra any) {
 }
 
 func initServer(config *Config) error {
+	seed := make([]byte, 16)
+	if _, err := crand.Read(seed); err != nil {
+		return err
+	}
+	DictSetHashSeed(seed)
+	if err := setHashFunction(config.HashFunction); err != nil {
+		return err
+	}
+	populateCommandTable()
+	loadEncodingConfig(config)
+
 	server.port = config.Port
 	server.clients = make(map[int]*GodisClient)
-	server.db = &GodisDB{
-		data:   DictCreate(DictType{HashFunc: GStrHash, EqualFunc: GStrEqual}),
-		expire: DictCreate(DictType{HashFunc: GStrHash, EqualFunc: GStrEqual}),
+	server.dbs = make([]*GodisDB, config.Databases)
+	for i := range server.dbs {
+		server.dbs[i] = createGodisDB(i)
 	}
 
 	var err error
@@ -421,26 +671,83 @@ func GStrEqual(a, b *Gobj) bool {
 	return a.StrVal() == b.StrVal()
 }
 
+func loadEncodingConfig(config *Config) {
+	server.listMaxListpackSize = config.ListMaxListpackSize
+	server.listCompressDepth = config.ListCompressDepth
+	server.hashMaxListpackEntries = config.HashMaxListpackEntries
+	server.hashMaxListpackValue = config.HashMaxListpackValue
+	server.setMaxIntsetEntries = config.SetMaxIntsetEntries
+	server.zsetMaxListpackEntries = config.ZSetMaxListpackEntries
+	server.zsetMaxListpackValue = config.ZSetMaxListpackValue
+}
+
+// 字符串key使用的哈希函数，由配置项hash-function决定
+var strHashFunc = DictGenHash
+
+func fnvHash(s string) uint64 {
+	hash := fnv.New64()
+	hash.Write([]byte(s))
+	return hash.Sum64()
+}
+
+func setHashFunction(name string) error {
+	switch name {
+	case HashSipHash:
+		strHashFunc = DictGenHash
+	case HashFNV:
+		strHashFunc = fnvHash
+	default:
+		return fmt.Errorf("unknown hash-function '%v'", name)
+	}
+	return nil
+}
+
 func GStrHash(key *Gobj) int64 {
 	if key.Type_ != GSTR {
 		return 0
 	}
-	hash := fnv.New64()
-	hash.Write([]byte(key.StrVal()))
-	return int64(hash.Sum64())
+	return int64(strHashFunc(key.StrVal()))
 }
 
-const EXPIRE_CHECK_COUNT int = 100
+func beforeSleep(loop *AeLoop) {
+	processUnblockedClients()
+}
+
+const (
+	EXPIRE_CHECK_COUNT int   = 100
+	REHASH_CRON_MS     int64 = 1
+)
 
 func ServerCron(loop *AeLoop, fd int, extra any) {
-	for i := 0; i < EXPIRE_CHECK_COUNT; i++ {
-		entry := server.db.expire.RandomGet()
-		if entry == nil {
-			break
+	for _, db := range server.dbs {
+		for i := 0; i < EXPIRE_CHECK_COUNT; i++ {
+			entry := db.expire.RandomGet()
+			if entry == nil {
+				break
+			}
+			if entry.Val < GetMsTime() {
+				db.data.Delete(entry.Key)
+				db.expire.Delete(entry.Key)
+			}
+		}
+	}
+	databasesCron()
+}
+
+// databasesCron 对数据量大幅减少的数据库缩容，并利用空闲时间推进rehash
+func databasesCron() {
+	for _, db := range server.dbs {
+		db.data.ShrinkIfNeed()
+		db.expire.ShrinkIfNeed()
+	}
+
+	// 每次只推进一个dict的rehash，避免长时间阻塞
+	for _, db := range server.dbs {
+		if db.data.RehashMilliseconds(REHASH_CRON_MS) > 0 {
+			return
 		}
-		if entry.Val.IntVal() < time.Now().Unix() {
-			server.db.data.Delete(entry.Key)
-			server.db.expire.Delete(entry.Key)
+		if db.expire.RehashMilliseconds(REHASH_CRON_MS) > 0 {
+			return
 		}
 	}
 }
@@ -461,9 +768,9 @@ func AcceptHandler(loop *AeLoop, fd int, extra any) {
 func CreateClient(fd int) *GodisClient {
 	var client GodisClient
 	client.fd = fd
-	client.db = server.db
+	client.db = server.dbs[0]
 	client.queryBuf = make([]byte, GodisIOBuf)
-	client.reply = ListCreate(ListType{EqualFunc: GStrEqual})
+	client.reply = ListCreate(ListType[string]{})
 	return &client
 }
 
@@ -480,6 +787,7 @@ func main() {
 	}
 	server.aeLoop.AddFileEvent(server.fd, AEReadable, AcceptHandler, nil)
 	server.aeLoop.AddTimeEvent(AENormal, 100, ServerCron, nil)
+	server.aeLoop.SetBeforeSleepProc(beforeSleep)
 	log.Println("godis server is up.")
 	server.aeLoop.AEMain()
 }
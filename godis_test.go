@@ -0,0 +1,83 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 创建一个不依赖网络连接的客户端，每次调用都会重置数据库
+func createTestClient(t *testing.T) *GodisClient {
+	populateCommandTable()
+	loadEncodingConfig(DefaultConfig())
+	server.dbs = make([]*GodisDB, GodisDefaultDBNum)
+	for i := range server.dbs {
+		server.dbs[i] = createGodisDB(i)
+	}
+	server.readyKeys = nil
+	if server.aeLoop == nil {
+		loop, err := AeLoopCreate()
+		assert.Nil(t, err)
+		server.aeLoop = loop
+	}
+	return CreateClient(-1)
+}
+
+// 执行一条命令并返回完整的回复内容
+func execCommand(c *GodisClient, args ...string) string {
+	c.args = make([]*Gobj, len(args))
+	for i, v := range args {
+		c.args[i] = CreateObject(GSTR, v)
+	}
+	ProcessCommand(c)
+	return readReply(c)
+}
+
+// 读取并清空客户端当前的回复内容
+func readReply(c *GodisClient) string {
+	var reply string
+	for c.reply.Length() > 0 {
+		n := c.reply.First()
+		reply += n.Val
+		c.reply.DelNode(n)
+	}
+	return reply
+}
+
+func TestGetSet(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, "$-1\r\n", execCommand(c, "get", "k"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "set", "k", "v"))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "get", "k"))
+	assert.Equal(t, "-ERR: wrong number of args\r\n", execCommand(c, "get"))
+}
+
+func TestCommandCaseAndHash(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, "+OK\r\n", execCommand(c, "SET", "k", "v"))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "Get", "k"))
+
+	assert.NotNil(t, setHashFunction("unknown"))
+	assert.Nil(t, setHashFunction(HashFNV))
+	c = createTestClient(t)
+	execCommand(c, "set", "k", "v")
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "get", "k"))
+	assert.Nil(t, setHashFunction(HashSipHash))
+}
+
+func TestLoadConfigDatabases(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	assert.Nil(t, os.WriteFile(path, []byte(`{"databases": 4}`), 0644))
+	config, err := LoadConfig(path)
+	assert.Nil(t, err)
+	assert.Equal(t, 4, config.Databases)
+
+	for _, content := range []string{`{"databases": 0}`, `{"databases": -1}`} {
+		assert.Nil(t, os.WriteFile(path, []byte(content), 0644))
+		config, err = LoadConfig(path)
+		assert.NotNil(t, err)
+		assert.Nil(t, config)
+	}
+}
@@ -0,0 +1,435 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+)
+
+/*
+	HyperLogLog的实现与redis保持一致，使用2^14个6bit的寄存器，标准误差为0.81%。
+	数据以字符串的形式保存，前16个字节为header：
+	| "HYLL" | encoding(1) | 保留(3) | 缓存的基数(8，小端，最高位为1表示缓存失效) |
+	dense编码直接保存所有寄存器，sparse编码使用ZERO/XZERO/VAL三种操作码压缩连续相同的寄存器
+*/
+
+const (
+	HLLP            = 14
+	HLLQ            = 64 - HLLP
+	HLLRegisters    = 1 << HLLP
+	HLLPMask        = HLLRegisters - 1
+	HLLBits         = 6
+	HLLRegisterMax  = (1 << HLLBits) - 1
+	HLLHdrSize      = 16
+	HLLDenseSize    = HLLHdrSize + (HLLRegisters*HLLBits+7)/8
+	HLLDense        = 0
+	HLLSparse       = 1
+	HLLAlphaInf     = 0.721347520444481703680
+	HLLSparseMaxLen = 3000 // sparse编码超过该长度时转换为dense编码
+
+	hllSparseValMaxValue = 32
+	hllSparseValMaxLen   = 4
+	hllSparseZeroMaxLen  = 64
+	hllSparseXZeroMaxLen = 16384
+)
+
+const (
+	ReplyNotHLL     = "-WRONGTYPE Key is not a valid HyperLogLog string value.\r\n"
+	ReplyInvalidHLL = "-INVALIDOBJ Corrupted HLL object detected\r\n"
+)
+
+// MurmurHash64A 与redis使用相同的hash函数，保证结果一致
+func MurmurHash64A(key []byte, seed uint64) uint64 {
+	const m uint64 = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ (uint64(len(key)) * m)
+
+	data := key
+	for len(data) >= 8 {
+		k := binary.LittleEndian.Uint64(data)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		data = data[8:]
+	}
+
+	if len(data) > 0 {
+		for i := len(data) - 1; i >= 0; i-- {
+			h ^= uint64(data[i]) << (8 * uint(i))
+		}
+		h *= m
+	}
+
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// 返回元素对应的寄存器下标，以及hash剩余部分中第一个1出现的位置
+func hllPatLen(ele []byte) (int, uint8) {
+	hash := MurmurHash64A(ele, 0xadc83b19)
+	index := int(hash & HLLPMask)
+	hash >>= HLLP
+	hash |= 1 << HLLQ // 保证循环一定会结束
+	count := uint8(1)
+	for bit := uint64(1); hash&bit == 0; bit <<= 1 {
+		count++
+	}
+	return index, count
+}
+
+func hllDenseGetRegister(regs []byte, idx int) uint8 {
+	b := idx * HLLBits / 8
+	fb := uint(idx * HLLBits & 7)
+	val := uint(regs[b]) >> fb
+	if b+1 < len(regs) {
+		val |= uint(regs[b+1]) << (8 - fb)
+	}
+	return uint8(val & HLLRegisterMax)
+}
+
+func hllDenseSetRegister(regs []byte, idx int, val uint8) {
+	b := idx * HLLBits / 8
+	fb := uint(idx * HLLBits & 7)
+	regs[b] &^= byte(HLLRegisterMax << fb)
+	regs[b] |= byte(uint(val) << fb)
+	if b+1 < len(regs) {
+		regs[b+1] &^= byte(HLLRegisterMax >> (8 - fb))
+		regs[b+1] |= byte(uint(val) >> (8 - fb))
+	}
+}
+
+// 创建一个空的sparse编码的HyperLogLog
+func hllCreate() []byte {
+	buf := make([]byte, HLLHdrSize, HLLHdrSize+2)
+	copy(buf, "HYLL")
+	buf[4] = HLLSparse
+	return hllSparseEncode(buf, make([]uint8, HLLRegisters))
+}
+
+func isHLLValid(buf []byte) bool {
+	if len(buf) < HLLHdrSize || string(buf[:4]) != "HYLL" {
+		return false
+	}
+	if buf[4] == HLLDense {
+		return len(buf) == HLLDenseSize
+	}
+	return buf[4] == HLLSparse
+}
+
+func hllInvalidateCache(buf []byte) {
+	buf[15] |= 1 << 7
+}
+
+// 将所有寄存器解码到regs中，每个寄存器一个字节，sparse编码损坏时返回false
+func hllDecode(buf []byte, regs []uint8) bool {
+	data := buf[HLLHdrSize:]
+	if buf[4] == HLLDense {
+		for i := 0; i < HLLRegisters; i++ {
+			regs[i] = hllDenseGetRegister(data, i)
+		}
+		return true
+	}
+
+	idx := 0
+	for p := 0; p < len(data); {
+		op := data[p]
+		runLen := 0
+		if op&0xc0 == 0 {
+			// ZERO: 00xxxxxx
+			runLen = int(op&0x3f) + 1
+			p++
+		} else if op&0xc0 == 0x40 {
+			// XZERO: 01xxxxxx yyyyyyyy
+			if p+1 >= len(data) {
+				return false
+			}
+			runLen = (int(op&0x3f)<<8 | int(data[p+1])) + 1
+			p += 2
+		} else {
+			// VAL: 1vvvvvxx
+			runLen = int(op&0x3) + 1
+			if idx+runLen > HLLRegisters {
+				return false
+			}
+			val := (op>>2)&0x1f + 1
+			for i := 0; i < runLen; i++ {
+				regs[idx+i] = val
+			}
+			p++
+		}
+		idx += runLen
+		if idx > HLLRegisters {
+			return false
+		}
+	}
+	return idx == HLLRegisters
+}
+
+// 使用sparse编码将regs写入hdr之后，寄存器的值都不能超过hllSparseValMaxValue
+func hllSparseEncode(hdr []byte, regs []uint8) []byte {
+	buf := hdr[:HLLHdrSize]
+	buf[4] = HLLSparse
+	for i := 0; i < HLLRegisters; {
+		runLen := 1
+		val := regs[i]
+		maxLen := hllSparseValMaxLen
+		if val == 0 {
+			maxLen = hllSparseXZeroMaxLen
+		}
+		for i+runLen < HLLRegisters && regs[i+runLen] == val && runLen < maxLen {
+			runLen++
+		}
+
+		if val != 0 {
+			buf = append(buf, 0x80|(val-1)<<2|uint8(runLen-1))
+		} else if runLen <= hllSparseZeroMaxLen {
+			buf = append(buf, uint8(runLen-1))
+		} else {
+			buf = append(buf, 0x40|uint8((runLen-1)>>8), uint8((runLen-1)&0xff))
+		}
+		i += runLen
+	}
+	return buf
+}
+
+// 根据寄存器的值选择编码，sparse编码无法表示或者过长时使用dense编码
+func hllEncode(hdr []byte, regs []uint8, dense bool) []byte {
+	if !dense {
+		for _, val := range regs {
+			if val > hllSparseValMaxValue {
+				dense = true
+				break
+			}
+		}
+	}
+	if !dense {
+		buf := hllSparseEncode(hdr, regs)
+		if len(buf) <= HLLSparseMaxLen {
+			return buf
+		}
+	}
+
+	buf := make([]byte, HLLDenseSize)
+	copy(buf, hdr[:HLLHdrSize])
+	buf[4] = HLLDense
+	for i, val := range regs {
+		hllDenseSetRegister(buf[HLLHdrSize:], i, val)
+	}
+	return buf
+}
+
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		zPrime := z
+		z += x * y
+		y += y
+		if zPrime == z {
+			return z
+		}
+	}
+}
+
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		zPrime := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if zPrime == z {
+			return z / 3
+		}
+	}
+}
+
+// hllCount 使用Ertl提出的改进估计算法计算基数
+func hllCount(regs []uint8) uint64 {
+	var histo [64]int
+	for _, val := range regs {
+		histo[val]++
+	}
+
+	m := float64(HLLRegisters)
+	z := m * hllTau((m-float64(histo[HLLQ+1]))/m)
+	for j := HLLQ; j >= 1; j-- {
+		z += float64(histo[j])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histo[0])/m)
+	return uint64(math.Round(HLLAlphaInf * m * m / z))
+}
+
+// 查找HyperLogLog对象，不合法时回复错误并返回nil
+func hllLookup(c *GodisClient, key *Gobj) (*Gobj, bool) {
+	o := findKeyRead(c.db, key)
+	if o == nil {
+		return nil, true
+	}
+	if checkType(c, o, GSTR) {
+		return nil, false
+	}
+	if !isHLLValid(stringBytes(o)) {
+		c.AddReplyStr(ReplyNotHLL)
+		return nil, false
+	}
+	return o, true
+}
+
+func pfaddCommand(c *GodisClient) {
+	key := c.args[1]
+	o, ok := hllLookup(c, key)
+	if !ok {
+		return
+	}
+
+	updated := false
+	if o == nil {
+		o = CreateObject(GSTR, hllCreate())
+		setKey(c.db, key, o)
+		o.DecrRefCount()
+		updated = true
+	} else {
+		o = lookupStringForWrite(c, key, 0)
+	}
+
+	buf := o.Val_.([]byte)
+	regs := make([]uint8, HLLRegisters)
+	if !hllDecode(buf, regs) {
+		c.AddReplyStr(ReplyInvalidHLL)
+		return
+	}
+
+	changed := false
+	for _, ele := range c.args[2:] {
+		idx, count := hllPatLen(stringBytes(ele))
+		if count > regs[idx] {
+			regs[idx] = count
+			changed = true
+		}
+	}
+
+	if changed {
+		if buf[4] == HLLDense {
+			for i, val := range regs {
+				hllDenseSetRegister(buf[HLLHdrSize:], i, val)
+			}
+		} else {
+			buf = hllEncode(buf, regs, false)
+		}
+		hllInvalidateCache(buf)
+		o.Val_ = buf
+	}
+
+	if changed || updated {
+		c.AddReplyInt(1)
+	} else {
+		c.AddReplyInt(0)
+	}
+}
+
+// 将buf中的寄存器合并到max中
+func hllMerge(max []uint8, buf []byte) bool {
+	regs := make([]uint8, HLLRegisters)
+	if !hllDecode(buf, regs) {
+		return false
+	}
+	for i, val := range regs {
+		if val > max[i] {
+			max[i] = val
+		}
+	}
+	return true
+}
+
+func pfcountCommand(c *GodisClient) {
+	// 多个key时计算合并后的基数，不使用缓存
+	if len(c.args) > 2 {
+		max := make([]uint8, HLLRegisters)
+		for _, key := range c.args[1:] {
+			o, ok := hllLookup(c, key)
+			if !ok {
+				return
+			}
+			if o != nil && !hllMerge(max, stringBytes(o)) {
+				c.AddReplyStr(ReplyInvalidHLL)
+				return
+			}
+		}
+		c.AddReplyInt(int64(hllCount(max)))
+		return
+	}
+
+	key := c.args[1]
+	o, ok := hllLookup(c, key)
+	if !ok {
+		return
+	}
+	if o == nil {
+		c.AddReplyInt(0)
+		return
+	}
+
+	buf := stringBytes(o)
+	if buf[15]&(1<<7) == 0 {
+		c.AddReplyInt(int64(binary.LittleEndian.Uint64(buf[8:16])))
+		return
+	}
+
+	regs := make([]uint8, HLLRegisters)
+	if !hllDecode(buf, regs) {
+		c.AddReplyStr(ReplyInvalidHLL)
+		return
+	}
+	card := hllCount(regs)
+	// 缓存计算结果
+	buf = lookupStringForWrite(c, key, 0).Val_.([]byte)
+	binary.LittleEndian.PutUint64(buf[8:16], card)
+	c.AddReplyInt(int64(card))
+}
+
+func pfmergeCommand(c *GodisClient) {
+	max := make([]uint8, HLLRegisters)
+	dense := false
+	for _, key := range c.args[1:] {
+		o, ok := hllLookup(c, key)
+		if !ok {
+			return
+		}
+		if o == nil {
+			continue
+		}
+		buf := stringBytes(o)
+		if buf[4] == HLLDense {
+			dense = true
+		}
+		if !hllMerge(max, buf) {
+			c.AddReplyStr(ReplyInvalidHLL)
+			return
+		}
+	}
+
+	key := c.args[1]
+	hdr := hllCreate()
+	if o := findKeyRead(c.db, key); o != nil {
+		copy(hdr, stringBytes(o)[:HLLHdrSize])
+	}
+	buf := hllEncode(hdr, max, dense)
+	hllInvalidateCache(buf)
+
+	o := lookupStringForWrite(c, key, 0)
+	o.Val_ = buf
+	c.AddReplyStr(ReplyOK)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHLLSparseEncoding(t *testing.T) {
+	buf := hllCreate()
+	assert.Equal(t, HLLHdrSize+2, len(buf))
+	assert.True(t, isHLLValid(buf))
+
+	regs := make([]uint8, HLLRegisters)
+	regs[0] = 3
+	regs[1] = 3
+	regs[100] = 32
+	regs[HLLRegisters-1] = 1
+	buf = hllEncode(buf, regs, false)
+	assert.Equal(t, uint8(HLLSparse), buf[4])
+
+	decoded := make([]uint8, HLLRegisters)
+	assert.True(t, hllDecode(buf, decoded))
+	assert.Equal(t, regs, decoded)
+
+	// sparse编码无法表示大于32的值
+	regs[200] = 33
+	buf = hllEncode(buf, regs, false)
+	assert.Equal(t, uint8(HLLDense), buf[4])
+	assert.Equal(t, HLLDenseSize, len(buf))
+	assert.True(t, hllDecode(buf, decoded))
+	assert.Equal(t, regs, decoded)
+
+	assert.False(t, hllDecode(append(hllCreate(), 0x00), decoded))
+}
+
+func TestPFAdd(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":1\r\n", execCommand(c, "pfadd", "h", "a", "b", "c", "d", "e", "f", "g"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "pfadd", "h", "a", "b"))
+	assert.Equal(t, ":7\r\n", execCommand(c, "pfcount", "h"))
+	assert.Equal(t, ":7\r\n", execCommand(c, "pfcount", "h"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "pfadd", "empty"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "pfcount", "empty", "none"))
+
+	execCommand(c, "set", "s", "abc")
+	assert.Equal(t, ReplyNotHLL, execCommand(c, "pfadd", "s", "a"))
+	execCommand(c, "rpush", "l", "a")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "pfcount", "l"))
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	c := createTestClient(t)
+	total := 100000
+	for i := 0; i < total; i += 100 {
+		args := []string{"pfadd", "h"}
+		for j := i; j < i+100; j++ {
+			args = append(args, fmt.Sprintf("ele:%d", j))
+		}
+		execCommand(c, args...)
+	}
+
+	o := c.db.data.Get(CreateObject(GSTR, "h"))
+	assert.Equal(t, uint8(HLLDense), stringBytes(o)[4])
+
+	reply := execCommand(c, "pfcount", "h")
+	count, err := strconv.Atoi(reply[1 : len(reply)-2])
+	assert.Nil(t, err)
+	assert.True(t, math.Abs(float64(count-total))/float64(total) < 0.02, reply)
+}
+
+func TestPFMerge(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "pfadd", "h1", "a", "b", "c")
+	execCommand(c, "pfadd", "h2", "c", "d", "e")
+	assert.Equal(t, ":5\r\n", execCommand(c, "pfcount", "h1", "h2"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "pfmerge", "dst", "h1", "h2", "none"))
+	assert.Equal(t, ":5\r\n", execCommand(c, "pfcount", "dst"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "pfmerge", "h1", "h2"))
+	assert.Equal(t, ":5\r\n", execCommand(c, "pfcount", "h1"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "pfadd", "h1", "f"))
+	assert.Equal(t, ":6\r\n", execCommand(c, "pfcount", "h1"))
+}
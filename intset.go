@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+)
+
+// Intset 有序的整数集合，参考redis的intset.c
+// 所有元素使用相同的宽度保存，插入超出当前宽度的元素时整体升级为2、4或8个字节
+type Intset struct {
+	encoding int
+	contents []byte
+	length   int
+}
+
+const (
+	IntsetEncInt16 = 2
+	IntsetEncInt32 = 4
+	IntsetEncInt64 = 8
+)
+
+func IntsetCreate() *Intset {
+	return &Intset{encoding: IntsetEncInt16}
+}
+
+func intsetValueEncoding(v int64) int {
+	if v < math.MinInt32 || v > math.MaxInt32 {
+		return IntsetEncInt64
+	} else if v < math.MinInt16 || v > math.MaxInt16 {
+		return IntsetEncInt32
+	}
+	return IntsetEncInt16
+}
+
+func (is *Intset) Len() int {
+	return is.length
+}
+
+func (is *Intset) Bytes() int {
+	return len(is.contents)
+}
+
+func intsetGetEncoded(contents []byte, pos int, enc int) int64 {
+	buf := contents[pos*enc:]
+	switch enc {
+	case IntsetEncInt64:
+		return int64(binary.LittleEndian.Uint64(buf))
+	case IntsetEncInt32:
+		return int64(int32(binary.LittleEndian.Uint32(buf)))
+	}
+	return int64(int16(binary.LittleEndian.Uint16(buf)))
+}
+
+// Get 返回pos处的元素
+func (is *Intset) Get(pos int) int64 {
+	return intsetGetEncoded(is.contents, pos, is.encoding)
+}
+
+func (is *Intset) set(pos int, v int64) {
+	buf := is.contents[pos*is.encoding:]
+	switch is.encoding {
+	case IntsetEncInt64:
+		binary.LittleEndian.PutUint64(buf, uint64(v))
+	case IntsetEncInt32:
+		binary.LittleEndian.PutUint32(buf, uint32(v))
+	default:
+		binary.LittleEndian.PutUint16(buf, uint16(v))
+	}
+}
+
+func (is *Intset) resize(length int) {
+	size := length * is.encoding
+	if size > cap(is.contents) {
+		contents := make([]byte, size, size*2)
+		copy(contents, is.contents)
+		is.contents = contents
+	}
+	is.contents = is.contents[:size]
+}
+
+// 二分查找v，不存在时返回v应该插入的位置
+func (is *Intset) search(v int64) (int, bool) {
+	if is.length == 0 {
+		return 0, false
+	}
+	// 大于最大值或小于最小值时不需要查找
+	if v > is.Get(is.length-1) {
+		return is.length, false
+	} else if v < is.Get(0) {
+		return 0, false
+	}
+
+	lo, hi := 0, is.length-1
+	for lo <= hi {
+		mid := (lo + hi) / 2
+		cur := is.Get(mid)
+		if v > cur {
+			lo = mid + 1
+		} else if v < cur {
+			hi = mid - 1
+		} else {
+			return mid, true
+		}
+	}
+	return lo, false
+}
+
+// 升级编码之后插入v，v一定比所有元素都大或者都小
+func (is *Intset) upgradeAndAdd(v int64) {
+	oldEnc := is.encoding
+	is.encoding = intsetValueEncoding(v)
+	prepend := 0
+	if v < 0 {
+		prepend = 1
+	}
+
+	// 按新的宽度重新分配空间并复制原有的元素
+	old := is.contents
+	is.contents = nil
+	is.resize(is.length + 1)
+	for i := is.length - 1; i >= 0; i-- {
+		is.set(i+prepend, intsetGetEncoded(old, i, oldEnc))
+	}
+	if prepend == 1 {
+		is.set(0, v)
+	} else {
+		is.set(is.length, v)
+	}
+	is.length++
+}
+
+// Add 插入v，v已经存在时返回false
+func (is *Intset) Add(v int64) bool {
+	if intsetValueEncoding(v) > is.encoding {
+		is.upgradeAndAdd(v)
+		return true
+	}
+
+	pos, found := is.search(v)
+	if found {
+		return false
+	}
+	is.resize(is.length + 1)
+	copy(is.contents[(pos+1)*is.encoding:], is.contents[pos*is.encoding:is.length*is.encoding])
+	is.set(pos, v)
+	is.length++
+	return true
+}
+
+// Remove 删除v，v不存在时返回false
+func (is *Intset) Remove(v int64) bool {
+	if intsetValueEncoding(v) > is.encoding {
+		return false
+	}
+	pos, found := is.search(v)
+	if !found {
+		return false
+	}
+	copy(is.contents[pos*is.encoding:], is.contents[(pos+1)*is.encoding:])
+	is.length--
+	is.resize(is.length)
+	return true
+}
+
+func (is *Intset) Find(v int64) bool {
+	if intsetValueEncoding(v) > is.encoding {
+		return false
+	}
+	_, found := is.search(v)
+	return found
+}
+
+// Random 随机返回一个元素，调用方需要保证intset不为空
+func (is *Intset) Random() int64 {
+	return is.Get(rand.Intn(is.length))
+}
+
+func (is *Intset) Dup() *Intset {
+	return &Intset{
+		encoding: is.encoding,
+		contents: append([]byte(nil), is.contents...),
+		length:   is.length,
+	}
+}
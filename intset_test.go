@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIntset(t *testing.T) {
+	is := IntsetCreate()
+	assert.True(t, is.Add(5))
+	assert.True(t, is.Add(1))
+	assert.True(t, is.Add(3))
+	assert.False(t, is.Add(3))
+	assert.Equal(t, IntsetEncInt16, is.encoding)
+	assert.Equal(t, 3, is.Len())
+
+	// 升级编码之后元素仍然有序
+	assert.True(t, is.Add(math.MaxInt32+1))
+	assert.Equal(t, IntsetEncInt64, is.encoding)
+	assert.True(t, is.Add(-70000))
+	assert.Equal(t, []int64{-70000, 1, 3, 5, math.MaxInt32 + 1}, intsetValues(is))
+
+	assert.True(t, is.Find(3))
+	assert.False(t, is.Find(4))
+	assert.True(t, is.Remove(3))
+	assert.False(t, is.Remove(3))
+	assert.Equal(t, []int64{-70000, 1, 5, math.MaxInt32 + 1}, intsetValues(is))
+	assert.True(t, is.Find(is.Random()))
+
+	small := IntsetCreate()
+	small.Add(1)
+	assert.False(t, small.Find(math.MaxInt64))
+	assert.False(t, small.Remove(math.MinInt64))
+}
+
+func intsetValues(is *Intset) []int64 {
+	vals := make([]int64, is.Len())
+	for i := range vals {
+		vals[i] = is.Get(i)
+	}
+	return vals
+}
@@ -1,41 +1,42 @@
 package main
 
-type Node struct {
-	Val  *Gobj
-	next *Node
-	pre  *Node
+type Node[T any] struct {
+	Val  T
+	next *Node[T]
+	pre  *Node[T]
 }
 
-type ListType struct {
-	EqualFunc func(a, b *Gobj) bool
+// ListType 中的EqualFunc只有Find和Delete会用到
+type ListType[T any] struct {
+	EqualFunc func(a, b T) bool
 }
 
-type List struct {
-	ListType
-	head   *Node
-	tail   *Node
+type List[T any] struct {
+	ListType[T]
+	head   *Node[T]
+	tail   *Node[T]
 	length int
 }
 
-func ListCreate(listType ListType) *List {
-	var list List
+func ListCreate[T any](listType ListType[T]) *List[T] {
+	var list List[T]
 	list.ListType = listType
 	return &list
 }
 
-func (list *List) Length() int {
+func (list *List[T]) Length() int {
 	return list.length
 }
 
-func (list *List) First() *Node {
+func (list *List[T]) First() *Node[T] {
 	return list.head
 }
 
-func (list *List) Last() *Node {
+func (list *List[T]) Last() *Node[T] {
 	return list.tail
 }
 
-func (list *List) Find(val *Gobj) *Node {
+func (list *List[T]) Find(val T) *Node[T] {
 	p := list.head
 	for p != nil {
 		if list.EqualFunc(p.Val, val) {
@@ -46,8 +47,8 @@ func (list *List) Find(val *Gobj) *Node {
 	return p
 }
 
-func (list *List) Append(val *Gobj) {
-	var n Node
+func (list *List[T]) Append(val T) {
+	var n Node[T]
 	n.Val = val
 	if list.head == nil {
 		list.head = &n
@@ -60,8 +61,8 @@ func (list *List) Append(val *Gobj) {
 	list.length += 1
 }
 
-func (list *List) LPush(val *Gobj) {
-	var n Node
+func (list *List[T]) LPush(val T) {
+	var n Node[T]
 	n.Val = val
 	if list.head == nil {
 		list.head = &n
@@ -74,38 +75,74 @@ func (list *List) LPush(val *Gobj) {
 	list.length += 1
 }
 
-func (list *List) DelNode(n *Node) {
+func (list *List[T]) DelNode(n *Node[T]) {
 	if n == nil {
 		return
 	}
 
-	if list.head == n {
-		if n.next != nil {
-			n.next.pre = nil
-		}
+	if n.pre != nil {
+		n.pre.next = n.next
+	} else {
 		list.head = n.next
-		n.next = nil
-	} else if list.tail == n {
-		if n.pre != nil {
-			n.pre.next = nil
-		}
-		list.tail = n.pre
-		n.pre = nil
+	}
+	if n.next != nil {
+		n.next.pre = n.pre
 	} else {
-		if n.pre != nil {
-			n.pre.next = n.next
-		}
-		if n.next != nil {
-			n.next.pre = n.pre
-		}
-
-		n.pre = nil
-		n.next = nil
+		list.tail = n.pre
 	}
+	n.pre = nil
+	n.next = nil
 
 	list.length -= 1
 }
 
-func (list *List) Delete(val *Gobj) {
+func (list *List[T]) Delete(val T) {
 	list.DelNode(list.Find(val))
 }
+
+// Index 获取下标为idx的节点，idx为负数时从尾部开始计数，-1表示最后一个节点
+func (list *List[T]) Index(idx int) *Node[T] {
+	var n *Node[T]
+	if idx < 0 {
+		idx = -idx - 1
+		n = list.tail
+		for n != nil && idx > 0 {
+			n = n.pre
+			idx--
+		}
+	} else {
+		n = list.head
+		for n != nil && idx > 0 {
+			n = n.next
+			idx--
+		}
+	}
+	return n
+}
+
+// InsertNode 在old节点之前或之后插入val
+func (list *List[T]) InsertNode(old *Node[T], val T, after bool) {
+	var n Node[T]
+	n.Val = val
+	if after {
+		n.pre = old
+		n.next = old.next
+		if list.tail == old {
+			list.tail = &n
+		}
+	} else {
+		n.next = old
+		n.pre = old.pre
+		if list.head == old {
+			list.head = &n
+		}
+	}
+
+	if n.pre != nil {
+		n.pre.next = &n
+	}
+	if n.next != nil {
+		n.next.pre = &n
+	}
+	list.length += 1
+}
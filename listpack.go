@@ -0,0 +1,382 @@
+package main
+
+import (
+	"encoding/binary"
+	"strconv"
+)
+
+// Listpack 紧凑的序列化列表，参考redis的listpack.c
+// 每个entry由encoding、data和backlen三部分组成，backlen记录encoding+data的长度，用于从后向前遍历。
+// 能表示为int64的字符串使用整数编码保存，entry的位置使用在buf中的偏移量表示，-1表示不存在
+type Listpack struct {
+	buf []byte
+	num int
+}
+
+const (
+	lpEnc7BitUint = 0x00 // 0xxxxxxx
+	lpEnc6BitStr  = 0x80 // 10xxxxxx
+	lpEnc13BitInt = 0xC0 // 110xxxxx yyyyyyyy
+	lpEnc12BitStr = 0xE0 // 1110xxxx yyyyyyyy
+	lpEnc32BitStr = 0xF0
+	lpEnc16BitInt = 0xF1
+	lpEnc24BitInt = 0xF2
+	lpEnc32BitInt = 0xF3
+	lpEnc64BitInt = 0xF4
+)
+
+func ListpackCreate() *Listpack {
+	return &Listpack{}
+}
+
+// Len 返回元素的数量
+func (lp *Listpack) Len() int {
+	return lp.num
+}
+
+// Bytes 返回占用的字节数
+func (lp *Listpack) Bytes() int {
+	return len(lp.buf)
+}
+
+// 返回encoding+data
+func lpEncodeInt(v int64) []byte {
+	switch {
+	case v >= 0 && v <= 127:
+		return []byte{byte(v)}
+	case v >= -4096 && v <= 4095:
+		uv := uint64(v) & (1<<13 - 1)
+		return []byte{byte(uv>>8) | lpEnc13BitInt, byte(uv)}
+	case v >= -32768 && v <= 32767:
+		return []byte{lpEnc16BitInt, byte(v), byte(v >> 8)}
+	case v >= -8388608 && v <= 8388607:
+		return []byte{lpEnc24BitInt, byte(v), byte(v >> 8), byte(v >> 16)}
+	case v >= -2147483648 && v <= 2147483647:
+		buf := []byte{lpEnc32BitInt, 0, 0, 0, 0}
+		binary.LittleEndian.PutUint32(buf[1:], uint32(v))
+		return buf
+	}
+	buf := make([]byte, 9)
+	buf[0] = lpEnc64BitInt
+	binary.LittleEndian.PutUint64(buf[1:], uint64(v))
+	return buf
+}
+
+func lpEncodeString(s string) []byte {
+	var buf []byte
+	l := len(s)
+	switch {
+	case l < 64:
+		buf = make([]byte, 1, 1+l)
+		buf[0] = byte(l) | lpEnc6BitStr
+	case l < 4096:
+		buf = make([]byte, 2, 2+l)
+		buf[0] = byte(l>>8) | lpEnc12BitStr
+		buf[1] = byte(l)
+	default:
+		buf = make([]byte, 5, 5+l)
+		buf[0] = lpEnc32BitStr
+		binary.LittleEndian.PutUint32(buf[1:], uint32(l))
+	}
+	return append(buf, s...)
+}
+
+func lpBacklenSize(l int) int {
+	switch {
+	case l <= 127:
+		return 1
+	case l < 16383:
+		return 2
+	case l < 2097151:
+		return 3
+	case l < 268435455:
+		return 4
+	}
+	return 5
+}
+
+// backlen从后向前读取，每个字节保存7位，最高位为1表示前面还有字节
+func lpEncodeBacklen(l int) []byte {
+	n := lpBacklenSize(l)
+	buf := make([]byte, n)
+	for i := n - 1; i >= 0; i-- {
+		buf[i] = byte(l>>(7*(n-1-i))) & 127
+		if i != 0 {
+			buf[i] |= 128
+		}
+	}
+	return buf
+}
+
+// 从q开始向前解析backlen，q指向backlen的最后一个字节
+func (lp *Listpack) decodeBacklen(q int) int {
+	var val, shift int
+	for {
+		b := lp.buf[q]
+		val |= int(b&127) << shift
+		if b&128 == 0 {
+			break
+		}
+		shift += 7
+		q--
+	}
+	return val
+}
+
+// lpEntrySize 返回s编码成entry之后的长度
+func lpEntrySize(s string) int {
+	var l int
+	if v, ok := stringToInt64(s); ok {
+		l = len(lpEncodeInt(v))
+	} else if len(s) < 64 {
+		l = 1 + len(s)
+	} else if len(s) < 4096 {
+		l = 2 + len(s)
+	} else {
+		l = 5 + len(s)
+	}
+	return l + lpBacklenSize(l)
+}
+
+// 构造一个完整的entry
+func lpEncodeEntry(s string) []byte {
+	var entry []byte
+	if v, ok := stringToInt64(s); ok {
+		entry = lpEncodeInt(v)
+	} else {
+		entry = lpEncodeString(s)
+	}
+	return append(entry, lpEncodeBacklen(len(entry))...)
+}
+
+// 返回p处entry的encoding+data的长度
+func (lp *Listpack) encodedSize(p int) int {
+	b := lp.buf[p]
+	switch {
+	case b&0x80 == lpEnc7BitUint:
+		return 1
+	case b&0xC0 == lpEnc6BitStr:
+		return 1 + int(b&0x3F)
+	case b&0xE0 == lpEnc13BitInt:
+		return 2
+	case b&0xF0 == lpEnc12BitStr:
+		return 2 + (int(b&0x0F)<<8 | int(lp.buf[p+1]))
+	}
+	switch b {
+	case lpEnc16BitInt:
+		return 3
+	case lpEnc24BitInt:
+		return 4
+	case lpEnc32BitInt:
+		return 5
+	case lpEnc64BitInt:
+		return 9
+	case lpEnc32BitStr:
+		return 5 + int(binary.LittleEndian.Uint32(lp.buf[p+1:]))
+	}
+	panic("invalid listpack encoding")
+}
+
+func (lp *Listpack) entrySize(p int) int {
+	l := lp.encodedSize(p)
+	return l + lpBacklenSize(l)
+}
+
+func (lp *Listpack) First() int {
+	if lp.num == 0 {
+		return -1
+	}
+	return 0
+}
+
+func (lp *Listpack) Last() int {
+	if lp.num == 0 {
+		return -1
+	}
+	return lp.Prev(len(lp.buf))
+}
+
+func (lp *Listpack) Next(p int) int {
+	p += lp.entrySize(p)
+	if p >= len(lp.buf) {
+		return -1
+	}
+	return p
+}
+
+func (lp *Listpack) Prev(p int) int {
+	if p <= 0 {
+		return -1
+	}
+	l := lp.decodeBacklen(p - 1)
+	return p - l - lpBacklenSize(l)
+}
+
+// Seek 返回下标为index的entry，index为负数时从尾部开始计数
+func (lp *Listpack) Seek(index int) int {
+	if index < 0 {
+		index += lp.num
+	}
+	if index < 0 || index >= lp.num {
+		return -1
+	}
+
+	// 从距离较近的一端开始查找
+	if index < lp.num/2 {
+		p := lp.First()
+		for ; index > 0; index-- {
+			p = lp.Next(p)
+		}
+		return p
+	}
+	p := lp.Last()
+	for i := lp.num - 1; i > index; i-- {
+		p = lp.Prev(p)
+	}
+	return p
+}
+
+// Get 返回p处entry的值，整数编码时isInt为true，值保存在v中
+func (lp *Listpack) Get(p int) (s string, v int64, isInt bool) {
+	b := lp.buf[p]
+	switch {
+	case b&0x80 == lpEnc7BitUint:
+		return "", int64(b), true
+	case b&0xC0 == lpEnc6BitStr:
+		l := int(b & 0x3F)
+		return string(lp.buf[p+1 : p+1+l]), 0, false
+	case b&0xE0 == lpEnc13BitInt:
+		uv := int64(b&0x1F)<<8 | int64(lp.buf[p+1])
+		if uv >= 1<<12 {
+			uv -= 1 << 13
+		}
+		return "", uv, true
+	case b&0xF0 == lpEnc12BitStr:
+		l := int(b&0x0F)<<8 | int(lp.buf[p+1])
+		return string(lp.buf[p+2 : p+2+l]), 0, false
+	}
+
+	data := lp.buf[p+1:]
+	switch b {
+	case lpEnc16BitInt:
+		return "", int64(int16(binary.LittleEndian.Uint16(data))), true
+	case lpEnc24BitInt:
+		uv := int64(data[0]) | int64(data[1])<<8 | int64(data[2])<<16
+		if uv >= 1<<23 {
+			uv -= 1 << 24
+		}
+		return "", uv, true
+	case lpEnc32BitInt:
+		return "", int64(int32(binary.LittleEndian.Uint32(data))), true
+	case lpEnc64BitInt:
+		return "", int64(binary.LittleEndian.Uint64(data)), true
+	case lpEnc32BitStr:
+		l := int(binary.LittleEndian.Uint32(data))
+		return string(data[4 : 4+l]), 0, false
+	}
+	panic("invalid listpack encoding")
+}
+
+func (lp *Listpack) GetString(p int) string {
+	s, v, isInt := lp.Get(p)
+	if isInt {
+		return strconv.FormatInt(v, 10)
+	}
+	return s
+}
+
+// Compare p处entry的值与s相等时返回true
+func (lp *Listpack) Compare(p int, s string) bool {
+	str, v, isInt := lp.Get(p)
+	if isInt {
+		sv, ok := stringToInt64(s)
+		return ok && sv == v
+	}
+	return str == s
+}
+
+// Find 从p开始查找值为s的entry，每次比较之后跳过skip个entry（例如hash中跳过value），找不到时返回-1
+func (lp *Listpack) Find(p int, s string, skip int) int {
+	for p != -1 {
+		if lp.Compare(p, s) {
+			return p
+		}
+		p = lp.Next(p)
+		for i := 0; i < skip && p != -1; i++ {
+			p = lp.Next(p)
+		}
+	}
+	return -1
+}
+
+// 将data插入到偏移量p处
+func (lp *Listpack) insertAt(p int, data []byte) {
+	lp.buf = append(lp.buf, data...)
+	copy(lp.buf[p+len(data):], lp.buf[p:len(lp.buf)-len(data)])
+	copy(lp.buf[p:], data)
+}
+
+func (lp *Listpack) Append(s string) {
+	lp.buf = append(lp.buf, lpEncodeEntry(s)...)
+	lp.num++
+}
+
+func (lp *Listpack) Prepend(s string) {
+	lp.insertAt(0, lpEncodeEntry(s))
+	lp.num++
+}
+
+// Insert 在p之前或之后插入s，返回新entry的偏移量
+func (lp *Listpack) Insert(p int, s string, after bool) int {
+	if after {
+		p += lp.entrySize(p)
+	}
+	lp.insertAt(p, lpEncodeEntry(s))
+	lp.num++
+	return p
+}
+
+// Replace 替换p处entry的值，偏移量保持不变
+func (lp *Listpack) Replace(p int, s string) {
+	entry := lpEncodeEntry(s)
+	old := lp.entrySize(p)
+	tail := lp.buf[p+old:]
+	buf := make([]byte, 0, len(lp.buf)-old+len(entry))
+	buf = append(buf, lp.buf[:p]...)
+	buf = append(buf, entry...)
+	lp.buf = append(buf, tail...)
+}
+
+// Delete 删除p处的entry，返回下一个entry的偏移量
+func (lp *Listpack) Delete(p int) int {
+	return lp.DeleteRange(p, 1)
+}
+
+// DeleteRange 从p开始删除num个entry，返回下一个entry的偏移量
+func (lp *Listpack) DeleteRange(p int, num int) int {
+	end := p
+	for ; num > 0 && end < len(lp.buf); num-- {
+		end += lp.entrySize(end)
+		lp.num--
+	}
+	lp.buf = append(lp.buf[:p], lp.buf[end:]...)
+	if p >= len(lp.buf) {
+		return -1
+	}
+	return p
+}
+
+// Split 将p及之后的entry移动到一个新的listpack中返回
+func (lp *Listpack) Split(p int) *Listpack {
+	rest := &Listpack{buf: append([]byte(nil), lp.buf[p:]...)}
+	for q := 0; q < len(rest.buf); q += rest.entrySize(q) {
+		rest.num++
+	}
+	lp.buf = lp.buf[:p]
+	lp.num -= rest.num
+	return rest
+}
+
+func (lp *Listpack) Dup() *Listpack {
+	return &Listpack{buf: append([]byte(nil), lp.buf...), num: lp.num}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListpack(t *testing.T) {
+	lp := ListpackCreate()
+	assert.Equal(t, -1, lp.First())
+	assert.Equal(t, -1, lp.Last())
+
+	// 覆盖所有的整数和字符串编码
+	vals := []string{"0", "127", "-1", "4095", "-4096", "32767", "8388607", "2147483647",
+		"9223372036854775807", "-9223372036854775808", "01", "abc", strings.Repeat("x", 100),
+		strings.Repeat("y", 5000), ""}
+	for _, v := range vals {
+		lp.Append(v)
+	}
+	assert.Equal(t, len(vals), lp.Len())
+
+	i := 0
+	for p := lp.First(); p != -1; p = lp.Next(p) {
+		assert.Equal(t, vals[i], lp.GetString(p))
+		i++
+	}
+	assert.Equal(t, len(vals), i)
+	for p := lp.Last(); p != -1; p = lp.Prev(p) {
+		i--
+		assert.Equal(t, vals[i], lp.GetString(p))
+	}
+
+	_, v, isInt := lp.Get(lp.Seek(4))
+	assert.True(t, isInt)
+	assert.Equal(t, int64(-4096), v)
+	_, _, isInt = lp.Get(lp.Seek(10))
+	assert.False(t, isInt)
+	assert.Equal(t, "abc", lp.GetString(lp.Seek(-4)))
+	assert.Equal(t, -1, lp.Seek(len(vals)))
+	assert.True(t, lp.Compare(lp.Seek(1), "127"))
+	assert.False(t, lp.Compare(lp.Seek(10), "1"))
+	assert.Equal(t, lp.Seek(11), lp.Find(lp.First(), "abc", 0))
+	assert.Equal(t, -1, lp.Find(lp.First(), "abc", 1))
+}
+
+func TestListpackModify(t *testing.T) {
+	lp := ListpackCreate()
+	for i := 0; i < 5; i++ {
+		lp.Append(strconv.Itoa(i))
+	}
+	lp.Prepend("head")
+	p := lp.Insert(lp.Seek(2), "after1", true)
+	assert.Equal(t, "after1", lp.GetString(p))
+	lp.Replace(lp.Seek(0), strings.Repeat("h", 200))
+	lp.Replace(lp.Seek(-1), "tail")
+
+	var got []string
+	for p := lp.First(); p != -1; p = lp.Next(p) {
+		got = append(got, lp.GetString(p))
+	}
+	assert.Equal(t, []string{strings.Repeat("h", 200), "0", "1", "after1", "2", "3", "tail"}, got)
+
+	next := lp.Delete(lp.Seek(1))
+	assert.Equal(t, "1", lp.GetString(next))
+	assert.Equal(t, -1, lp.DeleteRange(lp.Seek(3), 10))
+	assert.Equal(t, 3, lp.Len())
+	assert.Equal(t, "after1", lp.GetString(lp.Last()))
+
+	dup := lp.Dup()
+	dup.Append("x")
+	assert.Equal(t, 3, lp.Len())
+	assert.Equal(t, 4, dup.Len())
+}
@@ -0,0 +1,115 @@
+package main
+
+// LZF压缩算法，参考redis使用的liblzf，quicklist用它压缩中间的节点。
+// 压缩结果由若干段组成：控制字节小于32时表示后面跟着控制字节+1个字面量，
+// 否则高3位表示匹配长度-2（为7时长度保存在下一个字节中），低5位和后面一个字节表示回退的偏移量-1
+
+const (
+	lzfHashLog = 13
+	lzfMaxLit  = 1 << 5
+	lzfMaxOff  = 1 << 13
+	lzfMaxRef  = 1<<8 + 1<<3
+)
+
+// 保存3字节前缀上一次出现的位置+1，服务器是单线程的，所以可以复用
+var lzfHashTable [1 << lzfHashLog]int32
+
+func lzfIndex(v uint32) uint32 {
+	return ((v >> (3*8 - lzfHashLog)) - v) & (1<<lzfHashLog - 1)
+}
+
+// lzfCompress 压缩in，压缩后的长度不小于原长度时返回nil
+func lzfCompress(in []byte) []byte {
+	if len(in) < 4 {
+		return nil
+	}
+	lzfHashTable = [1 << lzfHashLog]int32{}
+
+	out := make([]byte, 1, len(in))
+	// out[litPos]保存当前字面量段的长度-1
+	litPos, lit := 0, 0
+	endLiteral := func() {
+		if lit == 0 {
+			out = out[:litPos]
+		} else {
+			out[litPos] = byte(lit - 1)
+		}
+	}
+	addLiteral := func(b byte) {
+		out = append(out, b)
+		lit++
+		if lit == lzfMaxLit {
+			out[litPos] = byte(lit - 1)
+			litPos, lit = len(out), 0
+			out = append(out, 0)
+		}
+	}
+
+	ip := 0
+	for ip < len(in)-2 {
+		if len(out) >= len(in) {
+			return nil
+		}
+		h := lzfIndex(uint32(in[ip])<<16 | uint32(in[ip+1])<<8 | uint32(in[ip+2]))
+		ref := int(lzfHashTable[h]) - 1
+		lzfHashTable[h] = int32(ip + 1)
+		off := ip - ref - 1
+		if ref < 0 || off >= lzfMaxOff || in[ref] != in[ip] || in[ref+1] != in[ip+1] || in[ref+2] != in[ip+2] {
+			addLiteral(in[ip])
+			ip++
+			continue
+		}
+
+		n := 3
+		for ip+n < len(in) && n < lzfMaxRef && in[ref+n] == in[ip+n] {
+			n++
+		}
+		endLiteral()
+		if l := n - 2; l < 7 {
+			out = append(out, byte(off>>8+l<<5))
+		} else {
+			out = append(out, byte(off>>8+7<<5), byte(l-7))
+		}
+		out = append(out, byte(off))
+		litPos, lit = len(out), 0
+		out = append(out, 0)
+		ip += n
+	}
+	for ; ip < len(in); ip++ {
+		addLiteral(in[ip])
+	}
+	endLiteral()
+	if len(out) >= len(in) {
+		return nil
+	}
+	return out
+}
+
+// lzfDecompress 解压缩in，outLen为原始数据的长度
+func lzfDecompress(in []byte, outLen int) []byte {
+	out := make([]byte, 0, outLen)
+	for ip := 0; ip < len(in); {
+		ctrl := int(in[ip])
+		ip++
+		if ctrl < lzfMaxLit {
+			ctrl++
+			out = append(out, in[ip:ip+ctrl]...)
+			ip += ctrl
+			continue
+		}
+
+		n := ctrl >> 5
+		ref := len(out) - (ctrl&0x1f)<<8 - 1
+		if n == 7 {
+			n += int(in[ip])
+			ip++
+		}
+		ref -= int(in[ip])
+		ip++
+		// 匹配的范围可能与输出重叠，需要逐字节复制
+		for i := 0; i < n+2; i++ {
+			out = append(out, out[ref+i])
+		}
+	}
+	return out
+}
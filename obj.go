@@ -1,31 +1,96 @@
 package main
 
-import "strconv"
+import (
+	"math"
+	"strconv"
+	"strings"
+)
 
 type GType uint8
 
 const (
-	GSTR  GType = 0x00
-	GList GType = 0x01
-	GSet  GType = 0x02
-	GZSet GType = 0x03
-	GDict GType = 0x04
+	GSTR    GType = 0x00
+	GList   GType = 0x01
+	GSet    GType = 0x02
+	GZSet   GType = 0x03
+	GDict   GType = 0x04
+	GStream GType = 0x05
 )
 
 type Gval any
 
+// 对象的编码由Val_的实际类型决定，OBJECT ENCODING返回这里的名字
+const (
+	EncodingRaw       = "raw"
+	EncodingInt       = "int"
+	EncodingEmbstr    = "embstr"
+	EncodingListpack  = "listpack"
+	EncodingQuicklist = "quicklist"
+	EncodingHashtable = "hashtable"
+	EncodingIntset    = "intset"
+	EncodingSkiplist  = "skiplist"
+	EncodingStream    = "stream"
+)
+
+const (
+	// 与redis一样，不超过该长度的字符串视为embstr编码
+	EmbstrSizeLimit = 44
+	// [0, SharedIntegers)范围内的整数使用共享对象
+	SharedIntegers = 10000
+	// 共享对象的引用计数固定为该值，不会被修改也不会被释放
+	SharedRefCount = math.MaxInt32
+)
+
+var sharedIntegers = createSharedIntegers()
+
+func createSharedIntegers() []*Gobj {
+	objs := make([]*Gobj, SharedIntegers)
+	for i := range objs {
+		objs[i] = &Gobj{Type_: GSTR, Val_: int64(i), refCount: SharedRefCount}
+	}
+	return objs
+}
+
 type Gobj struct {
 	Type_    GType
 	Val_     Gval
 	refCount int
 }
 
+// GSTR对象的Val_可能是string、整数编码的int64，或者是可以原地修改的[]byte（例如bitmap）
 func (o *Gobj) IntVal() int64 {
+	val, _ := o.TryIntVal()
+	return val
+}
+
+// TryIntVal 获取整数值，对象不能表示为int64时返回false
+func (o *Gobj) TryIntVal() (int64, bool) {
+	if o.Type_ != GSTR {
+		return 0, false
+	}
+
+	switch v := o.Val_.(type) {
+	case int64:
+		return v, true
+	case string:
+		return stringToInt64(v)
+	case []byte:
+		return stringToInt64(string(v))
+	}
+	return 0, false
+}
+
+func (o *Gobj) isIntEncoded() bool {
+	_, ok := o.Val_.(int64)
+	return o.Type_ == GSTR && ok
+}
+
+func (o *Gobj) FloatVal() float64 {
 	if o.Type_ != GSTR {
 		return 0
 	}
 
-	val, _ := strconv.ParseInt(o.Val_.(string), 10, 64)
+	val, _ := strconv.ParseFloat(o.StrVal(), 64)
 	return val
 }
 
@@ -34,13 +99,42 @@ func (o *Gobj) StrVal() string {
 		return ""
 	}
 
-	return o.Val_.(string)
+	switch v := o.Val_.(type) {
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case []byte:
+		return string(v)
+	}
+	return ""
 }
 
 func CreateFromInt(val int64) *Gobj {
+	if val >= 0 && val < SharedIntegers {
+		return sharedIntegers[val]
+	}
 	return &Gobj{
 		Type_:    GSTR,
-		Val_:     strconv.FormatInt(val, 10),
+		Val_:     val,
+		refCount: 1,
+	}
+}
+
+// 与redis保持一致，无穷大输出为inf和-inf
+func FormatFloat(val float64) string {
+	if math.IsInf(val, 1) {
+		return "inf"
+	} else if math.IsInf(val, -1) {
+		return "-inf"
+	}
+	return strconv.FormatFloat(val, 'g', -1, 64)
+}
+
+func CreateFromFloat(val float64) *Gobj {
+	return &Gobj{
+		Type_:    GSTR,
+		Val_:     FormatFloat(val),
 		refCount: 1,
 	}
 }
@@ -53,13 +147,192 @@ func CreateObject(typ GType, val any) *Gobj {
 	}
 }
 
+func gobjDup(o *Gobj) *Gobj {
+	o.IncrRefCount()
+	return o
+}
+
+// set中entry的val为nil
+func gobjRelease(o *Gobj) {
+	if o != nil {
+		o.DecrRefCount()
+	}
+}
+
+// GobjDictType key和val都是Gobj的dict，写入时增加引用计数，删除时减少引用计数
+var GobjDictType = DictType[*Gobj, *Gobj]{
+	HashFunc:      GStrHash,
+	EqualFunc:     GStrEqual,
+	KeyDup:        gobjDup,
+	ValDup:        gobjDup,
+	KeyDestructor: gobjRelease,
+	ValDestructor: gobjRelease,
+}
+
+// list、hash和zset创建时都使用listpack编码，元素增多后再转换
+func CreateListObject() *Gobj {
+	return CreateObject(GList, ListpackCreate())
+}
+
+func CreateHashObject() *Gobj {
+	return CreateObject(GDict, ListpackCreate())
+}
+
+// set使用val为nil的dict实现
+func CreateSetObject() *Gobj {
+	return CreateObject(GSet, DictCreate(GobjDictType))
+}
+
+func CreateIntsetObject() *Gobj {
+	return CreateObject(GSet, IntsetCreate())
+}
+
+func CreateZSetObject() *Gobj {
+	return CreateObject(GZSet, ZSetCreate())
+}
+
+func CreateStreamObject() *Gobj {
+	return CreateObject(GStream, StreamCreate())
+}
+
+func (o *Gobj) TypeName() string {
+	switch o.Type_ {
+	case GSTR:
+		return "string"
+	case GList:
+		return "list"
+	case GSet:
+		return "set"
+	case GZSet:
+		return "zset"
+	case GDict:
+		return "hash"
+	case GStream:
+		return "stream"
+	}
+	return "unknown"
+}
+
+// DupObject 深拷贝对象，集合中的元素不会被原地修改，因此只增加引用计数
+func DupObject(o *Gobj) *Gobj {
+	switch v := o.Val_.(type) {
+	case []byte:
+		return CreateObject(GSTR, append([]byte(nil), v...))
+	case string, int64:
+		return CreateObject(GSTR, v)
+	case *Listpack:
+		return CreateObject(o.Type_, v.Dup())
+	case *Intset:
+		return CreateObject(o.Type_, v.Dup())
+	case *Quicklist:
+		return CreateObject(o.Type_, v.Dup())
+	case *Dict[*Gobj, *Gobj]:
+		dict := DictCreate(GobjDictType)
+		v.ForEach(func(e *Entry[*Gobj, *Gobj]) {
+			// set的val为nil
+			if e.Val == nil {
+				dict.AddRaw(e.Key)
+			} else {
+				dict.Set(e.Key, e.Val)
+			}
+		})
+		return CreateObject(o.Type_, dict)
+	case *ZSet:
+		return CreateObject(GZSet, v.Dup())
+	case *Stream:
+		return CreateObject(GStream, v.Dup())
+	}
+	return nil
+}
+
 func (o *Gobj) IncrRefCount() {
-	o.refCount++
+	if o.refCount != SharedRefCount {
+		o.refCount++
+	}
 }
 
 func (o *Gobj) DecrRefCount() {
+	if o.refCount == SharedRefCount {
+		return
+	}
 	o.refCount--
 	if o.refCount == 0 {
 		o.Val_ = nil
 	}
 }
+
+// tryObjectEncoding 能无损表示为整数的字符串转换为整数编码，范围内的整数直接返回共享对象。
+// 被多处引用的对象不做修改
+func tryObjectEncoding(o *Gobj) *Gobj {
+	s, ok := o.Val_.(string)
+	if o.Type_ != GSTR || !ok || o.refCount > 1 {
+		return o
+	}
+	v, ok := stringToInt64(s)
+	if !ok {
+		return o
+	}
+	if v >= 0 && v < SharedIntegers {
+		return sharedIntegers[v]
+	}
+	o.Val_ = v
+	return o
+}
+
+// 从listpack中读取元素，整数可能返回共享对象
+func createObjectFromListpack(lp *Listpack, p int) *Gobj {
+	s, v, isInt := lp.Get(p)
+	if isInt {
+		return CreateFromInt(v)
+	}
+	return CreateObject(GSTR, s)
+}
+
+func (o *Gobj) Encoding() string {
+	switch v := o.Val_.(type) {
+	case int64:
+		return EncodingInt
+	case string:
+		if len(v) <= EmbstrSizeLimit {
+			return EncodingEmbstr
+		}
+		return EncodingRaw
+	case []byte:
+		return EncodingRaw
+	case *Listpack:
+		return EncodingListpack
+	case *Quicklist:
+		return EncodingQuicklist
+	case *Dict[*Gobj, *Gobj]:
+		return EncodingHashtable
+	case *Intset:
+		return EncodingIntset
+	case *ZSet:
+		if v.lp != nil {
+			return EncodingListpack
+		}
+		return EncodingSkiplist
+	case *Stream:
+		return EncodingStream
+	}
+	return "unknown"
+}
+
+func objectCommand(c *GodisClient) {
+	sub := strings.ToLower(c.args[1].StrVal())
+	if len(c.args) != 3 || (sub != "encoding" && sub != "refcount") {
+		c.AddReplyError("unknown subcommand or wrong number of arguments for '" + c.args[1].StrVal() + "'")
+		return
+	}
+
+	o := findKeyRead(c.db, c.args[2])
+	if o == nil {
+		c.AddReplyStr(ReplyNull)
+		return
+	}
+	if sub == "encoding" {
+		c.AddReplyBulkStr(o.Encoding())
+	} else {
+		c.AddReplyInt(int64(o.refCount))
+	}
+}
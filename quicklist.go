@@ -0,0 +1,446 @@
+package main
+
+import "math"
+
+// Quicklist 由listpack节点组成的双向链表，参考redis的quicklist.c。
+// 每个节点的大小受list-max-listpack-size限制，距离两端超过list-compress-depth的节点使用LZF压缩
+type Quicklist struct {
+	head, tail    *quicklistNode
+	count         int // 所有节点的元素数量之和
+	len           int // 节点数量
+	fill          int
+	compressDepth int // 两端不压缩的节点数量，为0时不压缩
+}
+
+type quicklistNode struct {
+	prev, next *quicklistNode
+	lp         *Listpack // 节点被压缩时为nil
+	compressed []byte
+	count      int // 元素数量
+	sz         int // 未压缩时listpack的字节数
+}
+
+const (
+	// 小于该大小的节点不压缩
+	quicklistMinCompressBytes = 48
+	// 压缩之后至少要减少的字节数，否则不压缩
+	quicklistMinCompressImprove = 8
+)
+
+// list-max-listpack-size为负数时对应的字节数限制
+var listpackOptimizationLevel = []int{4096, 8192, 16384, 32768, 65536}
+
+// list-max-listpack-size为正数时，listpack的大小仍然不能超过该值
+const listpackSizeSafetyLimit = 8192
+
+// quicklistNodeLimit 返回fill对应的字节数和元素数量限制
+func quicklistNodeLimit(fill int) (int, int) {
+	if fill >= 0 {
+		return listpackSizeSafetyLimit, fill
+	}
+	level := -fill - 1
+	if level >= len(listpackOptimizationLevel) {
+		level = len(listpackOptimizationLevel) - 1
+	}
+	return listpackOptimizationLevel[level], math.MaxInt
+}
+
+// listpackExceedsLimit 判断大小为bytes、包含count个元素的listpack是否超出fill的限制
+func listpackExceedsLimit(fill, bytes, count int) bool {
+	sz, cnt := quicklistNodeLimit(fill)
+	return bytes > sz || count > cnt
+}
+
+func QuicklistCreate(fill, compressDepth int) *Quicklist {
+	return &Quicklist{fill: fill, compressDepth: compressDepth}
+}
+
+func quicklistCreateNode(lp *Listpack) *quicklistNode {
+	return &quicklistNode{lp: lp, count: lp.Len(), sz: lp.Bytes()}
+}
+
+// 修改listpack之后更新节点的统计信息
+func (n *quicklistNode) update() {
+	n.count = n.lp.Len()
+	n.sz = n.lp.Bytes()
+}
+
+// compress 压缩节点，节点较小或者压缩效果不明显时保持不变
+func (n *quicklistNode) compress() {
+	if n.lp == nil || n.sz < quicklistMinCompressBytes {
+		return
+	}
+	buf := lzfCompress(n.lp.buf)
+	if buf == nil || len(buf)+quicklistMinCompressImprove >= n.sz {
+		return
+	}
+	n.compressed = buf
+	n.lp = nil
+}
+
+func (n *quicklistNode) decompress() {
+	if n.lp == nil {
+		n.lp = n.listpack()
+		n.compressed = nil
+	}
+}
+
+// listpack 返回节点的listpack，节点被压缩时返回解压后的副本，不会修改节点
+func (n *quicklistNode) listpack() *Listpack {
+	if n.lp != nil {
+		return n.lp
+	}
+	return &Listpack{buf: lzfDecompress(n.compressed, n.sz), num: n.count}
+}
+
+func (ql *Quicklist) Count() int {
+	return ql.count
+}
+
+// compress 保证两端compressDepth个节点不被压缩，node不在这个范围内时压缩node
+func (ql *Quicklist) compress(node *quicklistNode) {
+	if ql.compressDepth <= 0 {
+		return
+	}
+
+	inDepth := node == nil
+	fwd, rev := ql.head, ql.tail
+	for i := 0; i < ql.compressDepth && fwd != nil; i++ {
+		fwd.decompress()
+		rev.decompress()
+		if fwd == node || rev == node {
+			inDepth = true
+		}
+		fwd, rev = fwd.next, rev.prev
+	}
+	if !inDepth {
+		node.compress()
+	}
+	// 紧邻两端范围的节点可能是刚被挤出范围的
+	if ql.len > ql.compressDepth*2 {
+		fwd.compress()
+		rev.compress()
+	}
+}
+
+// 将节点n插入到old之前或之后，old为nil时quicklist为空
+func (ql *Quicklist) insertNode(old, n *quicklistNode, after bool) {
+	if old == nil {
+		ql.head, ql.tail = n, n
+	} else if after {
+		n.prev, n.next = old, old.next
+		if old.next != nil {
+			old.next.prev = n
+		} else {
+			ql.tail = n
+		}
+		old.next = n
+	} else {
+		n.prev, n.next = old.prev, old
+		if old.prev != nil {
+			old.prev.next = n
+		} else {
+			ql.head = n
+		}
+		old.prev = n
+	}
+	ql.len++
+	ql.count += n.count
+	ql.compress(n)
+}
+
+func (ql *Quicklist) delNode(n *quicklistNode) {
+	if n.prev != nil {
+		n.prev.next = n.next
+	} else {
+		ql.head = n.next
+	}
+	if n.next != nil {
+		n.next.prev = n.prev
+	} else {
+		ql.tail = n.prev
+	}
+	ql.len--
+	ql.count -= n.count
+	// 原本位于中间的节点可能进入两端的范围，需要解压
+	ql.compress(nil)
+}
+
+// 节点加入s之后是否仍然满足大小限制
+func (ql *Quicklist) nodeAllowInsert(n *quicklistNode, s string) bool {
+	return n != nil && !listpackExceedsLimit(ql.fill, n.sz+lpEntrySize(s), n.count+1)
+}
+
+// 在节点的头部或尾部加入s
+func (ql *Quicklist) nodePush(n *quicklistNode, s string, head bool) {
+	n.decompress()
+	if head {
+		n.lp.Prepend(s)
+	} else {
+		n.lp.Append(s)
+	}
+	n.update()
+	ql.count++
+	ql.compress(n)
+}
+
+// 创建只包含s的节点并插入到old之前或之后
+func (ql *Quicklist) insertNewNode(old *quicklistNode, s string, after bool) {
+	lp := ListpackCreate()
+	lp.Append(s)
+	ql.insertNode(old, quicklistCreateNode(lp), after)
+}
+
+func (ql *Quicklist) Push(s string, head bool) {
+	n := ql.tail
+	if head {
+		n = ql.head
+	}
+	if ql.nodeAllowInsert(n, s) {
+		ql.nodePush(n, s, head)
+	} else {
+		ql.insertNewNode(n, s, !head)
+	}
+}
+
+// AppendListpack 将lp作为一个新的节点加入尾部，lp不能为空
+func (ql *Quicklist) AppendListpack(lp *Listpack) {
+	ql.insertNode(ql.tail, quicklistCreateNode(lp), true)
+}
+
+// Pop 弹出头部或尾部的元素，quicklist为空时返回nil
+func (ql *Quicklist) Pop(head bool) *Gobj {
+	n := ql.tail
+	if head {
+		n = ql.head
+	}
+	if n == nil {
+		return nil
+	}
+
+	n.decompress()
+	p := n.lp.Last()
+	if head {
+		p = n.lp.First()
+	}
+	val := createObjectFromListpack(n.lp, p)
+	n.lp.Delete(p)
+	n.update()
+	ql.count--
+	if n.count == 0 {
+		ql.delNode(n)
+	}
+	return val
+}
+
+// 返回下标为index的元素所在的节点以及在节点中的下标，index为负数时从尾部开始计数
+func (ql *Quicklist) locate(index int) (*quicklistNode, int) {
+	if index < 0 {
+		index += ql.count
+	}
+	if index < 0 || index >= ql.count {
+		return nil, 0
+	}
+
+	// 从距离较近的一端开始查找
+	if index < ql.count/2 {
+		for n := ql.head; n != nil; n = n.next {
+			if index < n.count {
+				return n, index
+			}
+			index -= n.count
+		}
+		return nil, 0
+	}
+	index = ql.count - 1 - index
+	for n := ql.tail; n != nil; n = n.prev {
+		if index < n.count {
+			return n, n.count - 1 - index
+		}
+		index -= n.count
+	}
+	return nil, 0
+}
+
+// DelRange 删除从start开始的num个元素，start为负数时从尾部开始计数
+func (ql *Quicklist) DelRange(start, num int) {
+	n, idx := ql.locate(start)
+	for n != nil && num > 0 {
+		next := n.next
+		del := n.count - idx
+		if del > num {
+			del = num
+		}
+		if del == n.count {
+			ql.delNode(n)
+		} else {
+			n.decompress()
+			n.lp.DeleteRange(n.lp.Seek(idx), del)
+			n.update()
+			ql.count -= del
+			ql.compress(n)
+		}
+		num -= del
+		n, idx = next, 0
+	}
+}
+
+func (ql *Quicklist) Dup() *Quicklist {
+	dup := QuicklistCreate(ql.fill, ql.compressDepth)
+	for n := ql.head; n != nil; n = n.next {
+		node := &quicklistNode{count: n.count, sz: n.sz, prev: dup.tail}
+		if n.lp != nil {
+			node.lp = n.lp.Dup()
+		} else {
+			node.compressed = append([]byte(nil), n.compressed...)
+		}
+		if dup.tail != nil {
+			dup.tail.next = node
+		} else {
+			dup.head = node
+		}
+		dup.tail = node
+		dup.len++
+		dup.count += node.count
+	}
+	return dup
+}
+
+// QuicklistIter 从指定的下标开始向尾部或者向头部遍历quicklist
+type QuicklistIter struct {
+	ql      *Quicklist
+	reverse bool
+	node    *quicklistNode
+	lp      *Listpack // 当前节点的listpack，节点被压缩时是解压后的副本
+	p       int
+	// 下一个元素的位置，nextP为-1表示nextNode的第一个元素
+	nextNode *quicklistNode
+	nextP    int
+}
+
+// Iterator 返回从index开始遍历的迭代器，index为负数时从尾部开始计数
+func (ql *Quicklist) Iterator(index int, reverse bool) *QuicklistIter {
+	it := &QuicklistIter{ql: ql, reverse: reverse, nextP: -1}
+	n, idx := ql.locate(index)
+	if n != nil {
+		it.node, it.nextNode = n, n
+		it.lp = n.listpack()
+		it.nextP = it.lp.Seek(idx)
+	}
+	return it
+}
+
+// Next 移动到下一个元素，没有更多元素时返回false
+func (it *QuicklistIter) Next() bool {
+	if it.nextNode == nil {
+		return false
+	}
+	if it.nextNode != it.node {
+		it.node = it.nextNode
+		it.lp = it.node.listpack()
+	}
+	if it.nextP == -1 {
+		if it.reverse {
+			it.nextP = it.lp.Last()
+		} else {
+			it.nextP = it.lp.First()
+		}
+	}
+
+	it.p = it.nextP
+	if it.reverse {
+		it.nextP = it.lp.Prev(it.p)
+	} else {
+		it.nextP = it.lp.Next(it.p)
+	}
+	if it.nextP == -1 {
+		if it.reverse {
+			it.nextNode = it.node.prev
+		} else {
+			it.nextNode = it.node.next
+		}
+	}
+	return true
+}
+
+// Get 返回当前的元素
+func (it *QuicklistIter) Get() *Gobj {
+	return createObjectFromListpack(it.lp, it.p)
+}
+
+func (it *QuicklistIter) Compare(s string) bool {
+	return it.lp.Compare(it.p, s)
+}
+
+// Delete 删除当前的元素，之后可以继续调用Next
+func (it *QuicklistIter) Delete() {
+	n := it.node
+	n.decompress()
+	next := n.lp.Delete(it.p)
+	n.update()
+	it.ql.count--
+	if n.count == 0 {
+		// 节点中唯一的元素被删除，nextNode已经指向相邻的节点
+		it.ql.delNode(n)
+		it.node = nil
+		return
+	}
+
+	it.lp = n.lp
+	// 向尾部遍历时后面的entry会前移到当前位置
+	if !it.reverse && next != -1 {
+		it.nextP = next
+	}
+	it.ql.compress(n)
+}
+
+// Replace 将当前元素替换为s
+func (it *QuicklistIter) Replace(s string) {
+	n := it.node
+	n.decompress()
+	n.lp.Replace(it.p, s)
+	n.update()
+	it.lp = n.lp
+	if !it.reverse && it.nextNode == n {
+		it.nextP = it.lp.Next(it.p)
+	}
+	it.ql.compress(n)
+}
+
+// Insert 在当前元素之前或之后插入s，节点已满时优先放入相邻的节点，否则拆分节点。
+// 插入之后不能继续遍历
+func (it *QuicklistIter) Insert(s string, after bool) {
+	ql, n := it.ql, it.node
+	atTail := it.lp.Next(it.p) == -1
+	atHead := it.p == it.lp.First()
+	switch {
+	case ql.nodeAllowInsert(n, s):
+		n.decompress()
+		n.lp.Insert(it.p, s, after)
+		n.update()
+		ql.count++
+		ql.compress(n)
+	case after && atTail && ql.nodeAllowInsert(n.next, s):
+		ql.nodePush(n.next, s, true)
+	case !after && atHead && ql.nodeAllowInsert(n.prev, s):
+		ql.nodePush(n.prev, s, false)
+	case (after && atTail) || (!after && atHead):
+		ql.insertNewNode(n, s, after)
+	default:
+		// 从插入位置拆分成两个节点，s加入前一个节点的尾部
+		n.decompress()
+		split := it.p
+		if after {
+			split = n.lp.Next(it.p)
+		}
+		rest := quicklistCreateNode(n.lp.Split(split))
+		n.update()
+		ql.count -= rest.count
+		ql.insertNode(n, rest, true)
+		if ql.nodeAllowInsert(n, s) {
+			ql.nodePush(n, s, false)
+		} else {
+			ql.insertNewNode(n, s, true)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func quicklistValues(ql *Quicklist) []string {
+	var vals []string
+	it := ql.Iterator(0, false)
+	for it.Next() {
+		vals = append(vals, it.Get().StrVal())
+	}
+	return vals
+}
+
+func TestLzf(t *testing.T) {
+	in := []byte(strings.Repeat("hello quicklist ", 100) + "tail")
+	out := lzfCompress(in)
+	assert.True(t, len(out) < len(in))
+	assert.Equal(t, in, lzfDecompress(out, len(in)))
+
+	// 长匹配和超过32个字节的字面量
+	in = append(bytes.Repeat([]byte{'a'}, 1000), []byte("0123456789abcdefghijklmnopqrstuvwxyz")...)
+	assert.Equal(t, in, lzfDecompress(lzfCompress(in), len(in)))
+	assert.Nil(t, lzfCompress([]byte("abcdefgh")))
+}
+
+func TestQuicklistPushPop(t *testing.T) {
+	ql := QuicklistCreate(4, 0)
+	for i := 0; i < 10; i++ {
+		ql.Push(strconv.Itoa(i), false)
+	}
+	ql.Push("head", true)
+	assert.Equal(t, 11, ql.Count())
+	assert.Equal(t, 4, ql.len)
+	assert.Equal(t, []string{"head", "0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, quicklistValues(ql))
+
+	assert.Equal(t, "head", ql.Pop(true).StrVal())
+	assert.Equal(t, "9", ql.Pop(false).StrVal())
+	for ql.Count() > 0 {
+		ql.Pop(true)
+	}
+	assert.Equal(t, 0, ql.len)
+	assert.Nil(t, ql.Pop(false))
+}
+
+func TestQuicklistCompress(t *testing.T) {
+	ql := QuicklistCreate(10, 1)
+	val := strings.Repeat("v", 20)
+	for i := 0; i < 100; i++ {
+		ql.Push(val+strconv.Itoa(i), false)
+	}
+	assert.Equal(t, 10, ql.len)
+	assert.NotNil(t, ql.head.lp)
+	assert.NotNil(t, ql.tail.lp)
+	for n := ql.head.next; n != ql.tail; n = n.next {
+		assert.Nil(t, n.lp)
+	}
+
+	// 读取压缩的节点不会解压节点
+	it := ql.Iterator(55, false)
+	assert.True(t, it.Next())
+	assert.Equal(t, val+"55", it.Get().StrVal())
+	assert.Nil(t, it.node.lp)
+
+	// 删除头部节点之后，新的头部节点被解压
+	ql.DelRange(0, 10)
+	assert.NotNil(t, ql.head.lp)
+	assert.Equal(t, val+"10", quicklistValues(ql)[0])
+
+	dup := ql.Dup()
+	assert.Equal(t, quicklistValues(ql), quicklistValues(dup))
+}
+
+func TestQuicklistIterator(t *testing.T) {
+	ql := QuicklistCreate(3, 1)
+	for i := 0; i < 9; i++ {
+		ql.Push(strconv.Itoa(i), false)
+	}
+
+	// 删除所有的偶数，经过的节点会被清空并删除
+	it := ql.Iterator(0, false)
+	for it.Next() {
+		if v, _ := it.Get().TryIntVal(); v%2 == 0 || v < 3 {
+			it.Delete()
+		}
+	}
+	assert.Equal(t, []string{"3", "5", "7"}, quicklistValues(ql))
+
+	it = ql.Iterator(-1, true)
+	var rev []string
+	for it.Next() {
+		rev = append(rev, it.Get().StrVal())
+		if it.Compare("5") {
+			it.Replace("five")
+		}
+	}
+	assert.Equal(t, []string{"7", "5", "3"}, rev)
+	assert.Equal(t, []string{"3", "five", "7"}, quicklistValues(ql))
+
+	// 节点已满时拆分节点
+	ql = QuicklistCreate(3, 0)
+	for i := 0; i < 3; i++ {
+		ql.Push(strconv.Itoa(i), false)
+	}
+	it = ql.Iterator(1, false)
+	it.Next()
+	it.Insert("x", true)
+	assert.Equal(t, []string{"0", "1", "x", "2"}, quicklistValues(ql))
+	assert.Equal(t, 2, ql.len)
+	it = ql.Iterator(0, false)
+	it.Next()
+	it.Insert("y", false)
+	assert.Equal(t, []string{"y", "0", "1", "x", "2"}, quicklistValues(ql))
+	assert.Equal(t, 5, ql.Count())
+}
@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// SipHash-1-3，参考redis的siphash.c
+// 与SipHash-2-4相比每个block只做一轮压缩、结束时做三轮，速度更快，对于哈希表来说安全性已经足够
+
+func sipRound(v0, v1, v2, v3 uint64) (uint64, uint64, uint64, uint64) {
+	v0 += v1
+	v1 = bits.RotateLeft64(v1, 13)
+	v1 ^= v0
+	v0 = bits.RotateLeft64(v0, 32)
+	v2 += v3
+	v3 = bits.RotateLeft64(v3, 16)
+	v3 ^= v2
+	v0 += v3
+	v3 = bits.RotateLeft64(v3, 21)
+	v3 ^= v0
+	v2 += v1
+	v1 = bits.RotateLeft64(v1, 17)
+	v1 ^= v2
+	v2 = bits.RotateLeft64(v2, 32)
+	return v0, v1, v2, v3
+}
+
+// 按小端序读取8个字节，nocase为true时先转换为小写
+func sipLoad(in string, nocase bool) uint64 {
+	var m uint64
+	for i := len(in) - 1; i >= 0; i-- {
+		c := in[i]
+		if nocase {
+			c = toLowerByte(c)
+		}
+		m = m<<8 | uint64(c)
+	}
+	return m
+}
+
+func siphashGeneric(in string, k *[16]byte, nocase bool) uint64 {
+	k0 := binary.LittleEndian.Uint64(k[:8])
+	k1 := binary.LittleEndian.Uint64(k[8:])
+	v0 := 0x736f6d6570736575 ^ k0
+	v1 := 0x646f72616e646f6d ^ k1
+	v2 := 0x6c7967656e657261 ^ k0
+	v3 := 0x7465646279746573 ^ k1
+
+	b := uint64(len(in)) << 56
+	for ; len(in) >= 8; in = in[8:] {
+		m := sipLoad(in[:8], nocase)
+		v3 ^= m
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+		v0 ^= m
+	}
+
+	// 剩余不足8个字节的部分与长度一起组成最后一个block
+	b |= sipLoad(in, nocase)
+	v3 ^= b
+	v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	v0 ^= b
+
+	v2 ^= 0xff
+	for i := 0; i < 3; i++ {
+		v0, v1, v2, v3 = sipRound(v0, v1, v2, v3)
+	}
+	return v0 ^ v1 ^ v2 ^ v3
+}
+
+func siphash(in string, k *[16]byte) uint64 {
+	return siphashGeneric(in, k, false)
+}
+
+// siphashNocase 忽略ASCII字母的大小写，用于命令表这类大小写不敏感的dict
+func siphashNocase(in string, k *[16]byte) uint64 {
+	return siphashGeneric(in, k, true)
+}
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"math/rand"
+	"strings"
+)
+
+const (
+	SkipListMaxLevel int     = 32
+	SkipListP        float64 = 0.25
+)
+
+type skipListLevel struct {
+	forward *SkipListNode
+	span    int64 // 到forward节点之间跨越的节点数，用于计算排名
+}
+
+type SkipListNode struct {
+	Member   *Gobj
+	Score    float64
+	backward *SkipListNode
+	level    []skipListLevel
+}
+
+// SkipList 按score从小到大排序，score相同时按member的字典序排序
+type SkipList struct {
+	header *SkipListNode
+	tail   *SkipListNode
+	length int64
+	level  int
+}
+
+// ZRangeSpec score范围，MinEx/MaxEx表示开区间
+type ZRangeSpec struct {
+	Min, Max     float64
+	MinEx, MaxEx bool
+}
+
+// ZLexBound 字典序范围的边界，Inf为-1表示"-"，为1表示"+"
+type ZLexBound struct {
+	Val string
+	Inf int
+	Ex  bool
+}
+
+type ZLexRangeSpec struct {
+	Min, Max ZLexBound
+}
+
+func createSkipListNode(level int, score float64, member *Gobj) *SkipListNode {
+	return &SkipListNode{
+		Member: member,
+		Score:  score,
+		level:  make([]skipListLevel, level),
+	}
+}
+
+func SkipListCreate() *SkipList {
+	return &SkipList{
+		header: createSkipListNode(SkipListMaxLevel, 0, nil),
+		level:  1,
+	}
+}
+
+func (n *SkipListNode) Next() *SkipListNode {
+	return n.level[0].forward
+}
+
+func (n *SkipListNode) Prev() *SkipListNode {
+	return n.backward
+}
+
+func (zsl *SkipList) Length() int64 {
+	return zsl.length
+}
+
+func (zsl *SkipList) First() *SkipListNode {
+	return zsl.header.level[0].forward
+}
+
+func (zsl *SkipList) Last() *SkipListNode {
+	return zsl.tail
+}
+
+// 每多一层的概率为SkipListP
+func randomLevel() int {
+	level := 1
+	for level < SkipListMaxLevel && rand.Float64() < SkipListP {
+		level++
+	}
+	return level
+}
+
+// 节点n是否排在(score, member)之前
+func nodeLess(n *SkipListNode, score float64, member *Gobj) bool {
+	return n.Score < score || (n.Score == score && n.Member.StrVal() < member.StrVal())
+}
+
+// Insert 插入一个新节点，调用方需要保证member不存在
+func (zsl *SkipList) Insert(score float64, member *Gobj) *SkipListNode {
+	var update [SkipListMaxLevel]*SkipListNode
+	var rank [SkipListMaxLevel]int64
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		if i != zsl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && nodeLess(x.level[i].forward, score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > zsl.level {
+		for i := zsl.level; i < level; i++ {
+			rank[i] = 0
+			update[i] = zsl.header
+			update[i].level[i].span = zsl.length
+		}
+		zsl.level = level
+	}
+
+	x = createSkipListNode(level, score, member)
+	member.IncrRefCount()
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = (rank[0] - rank[i]) + 1
+	}
+	// 更高的层没有指向新节点，只需要把跨度+1
+	for i := level; i < zsl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != zsl.header {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		zsl.tail = x
+	}
+	zsl.length++
+	return x
+}
+
+func (zsl *SkipList) deleteNode(x *SkipListNode, update []*SkipListNode) {
+	for i := 0; i < zsl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		zsl.tail = x.backward
+	}
+	for zsl.level > 1 && zsl.header.level[zsl.level-1].forward == nil {
+		zsl.level--
+	}
+	zsl.length--
+}
+
+// 找到每一层中排在(score, member)之前的最后一个节点
+func (zsl *SkipList) findUpdate(score float64, member *Gobj) []*SkipListNode {
+	update := make([]*SkipListNode, SkipListMaxLevel)
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && nodeLess(x.level[i].forward, score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	return update
+}
+
+// Delete 删除score和member都匹配的节点
+func (zsl *SkipList) Delete(score float64, member *Gobj) bool {
+	update := zsl.findUpdate(score, member)
+	x := update[0].level[0].forward
+	if x != nil && x.Score == score && x.Member.StrVal() == member.StrVal() {
+		zsl.deleteNode(x, update)
+		x.Member.DecrRefCount()
+		return true
+	}
+	return false
+}
+
+// UpdateScore 修改节点的score，如果位置不变则直接原地修改
+func (zsl *SkipList) UpdateScore(curScore float64, member *Gobj, newScore float64) *SkipListNode {
+	update := zsl.findUpdate(curScore, member)
+	x := update[0].level[0].forward
+	if x == nil || x.Score != curScore || x.Member.StrVal() != member.StrVal() {
+		return nil
+	}
+
+	if (x.backward == nil || x.backward.Score < newScore) &&
+		(x.level[0].forward == nil || x.level[0].forward.Score > newScore) {
+		x.Score = newScore
+		return x
+	}
+
+	zsl.deleteNode(x, update)
+	n := zsl.Insert(newScore, x.Member)
+	// Insert增加了一次引用，原节点的引用需要释放
+	x.Member.DecrRefCount()
+	return n
+}
+
+// GetRank 获取节点的排名，从1开始，节点不存在时返回0
+func (zsl *SkipList) GetRank(score float64, member *Gobj) int64 {
+	var rank int64
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && (x.level[i].forward.Score < score ||
+			(x.level[i].forward.Score == score && x.level[i].forward.Member.StrVal() <= member.StrVal())) {
+			rank += x.level[i].span
+			x = x.level[i].forward
+		}
+		if x != zsl.header && x.Member.StrVal() == member.StrVal() {
+			return rank
+		}
+	}
+	return 0
+}
+
+// GetByRank 获取排名为rank的节点，rank从1开始
+func (zsl *SkipList) GetByRank(rank int64) *SkipListNode {
+	var traversed int64
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank && x != zsl.header {
+			return x
+		}
+	}
+	return nil
+}
+
+func (r *ZRangeSpec) gteMin(score float64) bool {
+	if r.MinEx {
+		return score > r.Min
+	}
+	return score >= r.Min
+}
+
+func (r *ZRangeSpec) lteMax(score float64) bool {
+	if r.MaxEx {
+		return score < r.Max
+	}
+	return score <= r.Max
+}
+
+// IsInRange 跳表中是否有节点落在范围内
+func (zsl *SkipList) IsInRange(r *ZRangeSpec) bool {
+	if r.Min > r.Max || (r.Min == r.Max && (r.MinEx || r.MaxEx)) {
+		return false
+	}
+	if zsl.tail == nil || !r.gteMin(zsl.tail.Score) {
+		return false
+	}
+	x := zsl.header.level[0].forward
+	return x != nil && r.lteMax(x.Score)
+}
+
+func (zsl *SkipList) FirstInRange(r *ZRangeSpec) *SkipListNode {
+	if !zsl.IsInRange(r) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.Score) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !r.lteMax(x.Score) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *SkipList) LastInRange(r *ZRangeSpec) *SkipListNode {
+	if !zsl.IsInRange(r) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.Score) {
+			x = x.level[i].forward
+		}
+	}
+	if !r.gteMin(x.Score) {
+		return nil
+	}
+	return x
+}
+
+// DeleteRangeByScore 删除score在范围内的所有节点，每删除一个节点都会调用fn
+func (zsl *SkipList) DeleteRangeByScore(r *ZRangeSpec, fn func(n *SkipListNode)) int64 {
+	update := make([]*SkipListNode, SkipListMaxLevel)
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.Score) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	var removed int64
+	x = x.level[0].forward
+	for x != nil && r.lteMax(x.Score) {
+		next := x.level[0].forward
+		zsl.deleteNode(x, update)
+		fn(x)
+		x.Member.DecrRefCount()
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// DeleteRangeByRank 删除排名在[start, end]内的所有节点，排名从1开始
+func (zsl *SkipList) DeleteRangeByRank(start, end int64, fn func(n *SkipListNode)) int64 {
+	update := make([]*SkipListNode, SkipListMaxLevel)
+	var traversed int64
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span < start {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	var removed int64
+	traversed++
+	x = x.level[0].forward
+	for x != nil && traversed <= end {
+		next := x.level[0].forward
+		zsl.deleteNode(x, update)
+		fn(x)
+		x.Member.DecrRefCount()
+		removed++
+		traversed++
+		x = next
+	}
+	return removed
+}
+
+// 比较边界与字符串s的大小
+func (b *ZLexBound) compare(s string) int {
+	if b.Inf != 0 {
+		return b.Inf
+	}
+	return strings.Compare(b.Val, s)
+}
+
+func (r *ZLexRangeSpec) gteMin(s string) bool {
+	if r.Min.Ex {
+		return r.Min.compare(s) < 0
+	}
+	return r.Min.compare(s) <= 0
+}
+
+func (r *ZLexRangeSpec) lteMax(s string) bool {
+	if r.Max.Ex {
+		return r.Max.compare(s) > 0
+	}
+	return r.Max.compare(s) >= 0
+}
+
+func (zsl *SkipList) IsInLexRange(r *ZLexRangeSpec) bool {
+	if r.Min.Inf == 1 || r.Max.Inf == -1 {
+		return false
+	}
+	if r.Min.Inf == 0 && r.Max.Inf == 0 {
+		cmp := strings.Compare(r.Min.Val, r.Max.Val)
+		if cmp > 0 || (cmp == 0 && (r.Min.Ex || r.Max.Ex)) {
+			return false
+		}
+	}
+
+	if zsl.tail == nil || !r.gteMin(zsl.tail.Member.StrVal()) {
+		return false
+	}
+	x := zsl.header.level[0].forward
+	return x != nil && r.lteMax(x.Member.StrVal())
+}
+
+func (zsl *SkipList) FirstInLexRange(r *ZLexRangeSpec) *SkipListNode {
+	if !zsl.IsInLexRange(r) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && !r.gteMin(x.level[i].forward.Member.StrVal()) {
+			x = x.level[i].forward
+		}
+	}
+	x = x.level[0].forward
+	if !r.lteMax(x.Member.StrVal()) {
+		return nil
+	}
+	return x
+}
+
+func (zsl *SkipList) LastInLexRange(r *ZLexRangeSpec) *SkipListNode {
+	if !zsl.IsInLexRange(r) {
+		return nil
+	}
+
+	x := zsl.header
+	for i := zsl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && r.lteMax(x.level[i].forward.Member.StrVal()) {
+			x = x.level[i].forward
+		}
+	}
+	if !r.gteMin(x.Member.StrVal()) {
+		return nil
+	}
+	return x
+}
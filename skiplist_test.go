@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSkipList(t *testing.T) {
+	zsl := SkipListCreate()
+	value := 100
+	for i := value - 1; i >= 0; i-- {
+		zsl.Insert(float64(i), CreateObject(GSTR, fmt.Sprintf("m%03d", i)))
+	}
+	assert.Equal(t, int64(value), zsl.Length())
+
+	for i := 0; i < value; i++ {
+		member := CreateObject(GSTR, fmt.Sprintf("m%03d", i))
+		assert.Equal(t, int64(i+1), zsl.GetRank(float64(i), member))
+		n := zsl.GetByRank(int64(i + 1))
+		assert.Equal(t, member.StrVal(), n.Member.StrVal())
+	}
+	assert.Nil(t, zsl.GetByRank(int64(value+1)))
+	assert.Equal(t, int64(0), zsl.GetRank(1, CreateObject(GSTR, "none")))
+
+	// score相同时按照member排序
+	m := CreateObject(GSTR, "a")
+	zsl.Insert(10, m)
+	assert.Equal(t, int64(11), zsl.GetRank(10, m))
+	assert.Equal(t, 2, m.refCount)
+	assert.True(t, zsl.Delete(10, m))
+	assert.Equal(t, 1, m.refCount)
+	assert.False(t, zsl.Delete(10, m))
+
+	n := zsl.UpdateScore(50, CreateObject(GSTR, "m050"), 1000)
+	assert.Equal(t, float64(1000), n.Score)
+	assert.Equal(t, n, zsl.Last())
+	assert.Equal(t, int64(value), zsl.GetRank(1000, n.Member))
+}
+
+func TestSkipListRange(t *testing.T) {
+	zsl := SkipListCreate()
+	for i := 0; i < 10; i++ {
+		zsl.Insert(float64(i), CreateObject(GSTR, fmt.Sprintf("m%d", i)))
+	}
+
+	r := &ZRangeSpec{Min: 2, Max: 5, MinEx: true}
+	assert.Equal(t, float64(3), zsl.FirstInRange(r).Score)
+	assert.Equal(t, float64(5), zsl.LastInRange(r).Score)
+	assert.Nil(t, zsl.FirstInRange(&ZRangeSpec{Min: 20, Max: 30}))
+	assert.Nil(t, zsl.FirstInRange(&ZRangeSpec{Min: 3, Max: 3, MaxEx: true}))
+
+	lr := &ZLexRangeSpec{Min: ZLexBound{Val: "m3"}, Max: ZLexBound{Inf: 1}}
+	assert.Equal(t, "m3", zsl.FirstInLexRange(lr).Member.StrVal())
+	assert.Equal(t, "m9", zsl.LastInLexRange(lr).Member.StrVal())
+
+	var deleted []float64
+	removed := zsl.DeleteRangeByScore(r, func(n *SkipListNode) {
+		deleted = append(deleted, n.Score)
+	})
+	assert.Equal(t, int64(3), removed)
+	assert.Equal(t, []float64{3, 4, 5}, deleted)
+
+	removed = zsl.DeleteRangeByRank(1, 2, func(n *SkipListNode) {})
+	assert.Equal(t, int64(2), removed)
+	assert.Equal(t, int64(5), zsl.Length())
+	assert.Equal(t, float64(2), zsl.First().Score)
+	assert.Nil(t, zsl.First().Prev())
+}
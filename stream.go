@@ -0,0 +1,355 @@
+package main
+
+import (
+	"math"
+	"sort"
+	"strconv"
+)
+
+// StreamID 由毫秒时间戳和同一毫秒内的序号组成
+type StreamID struct {
+	Ms, Seq uint64
+}
+
+var (
+	StreamIDMin = StreamID{0, 0}
+	StreamIDMax = StreamID{math.MaxUint64, math.MaxUint64}
+)
+
+func (id StreamID) Compare(o StreamID) int {
+	if id.Ms != o.Ms {
+		if id.Ms < o.Ms {
+			return -1
+		}
+		return 1
+	}
+	if id.Seq != o.Seq {
+		if id.Seq < o.Seq {
+			return -1
+		}
+		return 1
+	}
+	return 0
+}
+
+func (id StreamID) String() string {
+	return strconv.FormatUint(id.Ms, 10) + "-" + strconv.FormatUint(id.Seq, 10)
+}
+
+// Incr 返回下一个ID，已经是最大ID时返回false
+func (id StreamID) Incr() (StreamID, bool) {
+	if id.Seq == math.MaxUint64 {
+		if id.Ms == math.MaxUint64 {
+			return id, false
+		}
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return StreamID{id.Ms, id.Seq + 1}, true
+}
+
+// Decr 返回上一个ID，已经是最小ID时返回false
+func (id StreamID) Decr() (StreamID, bool) {
+	if id.Seq == 0 {
+		if id.Ms == 0 {
+			return id, false
+		}
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return StreamID{id.Ms, id.Seq - 1}, true
+}
+
+type StreamEntry struct {
+	ID     StreamID
+	Fields []*Gobj // field和value交替存放
+}
+
+// StreamNACK 已经投递给消费者但还没有被确认的消息
+type StreamNACK struct {
+	deliveryTime  int64
+	deliveryCount int64
+	consumer      *StreamConsumer
+}
+
+type StreamConsumer struct {
+	name     string
+	seenTime int64
+	pel      map[StreamID]*StreamNACK
+}
+
+// StreamCG 消费者组，pel中记录了所有消费者未确认的消息
+type StreamCG struct {
+	lastID    StreamID
+	pel       map[StreamID]*StreamNACK
+	consumers map[string]*StreamConsumer
+}
+
+// Stream 按ID从小到大保存所有消息，ID只会递增，新消息总是追加到末尾
+type Stream struct {
+	entries      []*StreamEntry
+	lastID       StreamID
+	maxDeletedID StreamID
+	entriesAdded int64
+	groups       map[string]*StreamCG
+}
+
+func StreamCreate() *Stream {
+	return &Stream{
+		groups: make(map[string]*StreamCG),
+	}
+}
+
+// Dup 拷贝stream以及所有的消费者组
+func (s *Stream) Dup() *Stream {
+	dup := StreamCreate()
+	for _, e := range s.entries {
+		fields := make([]*Gobj, len(e.Fields))
+		copy(fields, e.Fields)
+		dup.Append(e.ID, fields)
+	}
+	dup.lastID = s.lastID
+	dup.maxDeletedID = s.maxDeletedID
+	dup.entriesAdded = s.entriesAdded
+
+	for name, cg := range s.groups {
+		dupCG := dup.CreateCG(name, cg.lastID)
+		for _, consumer := range cg.consumers {
+			dupConsumer := dupCG.LookupConsumer(consumer.name, true)
+			dupConsumer.seenTime = consumer.seenTime
+			for id, nack := range consumer.pel {
+				dupNack := dupCG.Deliver(id, dupConsumer, nack.deliveryTime)
+				dupNack.deliveryCount = nack.deliveryCount
+			}
+		}
+	}
+	return dup
+}
+
+func (s *Stream) Length() int64 {
+	return int64(len(s.entries))
+}
+
+// seek 返回第一个ID大于等于id的消息的位置
+func (s *Stream) seek(id StreamID) int {
+	return sort.Search(len(s.entries), func(i int) bool {
+		return s.entries[i].ID.Compare(id) >= 0
+	})
+}
+
+// Append 追加一条消息，调用方需要保证id大于lastID
+func (s *Stream) Append(id StreamID, fields []*Gobj) {
+	for _, f := range fields {
+		f.IncrRefCount()
+	}
+	s.entries = append(s.entries, &StreamEntry{ID: id, Fields: fields})
+	s.lastID = id
+	s.entriesAdded++
+}
+
+func (s *Stream) Lookup(id StreamID) *StreamEntry {
+	i := s.seek(id)
+	if i < len(s.entries) && s.entries[i].ID == id {
+		return s.entries[i]
+	}
+	return nil
+}
+
+func freeStreamEntry(e *StreamEntry) {
+	for _, f := range e.Fields {
+		f.DecrRefCount()
+	}
+}
+
+func (s *Stream) Delete(id StreamID) bool {
+	i := s.seek(id)
+	if i >= len(s.entries) || s.entries[i].ID != id {
+		return false
+	}
+	freeStreamEntry(s.entries[i])
+	copy(s.entries[i:], s.entries[i+1:])
+	s.entries[len(s.entries)-1] = nil
+	s.entries = s.entries[:len(s.entries)-1]
+	if id.Compare(s.maxDeletedID) > 0 {
+		s.maxDeletedID = id
+	}
+	return true
+}
+
+// Range 返回[start, end]之间的消息，count为0时不限制数量
+func (s *Stream) Range(start, end StreamID, count int64, reverse bool) []*StreamEntry {
+	var result []*StreamEntry
+	if start.Compare(end) > 0 {
+		return result
+	}
+	lo, hi := s.seek(start), s.seek(end)
+	if hi < len(s.entries) && s.entries[hi].ID == end {
+		hi++
+	}
+	for i := lo; i < hi; i++ {
+		if count > 0 && int64(len(result)) >= count {
+			break
+		}
+		if reverse {
+			result = append(result, s.entries[lo+hi-1-i])
+		} else {
+			result = append(result, s.entries[i])
+		}
+	}
+	return result
+}
+
+// trimHead 从头部删除n条消息
+func (s *Stream) trimHead(n int) int64 {
+	for i := 0; i < n; i++ {
+		e := s.entries[i]
+		freeStreamEntry(e)
+		if e.ID.Compare(s.maxDeletedID) > 0 {
+			s.maxDeletedID = e.ID
+		}
+		s.entries[i] = nil
+	}
+	s.entries = s.entries[n:]
+	return int64(n)
+}
+
+// TrimByLen 删除最旧的消息使长度不超过maxLen，limit大于0时最多删除limit条
+func (s *Stream) TrimByLen(maxLen, limit int64) int64 {
+	n := s.Length() - maxLen
+	if n <= 0 {
+		return 0
+	}
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return s.trimHead(int(n))
+}
+
+// TrimByMinID 删除ID小于minID的消息，limit大于0时最多删除limit条
+func (s *Stream) TrimByMinID(minID StreamID, limit int64) int64 {
+	n := int64(s.seek(minID))
+	if limit > 0 && n > limit {
+		n = limit
+	}
+	return s.trimHead(int(n))
+}
+
+// CreateCG 创建消费者组，已经存在时返回nil
+func (s *Stream) CreateCG(name string, id StreamID) *StreamCG {
+	if _, ok := s.groups[name]; ok {
+		return nil
+	}
+	cg := &StreamCG{
+		lastID:    id,
+		pel:       make(map[StreamID]*StreamNACK),
+		consumers: make(map[string]*StreamConsumer),
+	}
+	s.groups[name] = cg
+	return cg
+}
+
+func (s *Stream) LookupCG(name string) *StreamCG {
+	return s.groups[name]
+}
+
+func (s *Stream) DestroyCG(name string) bool {
+	if _, ok := s.groups[name]; !ok {
+		return false
+	}
+	delete(s.groups, name)
+	return true
+}
+
+// LookupConsumer 查找消费者，create为true时不存在则创建
+func (cg *StreamCG) LookupConsumer(name string, create bool) *StreamConsumer {
+	consumer, ok := cg.consumers[name]
+	if !ok && create {
+		consumer = &StreamConsumer{
+			name:     name,
+			seenTime: GetMsTime(),
+			pel:      make(map[StreamID]*StreamNACK),
+		}
+		cg.consumers[name] = consumer
+	}
+	return consumer
+}
+
+// DeleteConsumer 删除消费者以及它未确认的消息，返回未确认消息的数量
+func (cg *StreamCG) DeleteConsumer(name string) int64 {
+	consumer, ok := cg.consumers[name]
+	if !ok {
+		return 0
+	}
+	for id := range consumer.pel {
+		delete(cg.pel, id)
+	}
+	delete(cg.consumers, name)
+	return int64(len(consumer.pel))
+}
+
+// SortedConsumers 按名字排序返回所有消费者
+func (cg *StreamCG) SortedConsumers() []*StreamConsumer {
+	consumers := make([]*StreamConsumer, 0, len(cg.consumers))
+	for _, consumer := range cg.consumers {
+		consumers = append(consumers, consumer)
+	}
+	sort.Slice(consumers, func(i, j int) bool { return consumers[i].name < consumers[j].name })
+	return consumers
+}
+
+// Deliver 将消息分配给消费者，消息已经在pel中时转移给新的消费者
+func (cg *StreamCG) Deliver(id StreamID, consumer *StreamConsumer, now int64) *StreamNACK {
+	nack, ok := cg.pel[id]
+	if ok {
+		delete(nack.consumer.pel, id)
+	} else {
+		nack = &StreamNACK{}
+		cg.pel[id] = nack
+	}
+	nack.consumer = consumer
+	nack.deliveryTime = now
+	consumer.pel[id] = nack
+	return nack
+}
+
+// Ack 确认消息，将其从消费者组和消费者的pel中删除
+func (cg *StreamCG) Ack(id StreamID) bool {
+	nack, ok := cg.pel[id]
+	if !ok {
+		return false
+	}
+	delete(nack.consumer.pel, id)
+	delete(cg.pel, id)
+	return true
+}
+
+// sortedPEL 按ID从小到大返回pel中不小于start的所有ID
+func sortedPEL(pel map[StreamID]*StreamNACK, start StreamID) []StreamID {
+	ids := make([]StreamID, 0, len(pel))
+	for id := range pel {
+		if id.Compare(start) >= 0 {
+			ids = append(ids, id)
+		}
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Compare(ids[j]) < 0 })
+	return ids
+}
+
+// NextID 计算新消息的ID，idGiven为false时自动生成，seqGiven为false时只自动生成序号
+func (s *Stream) NextID(id StreamID, idGiven, seqGiven bool) (StreamID, bool) {
+	if !idGiven {
+		now := uint64(GetMsTime())
+		if now > s.lastID.Ms {
+			return StreamID{now, 0}, true
+		}
+		return s.lastID.Incr()
+	}
+	if !seqGiven {
+		if id.Ms == s.lastID.Ms {
+			if s.lastID.Seq == math.MaxUint64 {
+				return id, false
+			}
+			return StreamID{id.Ms, s.lastID.Seq + 1}, true
+		}
+		return id, id.Ms > s.lastID.Ms
+	}
+	return id, id.Compare(s.lastID) > 0
+}
@@ -0,0 +1,330 @@
+package main
+
+import "math"
+
+// hash元素较少时使用listpack编码，field和value相邻保存。
+// field或value超过hash-max-listpack-value、或者元素数量超过hash-max-listpack-entries时转换为hashtable
+
+func hashTypeLength(o *Gobj) int {
+	switch h := o.Val_.(type) {
+	case *Listpack:
+		return h.Len() / 2
+	case *Dict[*Gobj, *Gobj]:
+		return int(h.Size())
+	}
+	panic("unknown hash encoding")
+}
+
+// 写入args之前检查是否有过长的字符串，有则转换为hashtable
+func hashTypeTryConversion(o *Gobj, args []*Gobj) {
+	if _, ok := o.Val_.(*Listpack); !ok {
+		return
+	}
+	for _, arg := range args {
+		if len(arg.StrVal()) > server.hashMaxListpackValue {
+			hashTypeConvert(o)
+			return
+		}
+	}
+}
+
+func hashTypeConvert(o *Gobj) {
+	lp := o.Val_.(*Listpack)
+	dict := DictCreate(GobjDictType)
+	for p := lp.First(); p != -1; p = lp.Next(lp.Next(p)) {
+		field := createObjectFromListpack(lp, p)
+		val := createObjectFromListpack(lp, lp.Next(p))
+		dict.Set(field, val)
+		field.DecrRefCount()
+		val.DecrRefCount()
+	}
+	o.Val_ = dict
+}
+
+// 返回field在listpack中的偏移量，不存在时返回-1
+func hashListpackFind(lp *Listpack, field *Gobj) int {
+	return lp.Find(lp.First(), field.StrVal(), 1)
+}
+
+// hashTypeGet 返回field对应的value，不存在时返回nil。
+// hashtable编码时返回的对象仍然属于hash，调用方不能修改也不需要释放
+func hashTypeGet(o *Gobj, field *Gobj) *Gobj {
+	switch h := o.Val_.(type) {
+	case *Listpack:
+		p := hashListpackFind(h, field)
+		if p == -1 {
+			return nil
+		}
+		return createObjectFromListpack(h, h.Next(p))
+	case *Dict[*Gobj, *Gobj]:
+		return h.Get(field)
+	}
+	return nil
+}
+
+func hashTypeExists(o *Gobj, field *Gobj) bool {
+	switch h := o.Val_.(type) {
+	case *Listpack:
+		return hashListpackFind(h, field) != -1
+	case *Dict[*Gobj, *Gobj]:
+		return h.Find(field) != nil
+	}
+	return false
+}
+
+// hashTypeSet 写入field，新建field时返回true
+func hashTypeSet(o *Gobj, field, val *Gobj) bool {
+	if lp, ok := o.Val_.(*Listpack); ok {
+		if p := hashListpackFind(lp, field); p != -1 {
+			lp.Replace(lp.Next(p), val.StrVal())
+			return false
+		}
+		lp.Append(field.StrVal())
+		lp.Append(val.StrVal())
+		if hashTypeLength(o) > server.hashMaxListpackEntries {
+			hashTypeConvert(o)
+		}
+		return true
+	}
+
+	dict := o.Val_.(*Dict[*Gobj, *Gobj])
+	created := dict.Find(field) == nil
+	dict.Set(field, val)
+	return created
+}
+
+// hashTypeDelete 删除field，field不存在时返回false
+func hashTypeDelete(o *Gobj, field *Gobj) bool {
+	switch h := o.Val_.(type) {
+	case *Listpack:
+		p := hashListpackFind(h, field)
+		if p == -1 {
+			return false
+		}
+		h.DeleteRange(p, 2)
+		return true
+	case *Dict[*Gobj, *Gobj]:
+		return h.Delete(field) == nil
+	}
+	return false
+}
+
+// hashTypeForEach 遍历所有的field和value，回调中不能修改hash。参数只在回调中有效，需要保留时增加引用计数
+func hashTypeForEach(o *Gobj, fn func(field, val *Gobj)) {
+	switch h := o.Val_.(type) {
+	case *Listpack:
+		for p := h.First(); p != -1; p = h.Next(h.Next(p)) {
+			field := createObjectFromListpack(h, p)
+			val := createObjectFromListpack(h, h.Next(p))
+			fn(field, val)
+			field.DecrRefCount()
+			val.DecrRefCount()
+		}
+	case *Dict[*Gobj, *Gobj]:
+		h.ForEach(func(e *Entry[*Gobj, *Gobj]) {
+			fn(e.Key, e.Val)
+		})
+	}
+}
+
+// 查找hash对象，不存在时创建一个新的hash并写入数据库
+func hashLookupWriteOrCreate(c *GodisClient, key *Gobj) *Gobj {
+	hobj := findKeyWrite(c.db, key)
+	if hobj == nil {
+		hobj = CreateHashObject()
+		setKey(c.db, key, hobj)
+		hobj.DecrRefCount()
+	} else if checkType(c, hobj, GDict) {
+		return nil
+	}
+	return hobj
+}
+
+// 查找hash对象，key不存在时回复empty并返回nil
+func hashLookupRead(c *GodisClient, key *Gobj, empty string) *Gobj {
+	hobj := findKeyRead(c.db, key)
+	if hobj == nil {
+		c.AddReplyStr(empty)
+		return nil
+	}
+	if checkType(c, hobj, GDict) {
+		return nil
+	}
+	return hobj
+}
+
+func hsetCommand(c *GodisClient) {
+	if len(c.args)%2 != 0 {
+		c.AddReplyError("wrong number of arguments for 'hset' command")
+		return
+	}
+
+	hobj := hashLookupWriteOrCreate(c, c.args[1])
+	if hobj == nil {
+		return
+	}
+
+	hashTypeTryConversion(hobj, c.args[2:])
+	var created int64
+	for i := 2; i < len(c.args); i += 2 {
+		if hashTypeSet(hobj, c.args[i], c.args[i+1]) {
+			created++
+		}
+	}
+	c.AddReplyInt(created)
+}
+
+func hgetCommand(c *GodisClient) {
+	hobj := hashLookupRead(c, c.args[1], ReplyNull)
+	if hobj == nil {
+		return
+	}
+
+	val := hashTypeGet(hobj, c.args[2])
+	if val == nil {
+		c.AddReplyStr(ReplyNull)
+		return
+	}
+	c.AddReplyBulk(val)
+}
+
+func hmgetCommand(c *GodisClient) {
+	hobj := findKeyRead(c.db, c.args[1])
+	if hobj != nil && checkType(c, hobj, GDict) {
+		return
+	}
+
+	c.AddReplyArrayLen(len(c.args) - 2)
+	for _, field := range c.args[2:] {
+		var val *Gobj
+		if hobj != nil {
+			val = hashTypeGet(hobj, field)
+		}
+		if val == nil {
+			c.AddReplyStr(ReplyNull)
+		} else {
+			c.AddReplyBulk(val)
+		}
+	}
+}
+
+func hdelCommand(c *GodisClient) {
+	key := c.args[1]
+	hobj := findKeyWrite(c.db, key)
+	if hobj == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if checkType(c, hobj, GDict) {
+		return
+	}
+
+	var deleted int64
+	for _, field := range c.args[2:] {
+		if hashTypeDelete(hobj, field) {
+			deleted++
+		}
+	}
+
+	if hashTypeLength(hobj) == 0 {
+		deleteKey(c.db, key)
+	}
+	c.AddReplyInt(deleted)
+}
+
+func hincrbyCommand(c *GodisClient) {
+	incr, ok := getIntFromObjectOrReply(c, c.args[3])
+	if !ok {
+		return
+	}
+
+	hobj := hashLookupWriteOrCreate(c, c.args[1])
+	if hobj == nil {
+		return
+	}
+
+	var val int64
+	if old := hashTypeGet(hobj, c.args[2]); old != nil {
+		if val, ok = old.TryIntVal(); !ok {
+			c.AddReplyError("hash value is not an integer")
+			return
+		}
+	}
+
+	if (incr > 0 && val > math.MaxInt64-incr) || (incr < 0 && val < math.MinInt64-incr) {
+		c.AddReplyError("increment or decrement would overflow")
+		return
+	}
+	val += incr
+	o := CreateFromInt(val)
+	hashTypeTryConversion(hobj, c.args[2:3])
+	hashTypeSet(hobj, c.args[2], o)
+	o.DecrRefCount()
+	c.AddReplyInt(val)
+}
+
+func hexistsCommand(c *GodisClient) {
+	hobj := hashLookupRead(c, c.args[1], ":0\r\n")
+	if hobj == nil {
+		return
+	}
+
+	if hashTypeExists(hobj, c.args[2]) {
+		c.AddReplyInt(1)
+	} else {
+		c.AddReplyInt(0)
+	}
+}
+
+func hlenCommand(c *GodisClient) {
+	hobj := hashLookupRead(c, c.args[1], ":0\r\n")
+	if hobj == nil {
+		return
+	}
+	c.AddReplyInt(int64(hashTypeLength(hobj)))
+}
+
+func hashGetAll(c *GodisClient, withKeys, withVals bool) {
+	hobj := hashLookupRead(c, c.args[1], ReplyEmptyArray)
+	if hobj == nil {
+		return
+	}
+
+	n := hashTypeLength(hobj)
+	if withKeys && withVals {
+		n *= 2
+	}
+	c.AddReplyArrayLen(n)
+	hashTypeForEach(hobj, func(field, val *Gobj) {
+		if withKeys {
+			c.AddReplyBulk(field)
+		}
+		if withVals {
+			c.AddReplyBulk(val)
+		}
+	})
+}
+
+func hgetallCommand(c *GodisClient) {
+	hashGetAll(c, true, true)
+}
+
+func hkeysCommand(c *GodisClient) {
+	hashGetAll(c, true, false)
+}
+
+func hvalsCommand(c *GodisClient) {
+	hashGetAll(c, false, true)
+}
+
+func hscanCommand(c *GodisClient) {
+	hobj := findKeyRead(c.db, c.args[1])
+	if hobj == nil {
+		c.AddReplyStr(ReplyEmptyScan)
+		return
+	}
+	if checkType(c, hobj, GDict) {
+		return
+	}
+	scanGenericCommand(c, hobj, 2)
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":2\r\n", execCommand(c, "hset", "h", "f1", "v1", "f2", "v2"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "hset", "h", "f1", "v3"))
+	assert.Equal(t, "$2\r\nv3\r\n", execCommand(c, "hget", "h", "f1"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "hget", "h", "f3"))
+	assert.Equal(t, "*2\r\n$2\r\nv2\r\n$-1\r\n", execCommand(c, "hmget", "h", "f2", "f3"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "hlen", "h"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "hexists", "h", "f2"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "hexists", "h", "f3"))
+
+	assert.Equal(t, ":1\r\n", execCommand(c, "hdel", "h", "f1", "f3"))
+	assert.Equal(t, "*2\r\n$2\r\nf2\r\n$2\r\nv2\r\n", execCommand(c, "hgetall", "h"))
+	assert.Equal(t, "*1\r\n$2\r\nf2\r\n", execCommand(c, "hkeys", "h"))
+	assert.Equal(t, "*1\r\n$2\r\nv2\r\n", execCommand(c, "hvals", "h"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "hdel", "h", "f2"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "hlen", "h"))
+	assert.Nil(t, c.db.data.Get(CreateObject(GSTR, "h")))
+
+	execCommand(c, "set", "s", "v")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "hget", "s", "f"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'hset' command\r\n", execCommand(c, "hset", "h", "f1", "v1", "f2"))
+}
+
+func TestHincrby(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":5\r\n", execCommand(c, "hincrby", "h", "n", "5"))
+	assert.Equal(t, ":-5\r\n", execCommand(c, "hincrby", "h", "n", "-10"))
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "hincrby", "h", "n", "x"))
+	execCommand(c, "hset", "h", "s", "abc")
+	assert.Equal(t, "-ERR hash value is not an integer\r\n", execCommand(c, "hincrby", "h", "s", "1"))
+	execCommand(c, "hset", "h", "max", "9223372036854775807")
+	assert.Equal(t, "-ERR increment or decrement would overflow\r\n", execCommand(c, "hincrby", "h", "max", "1"))
+}
@@ -0,0 +1,615 @@
+package main
+
+import "strings"
+
+// list元素较少时使用listpack编码，超过list-max-listpack-size之后转换为quicklist，
+// 删除元素之后quicklist只剩一个较小的节点时再转换回listpack
+
+func listTypeLength(o *Gobj) int {
+	switch l := o.Val_.(type) {
+	case *Listpack:
+		return l.Len()
+	case *Quicklist:
+		return l.Count()
+	}
+	panic("unknown list encoding")
+}
+
+// 写入vals之前检查listpack是否会超出限制，超出时转换为quicklist
+func listTypeTryConversion(o *Gobj, vals []*Gobj) {
+	lp, ok := o.Val_.(*Listpack)
+	if !ok {
+		return
+	}
+	bytes, count := lp.Bytes(), lp.Len()
+	for _, v := range vals {
+		bytes += lpEntrySize(v.StrVal())
+		count++
+	}
+	if listpackExceedsLimit(server.listMaxListpackSize, bytes, count) {
+		listTypeConvert(o)
+	}
+}
+
+// listpack直接作为quicklist的第一个节点
+func listTypeConvert(o *Gobj) {
+	lp := o.Val_.(*Listpack)
+	ql := QuicklistCreate(server.listMaxListpackSize, server.listCompressDepth)
+	if lp.Len() > 0 {
+		ql.AppendListpack(lp)
+	}
+	o.Val_ = ql
+}
+
+// 删除元素之后调用，quicklist只有一个节点并且不超过限制的一半时转换回listpack，
+// 留出一半的空间避免反复转换
+func listTypeTryConvertQuicklist(o *Gobj) {
+	ql, ok := o.Val_.(*Quicklist)
+	if !ok || ql.len != 1 {
+		return
+	}
+	sz, count := quicklistNodeLimit(server.listMaxListpackSize)
+	if ql.head.sz > sz/2 || ql.count > count/2 {
+		return
+	}
+	ql.head.decompress()
+	o.Val_ = ql.head.lp
+}
+
+func listTypePush(o *Gobj, val *Gobj, head bool) {
+	switch l := o.Val_.(type) {
+	case *Listpack:
+		if head {
+			l.Prepend(val.StrVal())
+		} else {
+			l.Append(val.StrVal())
+		}
+	case *Quicklist:
+		l.Push(val.StrVal(), head)
+	}
+}
+
+// listTypePop 弹出头部或尾部的元素，调用方需要释放返回的对象，list为空时返回nil
+func listTypePop(o *Gobj, head bool) *Gobj {
+	switch l := o.Val_.(type) {
+	case *Listpack:
+		p := l.Last()
+		if head {
+			p = l.First()
+		}
+		if p == -1 {
+			return nil
+		}
+		val := createObjectFromListpack(l, p)
+		l.Delete(p)
+		return val
+	case *Quicklist:
+		return l.Pop(head)
+	}
+	return nil
+}
+
+// listTypeDelRange 删除从start开始的num个元素，start为负数时从尾部开始计数
+func listTypeDelRange(o *Gobj, start, num int) {
+	if num <= 0 {
+		return
+	}
+	switch l := o.Val_.(type) {
+	case *Listpack:
+		if p := l.Seek(start); p != -1 {
+			l.DeleteRange(p, num)
+		}
+	case *Quicklist:
+		l.DelRange(start, num)
+	}
+}
+
+// listTypeIterator 从指定的下标开始向尾部或者向头部遍历list
+type listTypeIterator struct {
+	subject *Gobj
+	reverse bool
+	// listpack编码时使用偏移量
+	p, next int
+	qi      *QuicklistIter
+}
+
+func listTypeInitIterator(o *Gobj, index int, reverse bool) *listTypeIterator {
+	it := &listTypeIterator{subject: o, reverse: reverse}
+	switch l := o.Val_.(type) {
+	case *Listpack:
+		it.next = l.Seek(index)
+	case *Quicklist:
+		it.qi = l.Iterator(index, reverse)
+	}
+	return it
+}
+
+// Next 移动到下一个元素，没有更多元素时返回false
+func (it *listTypeIterator) Next() bool {
+	switch l := it.subject.Val_.(type) {
+	case *Listpack:
+		it.p = it.next
+		if it.p == -1 {
+			return false
+		}
+		if it.reverse {
+			it.next = l.Prev(it.p)
+		} else {
+			it.next = l.Next(it.p)
+		}
+	case *Quicklist:
+		return it.qi.Next()
+	}
+	return true
+}
+
+// Get 返回当前的元素，调用方需要释放返回的对象
+func (it *listTypeIterator) Get() *Gobj {
+	if lp, ok := it.subject.Val_.(*Listpack); ok {
+		return createObjectFromListpack(lp, it.p)
+	}
+	return it.qi.Get()
+}
+
+func (it *listTypeIterator) Equal(o *Gobj) bool {
+	if lp, ok := it.subject.Val_.(*Listpack); ok {
+		return lp.Compare(it.p, o.StrVal())
+	}
+	return it.qi.Compare(o.StrVal())
+}
+
+// Delete 删除当前的元素，之后可以继续调用Next
+func (it *listTypeIterator) Delete() {
+	switch l := it.subject.Val_.(type) {
+	case *Listpack:
+		next := l.Delete(it.p)
+		// 向尾部遍历时后面的entry会前移到当前位置
+		if !it.reverse {
+			it.next = next
+		}
+	case *Quicklist:
+		it.qi.Delete()
+	}
+}
+
+// Insert 在当前元素之前或之后插入val，插入之后不能继续遍历
+func (it *listTypeIterator) Insert(val *Gobj, after bool) {
+	switch l := it.subject.Val_.(type) {
+	case *Listpack:
+		l.Insert(it.p, val.StrVal(), after)
+	case *Quicklist:
+		it.qi.Insert(val.StrVal(), after)
+	}
+}
+
+// Replace 将当前元素替换为val
+func (it *listTypeIterator) Replace(val *Gobj) {
+	switch l := it.subject.Val_.(type) {
+	case *Listpack:
+		l.Replace(it.p, val.StrVal())
+	case *Quicklist:
+		it.qi.Replace(val.StrVal())
+	}
+}
+
+// 删除元素之后调用，list为空时删除key，否则尝试转换回listpack
+func listElementsRemoved(db *GodisDB, key, lobj *Gobj) {
+	if listTypeLength(lobj) == 0 {
+		deleteKey(db, key)
+	} else {
+		listTypeTryConvertQuicklist(lobj)
+	}
+}
+
+func pushGenericCommand(c *GodisClient, head bool) {
+	key := c.args[1]
+	lobj := findKeyWrite(c.db, key)
+	if lobj == nil {
+		lobj = CreateListObject()
+		setKey(c.db, key, lobj)
+		lobj.DecrRefCount()
+	} else if checkType(c, lobj, GList) {
+		return
+	}
+
+	listTypeTryConversion(lobj, c.args[2:])
+	for _, v := range c.args[2:] {
+		listTypePush(lobj, v, head)
+	}
+	c.AddReplyInt(int64(listTypeLength(lobj)))
+}
+
+func lpushCommand(c *GodisClient) {
+	pushGenericCommand(c, true)
+}
+
+func rpushCommand(c *GodisClient) {
+	pushGenericCommand(c, false)
+}
+
+func popGenericCommand(c *GodisClient, head bool) {
+	if len(c.args) > 3 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	var count int64 = 1
+	hasCount := len(c.args) == 3
+	if hasCount {
+		var ok bool
+		if count, ok = getIntFromObjectOrReply(c, c.args[2]); !ok {
+			return
+		}
+		if count < 0 {
+			c.AddReplyError("value is out of range, must be positive")
+			return
+		}
+	}
+
+	key := c.args[1]
+	lobj := findKeyWrite(c.db, key)
+	if lobj == nil {
+		if hasCount {
+			c.AddReplyStr(ReplyNullArray)
+		} else {
+			c.AddReplyStr(ReplyNull)
+		}
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+
+	if hasCount {
+		if length := int64(listTypeLength(lobj)); count > length {
+			count = length
+		}
+		c.AddReplyArrayLen(int(count))
+	}
+	for i := int64(0); i < count; i++ {
+		val := listTypePop(lobj, head)
+		c.AddReplyBulk(val)
+		val.DecrRefCount()
+	}
+
+	listElementsRemoved(c.db, key, lobj)
+}
+
+// 阻塞版本的LPOP/RPOP，依次检查每个key，全部为空时阻塞等待
+func blockingPopGenericCommand(c *GodisClient, head bool) {
+	timeout, ok := getTimeoutFromObjectOrReply(c, c.args[len(c.args)-1])
+	if !ok {
+		return
+	}
+
+	keys := c.args[1 : len(c.args)-1]
+	for _, key := range keys {
+		lobj := findKeyWrite(c.db, key)
+		if lobj == nil {
+			continue
+		}
+		if checkType(c, lobj, GList) {
+			return
+		}
+
+		val := listTypePop(lobj, head)
+		c.AddReplyArrayLen(2)
+		c.AddReplyBulk(key)
+		c.AddReplyBulk(val)
+		val.DecrRefCount()
+		listElementsRemoved(c.db, key, lobj)
+		return
+	}
+	blockForKeys(c, GList, keys, timeout)
+}
+
+func blpopCommand(c *GodisClient) {
+	blockingPopGenericCommand(c, true)
+}
+
+func brpopCommand(c *GodisClient) {
+	blockingPopGenericCommand(c, false)
+}
+
+func lpopCommand(c *GodisClient) {
+	popGenericCommand(c, true)
+}
+
+func rpopCommand(c *GodisClient) {
+	popGenericCommand(c, false)
+}
+
+// 将[start, end]转换为合法的下标范围，范围为空时返回false
+func normalizeRange(start, end, length int64) (int64, int64, bool) {
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if start > end || start >= length {
+		return 0, 0, false
+	}
+	if end >= length {
+		end = length - 1
+	}
+	return start, end, true
+}
+
+func lrangeCommand(c *GodisClient) {
+	start, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	end, ok := getIntFromObjectOrReply(c, c.args[3])
+	if !ok {
+		return
+	}
+
+	lobj := findKeyRead(c.db, c.args[1])
+	if lobj == nil {
+		c.AddReplyStr(ReplyEmptyArray)
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+
+	start, end, ok = normalizeRange(start, end, int64(listTypeLength(lobj)))
+	if !ok {
+		c.AddReplyStr(ReplyEmptyArray)
+		return
+	}
+
+	c.AddReplyArrayLen(int(end - start + 1))
+	it := listTypeInitIterator(lobj, int(start), false)
+	for i := start; i <= end && it.Next(); i++ {
+		val := it.Get()
+		c.AddReplyBulk(val)
+		val.DecrRefCount()
+	}
+}
+
+func llenCommand(c *GodisClient) {
+	lobj := findKeyRead(c.db, c.args[1])
+	if lobj == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+	c.AddReplyInt(int64(listTypeLength(lobj)))
+}
+
+func lindexCommand(c *GodisClient) {
+	idx, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+
+	lobj := findKeyRead(c.db, c.args[1])
+	if lobj == nil {
+		c.AddReplyStr(ReplyNull)
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+
+	it := listTypeInitIterator(lobj, int(idx), false)
+	if !it.Next() {
+		c.AddReplyStr(ReplyNull)
+		return
+	}
+	val := it.Get()
+	c.AddReplyBulk(val)
+	val.DecrRefCount()
+}
+
+func lsetCommand(c *GodisClient) {
+	idx, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+
+	lobj := findKeyWrite(c.db, c.args[1])
+	if lobj == nil {
+		c.AddReplyStr(ReplyNoSuchKey)
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+
+	listTypeTryConversion(lobj, c.args[3:])
+	it := listTypeInitIterator(lobj, int(idx), false)
+	if !it.Next() {
+		c.AddReplyStr(ReplyOutOfRange)
+		return
+	}
+	it.Replace(c.args[3])
+	c.AddReplyStr(ReplyOK)
+}
+
+func ltrimCommand(c *GodisClient) {
+	start, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	end, ok := getIntFromObjectOrReply(c, c.args[3])
+	if !ok {
+		return
+	}
+
+	key := c.args[1]
+	lobj := findKeyWrite(c.db, key)
+	if lobj == nil {
+		c.AddReplyStr(ReplyOK)
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+
+	length := int64(listTypeLength(lobj))
+	var ltrim, rtrim int64
+	start, end, ok = normalizeRange(start, end, length)
+	if ok {
+		ltrim = start
+		rtrim = length - end - 1
+	} else {
+		// 范围为空，删除所有元素
+		ltrim = length
+	}
+
+	listTypeDelRange(lobj, 0, int(ltrim))
+	listTypeDelRange(lobj, -int(rtrim), int(rtrim))
+
+	listElementsRemoved(c.db, key, lobj)
+	c.AddReplyStr(ReplyOK)
+}
+
+func lremCommand(c *GodisClient) {
+	count, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+
+	key := c.args[1]
+	lobj := findKeyWrite(c.db, key)
+	if lobj == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+
+	// count>0 从头部开始删除，count<0 从尾部开始删除，count=0 删除全部
+	var it *listTypeIterator
+	if count >= 0 {
+		it = listTypeInitIterator(lobj, 0, false)
+	} else {
+		it = listTypeInitIterator(lobj, -1, true)
+		count = -count
+	}
+
+	var removed int64
+	for (count == 0 || removed < count) && it.Next() {
+		if it.Equal(c.args[3]) {
+			it.Delete()
+			removed++
+		}
+	}
+
+	listElementsRemoved(c.db, key, lobj)
+	c.AddReplyInt(removed)
+}
+
+func linsertCommand(c *GodisClient) {
+	var after bool
+	where := c.args[2].StrVal()
+	if strings.EqualFold(where, "after") {
+		after = true
+	} else if !strings.EqualFold(where, "before") {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	lobj := findKeyWrite(c.db, c.args[1])
+	if lobj == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if checkType(c, lobj, GList) {
+		return
+	}
+
+	listTypeTryConversion(lobj, c.args[4:])
+	it := listTypeInitIterator(lobj, 0, false)
+	for it.Next() {
+		if it.Equal(c.args[3]) {
+			it.Insert(c.args[4], after)
+			c.AddReplyInt(int64(listTypeLength(lobj)))
+			return
+		}
+	}
+	c.AddReplyInt(-1)
+}
+
+// 解析LEFT/RIGHT，LEFT返回true
+func getListPositionOrReply(c *GodisClient, o *Gobj) (bool, bool) {
+	switch strings.ToLower(o.StrVal()) {
+	case "left":
+		return true, true
+	case "right":
+		return false, true
+	}
+	c.AddReplyStr(ReplySyntaxErr)
+	return false, false
+}
+
+// 将src头部或尾部的元素移动到dst，blocking为true时src为空则阻塞等待
+func lmoveGenericCommand(c *GodisClient, src, dst *Gobj, fromHead, toHead, blocking bool, timeout int64) {
+	sobj := findKeyWrite(c.db, src)
+	if sobj == nil {
+		if blocking {
+			blockForKeys(c, GList, []*Gobj{src}, timeout)
+		} else {
+			c.AddReplyStr(ReplyNull)
+		}
+		return
+	}
+	if checkType(c, sobj, GList) {
+		return
+	}
+	dobj := findKeyWrite(c.db, dst)
+	if dobj != nil && checkType(c, dobj, GList) {
+		return
+	}
+
+	val := listTypePop(sobj, fromHead)
+	if dobj == nil {
+		dobj = CreateListObject()
+		setKey(c.db, dst, dobj)
+		dobj.DecrRefCount()
+	}
+	listTypeTryConversion(dobj, []*Gobj{val})
+	listTypePush(dobj, val, toHead)
+	c.AddReplyBulk(val)
+	val.DecrRefCount()
+
+	listElementsRemoved(c.db, src, sobj)
+}
+
+func lmoveCommand(c *GodisClient) {
+	fromHead, ok := getListPositionOrReply(c, c.args[3])
+	if !ok {
+		return
+	}
+	toHead, ok := getListPositionOrReply(c, c.args[4])
+	if !ok {
+		return
+	}
+	lmoveGenericCommand(c, c.args[1], c.args[2], fromHead, toHead, false, 0)
+}
+
+func blmoveCommand(c *GodisClient) {
+	fromHead, ok := getListPositionOrReply(c, c.args[3])
+	if !ok {
+		return
+	}
+	toHead, ok := getListPositionOrReply(c, c.args[4])
+	if !ok {
+		return
+	}
+	timeout, ok := getTimeoutFromObjectOrReply(c, c.args[5])
+	if !ok {
+		return
+	}
+	lmoveGenericCommand(c, c.args[1], c.args[2], fromHead, toHead, true, timeout)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestListPushPop(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":3\r\n", execCommand(c, "rpush", "l", "a", "b", "c"))
+	assert.Equal(t, ":4\r\n", execCommand(c, "lpush", "l", "z"))
+	assert.Equal(t, "*4\r\n$1\r\nz\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\nc\r\n", execCommand(c, "lrange", "l", "0", "-1"))
+	assert.Equal(t, "$1\r\nz\r\n", execCommand(c, "lpop", "l"))
+	assert.Equal(t, "*2\r\n$1\r\nc\r\n$1\r\nb\r\n", execCommand(c, "rpop", "l", "2"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "llen", "l"))
+	assert.Equal(t, "$1\r\na\r\n", execCommand(c, "rpop", "l"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "lpop", "l"))
+	assert.Equal(t, "*-1\r\n", execCommand(c, "lpop", "l", "1"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "llen", "l"))
+
+	execCommand(c, "set", "s", "v")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "lpush", "s", "a"))
+	execCommand(c, "rpush", "l", "a")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "get", "l"))
+}
+
+func TestListIndex(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "rpush", "l", "a", "b", "c", "d")
+	assert.Equal(t, "$1\r\nd\r\n", execCommand(c, "lindex", "l", "-1"))
+	assert.Equal(t, "$1\r\nb\r\n", execCommand(c, "lindex", "l", "1"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "lindex", "l", "4"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "lset", "l", "-2", "x"))
+	assert.Equal(t, ReplyOutOfRange, execCommand(c, "lset", "l", "10", "x"))
+	assert.Equal(t, ReplyNoSuchKey, execCommand(c, "lset", "none", "0", "x"))
+	assert.Equal(t, "*2\r\n$1\r\nx\r\n$1\r\nd\r\n", execCommand(c, "lrange", "l", "-2", "100"))
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "lrange", "l", "a", "1"))
+
+	assert.Equal(t, "+OK\r\n", execCommand(c, "ltrim", "l", "1", "-2"))
+	assert.Equal(t, "*2\r\n$1\r\nb\r\n$1\r\nx\r\n", execCommand(c, "lrange", "l", "0", "-1"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "ltrim", "l", "5", "10"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "llen", "l"))
+}
+
+func TestListRemInsert(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "rpush", "l", "a", "b", "a", "c", "a")
+	assert.Equal(t, ":1\r\n", execCommand(c, "lrem", "l", "-1", "a"))
+	assert.Equal(t, "*4\r\n$1\r\na\r\n$1\r\nb\r\n$1\r\na\r\n$1\r\nc\r\n", execCommand(c, "lrange", "l", "0", "-1"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "lrem", "l", "0", "a"))
+
+	assert.Equal(t, ":3\r\n", execCommand(c, "linsert", "l", "before", "b", "x"))
+	assert.Equal(t, ":4\r\n", execCommand(c, "linsert", "l", "AFTER", "c", "y"))
+	assert.Equal(t, ":-1\r\n", execCommand(c, "linsert", "l", "after", "none", "y"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "linsert", "l", "middle", "b", "y"))
+	assert.Equal(t, "*4\r\n$1\r\nx\r\n$1\r\nb\r\n$1\r\nc\r\n$1\r\ny\r\n", execCommand(c, "lrange", "l", "0", "-1"))
+}
@@ -0,0 +1,463 @@
+package main
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+)
+
+const (
+	SetOpUnion = iota
+	SetOpInter
+	SetOpDiff
+)
+
+// set的成员都是整数时使用intset编码，
+// 加入非整数成员或者成员数量超过set-max-intset-entries时转换为hashtable
+
+// setTypeCreate 创建一个能保存value的空set
+func setTypeCreate(value *Gobj) *Gobj {
+	if _, ok := stringToInt64(value.StrVal()); ok {
+		return CreateIntsetObject()
+	}
+	return CreateSetObject()
+}
+
+func setTypeConvert(o *Gobj) {
+	is := o.Val_.(*Intset)
+	dict := DictCreate(GobjDictType)
+	for i := 0; i < is.Len(); i++ {
+		member := CreateFromInt(is.Get(i))
+		dict.AddRaw(member)
+		member.DecrRefCount()
+	}
+	o.Val_ = dict
+}
+
+// setTypeAdd 加入member，member已经存在时返回false
+func setTypeAdd(o *Gobj, member *Gobj) bool {
+	if is, ok := o.Val_.(*Intset); ok {
+		v, ok := stringToInt64(member.StrVal())
+		if ok {
+			if !is.Add(v) {
+				return false
+			}
+			if is.Len() > server.setMaxIntsetEntries {
+				setTypeConvert(o)
+			}
+			return true
+		}
+		setTypeConvert(o)
+	}
+	return o.Val_.(*Dict[*Gobj, *Gobj]).AddRaw(member) != nil
+}
+
+// setTypeRemove 删除member，member不存在时返回false
+func setTypeRemove(o *Gobj, member *Gobj) bool {
+	switch set := o.Val_.(type) {
+	case *Intset:
+		v, ok := stringToInt64(member.StrVal())
+		return ok && set.Remove(v)
+	case *Dict[*Gobj, *Gobj]:
+		return set.Delete(member) == nil
+	}
+	return false
+}
+
+func setTypeIsMember(o *Gobj, member *Gobj) bool {
+	switch set := o.Val_.(type) {
+	case *Intset:
+		v, ok := stringToInt64(member.StrVal())
+		return ok && set.Find(v)
+	case *Dict[*Gobj, *Gobj]:
+		return set.Find(member) != nil
+	}
+	return false
+}
+
+func setTypeSize(o *Gobj) int64 {
+	switch set := o.Val_.(type) {
+	case *Intset:
+		return int64(set.Len())
+	case *Dict[*Gobj, *Gobj]:
+		return set.Size()
+	}
+	panic("unknown set encoding")
+}
+
+// setTypeForEach 遍历所有成员，回调中不能修改set。member只在回调中有效，需要保留时增加引用计数
+func setTypeForEach(o *Gobj, fn func(member *Gobj)) {
+	switch set := o.Val_.(type) {
+	case *Intset:
+		for i := 0; i < set.Len(); i++ {
+			member := CreateFromInt(set.Get(i))
+			fn(member)
+			member.DecrRefCount()
+		}
+	case *Dict[*Gobj, *Gobj]:
+		set.ForEach(func(e *Entry[*Gobj, *Gobj]) {
+			fn(e.Key)
+		})
+	}
+}
+
+// setTypeRandomElement 随机获取set中的一个成员，set为空时返回nil
+func setTypeRandomElement(o *Gobj) *Gobj {
+	if setTypeSize(o) == 0 {
+		return nil
+	}
+	switch set := o.Val_.(type) {
+	case *Intset:
+		return CreateFromInt(set.Random())
+	case *Dict[*Gobj, *Gobj]:
+		for {
+			if e := set.RandomGet(); e != nil {
+				return e.Key
+			}
+		}
+	}
+	return nil
+}
+
+// 查找set对象，key不存在时回复empty并返回nil
+func setLookupRead(c *GodisClient, key *Gobj, empty string) *Gobj {
+	sobj := findKeyRead(c.db, key)
+	if sobj == nil {
+		c.AddReplyStr(empty)
+		return nil
+	}
+	if checkType(c, sobj, GSet) {
+		return nil
+	}
+	return sobj
+}
+
+func saddCommand(c *GodisClient) {
+	key := c.args[1]
+	sobj := findKeyWrite(c.db, key)
+	if sobj == nil {
+		sobj = setTypeCreate(c.args[2])
+		setKey(c.db, key, sobj)
+		sobj.DecrRefCount()
+	} else if checkType(c, sobj, GSet) {
+		return
+	}
+
+	var added int64
+	for _, member := range c.args[2:] {
+		if setTypeAdd(sobj, member) {
+			added++
+		}
+	}
+	c.AddReplyInt(added)
+}
+
+func sremCommand(c *GodisClient) {
+	key := c.args[1]
+	sobj := findKeyWrite(c.db, key)
+	if sobj == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if checkType(c, sobj, GSet) {
+		return
+	}
+
+	var removed int64
+	for _, member := range c.args[2:] {
+		if setTypeRemove(sobj, member) {
+			removed++
+		}
+	}
+
+	if setTypeSize(sobj) == 0 {
+		deleteKey(c.db, key)
+	}
+	c.AddReplyInt(removed)
+}
+
+func addReplySetMembers(c *GodisClient, sobj *Gobj) {
+	c.AddReplyArrayLen(int(setTypeSize(sobj)))
+	setTypeForEach(sobj, func(member *Gobj) {
+		c.AddReplyBulk(member)
+	})
+}
+
+func smembersCommand(c *GodisClient) {
+	sobj := setLookupRead(c, c.args[1], ReplyEmptyArray)
+	if sobj == nil {
+		return
+	}
+	addReplySetMembers(c, sobj)
+}
+
+func sismemberCommand(c *GodisClient) {
+	sobj := setLookupRead(c, c.args[1], ":0\r\n")
+	if sobj == nil {
+		return
+	}
+
+	if setTypeIsMember(sobj, c.args[2]) {
+		c.AddReplyInt(1)
+	} else {
+		c.AddReplyInt(0)
+	}
+}
+
+func scardCommand(c *GodisClient) {
+	sobj := setLookupRead(c, c.args[1], ":0\r\n")
+	if sobj == nil {
+		return
+	}
+	c.AddReplyInt(setTypeSize(sobj))
+}
+
+func spopCommand(c *GodisClient) {
+	if len(c.args) > 3 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	var count int64 = 1
+	hasCount := len(c.args) == 3
+	if hasCount {
+		var ok bool
+		if count, ok = getIntFromObjectOrReply(c, c.args[2]); !ok {
+			return
+		}
+		if count < 0 {
+			c.AddReplyError("value is out of range, must be positive")
+			return
+		}
+	}
+
+	key := c.args[1]
+	empty := ReplyNull
+	if hasCount {
+		empty = ReplyEmptyArray
+	}
+	sobj := findKeyWrite(c.db, key)
+	if sobj == nil {
+		c.AddReplyStr(empty)
+		return
+	}
+	if checkType(c, sobj, GSet) {
+		return
+	}
+
+	if size := setTypeSize(sobj); count > size {
+		count = size
+	}
+	if hasCount {
+		c.AddReplyArrayLen(int(count))
+	}
+	for i := int64(0); i < count; i++ {
+		member := setTypeRandomElement(sobj)
+		// 先回复再删除，删除会释放member
+		c.AddReplyBulk(member)
+		setTypeRemove(sobj, member)
+	}
+
+	if setTypeSize(sobj) == 0 {
+		deleteKey(c.db, key)
+	}
+}
+
+func srandmemberCommand(c *GodisClient) {
+	if len(c.args) > 3 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	if len(c.args) == 2 {
+		sobj := setLookupRead(c, c.args[1], ReplyNull)
+		if sobj == nil {
+			return
+		}
+		c.AddReplyBulk(setTypeRandomElement(sobj))
+		return
+	}
+
+	count, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	// 和redis一样限制负数count的范围，避免取反溢出和回复过大
+	if count < -math.MaxInt64/2 {
+		c.AddReplyError("value is out of range")
+		return
+	}
+	sobj := setLookupRead(c, c.args[1], ReplyEmptyArray)
+	if sobj == nil {
+		return
+	}
+
+	// count为负数时允许返回重复的成员
+	if count < 0 {
+		c.AddReplyArrayLen(int(-count))
+		for i := int64(0); i < -count; i++ {
+			c.AddReplyBulk(setTypeRandomElement(sobj))
+		}
+		return
+	}
+
+	size := setTypeSize(sobj)
+	if count >= size {
+		addReplySetMembers(c, sobj)
+		return
+	}
+
+	// 需要的成员较多时，直接打乱所有成员再取前count个，否则随机获取并去重
+	var members []*Gobj
+	if count*3 > size {
+		setTypeForEach(sobj, func(member *Gobj) {
+			member.IncrRefCount()
+			members = append(members, member)
+		})
+		rand.Shuffle(len(members), func(i, j int) {
+			members[i], members[j] = members[j], members[i]
+		})
+		members = members[:count]
+	} else {
+		picked := make(map[string]bool, count)
+		for int64(len(members)) < count {
+			member := setTypeRandomElement(sobj)
+			if !picked[member.StrVal()] {
+				picked[member.StrVal()] = true
+				member.IncrRefCount()
+				members = append(members, member)
+			}
+		}
+	}
+
+	c.AddReplyArrayLen(len(members))
+	for _, member := range members {
+		c.AddReplyBulk(member)
+		member.DecrRefCount()
+	}
+}
+
+// 计算多个set的并集、交集或差集，结果是一个新的set对象。出现类型错误时返回nil
+func setOperation(c *GodisClient, keys []*Gobj, op int) *Gobj {
+	sets := make([]*Gobj, len(keys))
+	for i, key := range keys {
+		sobj := findKeyRead(c.db, key)
+		if sobj == nil {
+			continue
+		}
+		if checkType(c, sobj, GSet) {
+			return nil
+		}
+		sets[i] = sobj
+	}
+
+	// 结果先使用intset编码，加入非整数成员时自动转换
+	result := CreateIntsetObject()
+	switch op {
+	case SetOpUnion:
+		for _, set := range sets {
+			if set == nil {
+				continue
+			}
+			setTypeForEach(set, func(member *Gobj) {
+				setTypeAdd(result, member)
+			})
+		}
+	case SetOpInter:
+		for _, set := range sets {
+			if set == nil {
+				return result
+			}
+		}
+		// 从最小的set开始遍历，减少查找次数
+		sort.Slice(sets, func(i, j int) bool {
+			return setTypeSize(sets[i]) < setTypeSize(sets[j])
+		})
+		setTypeForEach(sets[0], func(member *Gobj) {
+			for _, set := range sets[1:] {
+				// 同一个key出现多次时不需要再查找
+				if set == sets[0] {
+					continue
+				}
+				if !setTypeIsMember(set, member) {
+					return
+				}
+			}
+			setTypeAdd(result, member)
+		})
+	case SetOpDiff:
+		if sets[0] == nil {
+			return result
+		}
+		setTypeForEach(sets[0], func(member *Gobj) {
+			for _, set := range sets[1:] {
+				if set != nil && setTypeIsMember(set, member) {
+					return
+				}
+			}
+			setTypeAdd(result, member)
+		})
+	}
+	return result
+}
+
+func setOperationCommand(c *GodisClient, keys []*Gobj, op int) {
+	result := setOperation(c, keys, op)
+	if result == nil {
+		return
+	}
+	addReplySetMembers(c, result)
+	result.DecrRefCount()
+}
+
+func setOperationStoreCommand(c *GodisClient, op int) {
+	result := setOperation(c, c.args[2:], op)
+	if result == nil {
+		return
+	}
+
+	dst := c.args[1]
+	deleteKey(c.db, dst)
+	size := setTypeSize(result)
+	if size > 0 {
+		setKey(c.db, dst, result)
+	}
+	result.DecrRefCount()
+	c.AddReplyInt(size)
+}
+
+func sinterCommand(c *GodisClient) {
+	setOperationCommand(c, c.args[1:], SetOpInter)
+}
+
+func sinterstoreCommand(c *GodisClient) {
+	setOperationStoreCommand(c, SetOpInter)
+}
+
+func sunionCommand(c *GodisClient) {
+	setOperationCommand(c, c.args[1:], SetOpUnion)
+}
+
+func sunionstoreCommand(c *GodisClient) {
+	setOperationStoreCommand(c, SetOpUnion)
+}
+
+func sdiffCommand(c *GodisClient) {
+	setOperationCommand(c, c.args[1:], SetOpDiff)
+}
+
+func sdiffstoreCommand(c *GodisClient) {
+	setOperationStoreCommand(c, SetOpDiff)
+}
+
+func sscanCommand(c *GodisClient) {
+	sobj := findKeyRead(c.db, c.args[1])
+	if sobj == nil {
+		c.AddReplyStr(ReplyEmptyScan)
+		return
+	}
+	if checkType(c, sobj, GSet) {
+		return
+	}
+	scanGenericCommand(c, sobj, 2)
+}
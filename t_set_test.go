@@ -0,0 +1,90 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// 将数组回复中的bulk元素解析出来并排序，便于比较无序的结果
+func sortedBulks(reply string) []string {
+	var res []string
+	lines := strings.Split(reply, "\r\n")
+	for i := 1; i < len(lines)-1; i += 2 {
+		res = append(res, lines[i+1])
+	}
+	sort.Strings(res)
+	return res
+}
+
+func TestSet(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":3\r\n", execCommand(c, "sadd", "s", "a", "b", "c"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "sadd", "s", "a", "d"))
+	assert.Equal(t, ":4\r\n", execCommand(c, "scard", "s"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "sismember", "s", "a"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "sismember", "s", "x"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "srem", "s", "a", "x", "b"))
+	assert.Equal(t, []string{"c", "d"}, sortedBulks(execCommand(c, "smembers", "s")))
+
+	assert.Equal(t, []string{"c", "d"}, sortedBulks(execCommand(c, "srandmember", "s", "5")))
+	assert.Equal(t, 5, len(sortedBulks(execCommand(c, "srandmember", "s", "-5"))))
+	assert.Equal(t, 1, len(sortedBulks(execCommand(c, "srandmember", "s", "1"))))
+	assert.Equal(t, "-ERR value is out of range\r\n", execCommand(c, "srandmember", "s", "-9223372036854775808"))
+	assert.Equal(t, "-ERR value is out of range\r\n", execCommand(c, "srandmember", "s", "-4611686018427387904"))
+
+	assert.Equal(t, 2, len(sortedBulks(execCommand(c, "spop", "s", "3"))))
+	assert.Equal(t, ":0\r\n", execCommand(c, "scard", "s"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "spop", "s"))
+
+	execCommand(c, "set", "str", "v")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "sadd", "str", "a"))
+	assert.Equal(t, ReplyWrongType, execCommand(c, "sunion", "str", "s"))
+}
+
+func TestSetOperation(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "sadd", "s1", "a", "b", "c", "d")
+	execCommand(c, "sadd", "s2", "c", "d", "e")
+	execCommand(c, "sadd", "s3", "a", "c", "e")
+
+	assert.Equal(t, []string{"c"}, sortedBulks(execCommand(c, "sinter", "s1", "s2", "s3")))
+	assert.Equal(t, "*0\r\n", execCommand(c, "sinter", "s1", "none"))
+	assert.Equal(t, []string{"a", "b", "c", "d", "e"}, sortedBulks(execCommand(c, "sunion", "s1", "s2", "none")))
+	assert.Equal(t, []string{"b"}, sortedBulks(execCommand(c, "sdiff", "s1", "s2", "s3")))
+
+	assert.Equal(t, ":2\r\n", execCommand(c, "sinterstore", "dst", "s1", "s2"))
+	assert.Equal(t, []string{"c", "d"}, sortedBulks(execCommand(c, "smembers", "dst")))
+	assert.Equal(t, ":2\r\n", execCommand(c, "sdiffstore", "s1", "s1", "dst"))
+	assert.Equal(t, []string{"a", "b"}, sortedBulks(execCommand(c, "smembers", "s1")))
+	assert.Equal(t, ":0\r\n", execCommand(c, "sunionstore", "dst", "none"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "scard", "dst"))
+}
+
+// 不断加入非整数成员，直到set的dict处于rehash过程中
+func addUntilRehashing(t *testing.T, c *GodisClient, key string) {
+	for i := 0; i < 10000; i++ {
+		execCommand(c, "sadd", key, "m"+strconv.Itoa(i))
+		if set, ok := findKeyRead(c.db, CreateObject(GSTR, key)).Val_.(*Dict[*Gobj, *Gobj]); ok && set.isRehashing() {
+			return
+		}
+	}
+	t.Fatal("set is not rehashing")
+}
+
+func TestSetOperationRehashing(t *testing.T) {
+	c := createTestClient(t)
+	addUntilRehashing(t, c, "s")
+	size := execCommand(c, "scard", "s")
+	assert.Equal(t, size, ":"+strconv.Itoa(len(sortedBulks(execCommand(c, "sinter", "s", "s"))))+"\r\n")
+
+	addUntilRehashing(t, c, "s")
+	assert.Equal(t, "*0\r\n", execCommand(c, "sdiff", "s", "s"))
+
+	addUntilRehashing(t, c, "s")
+	size = execCommand(c, "scard", "s")
+	assert.Equal(t, size, execCommand(c, "zinterstore", "dst", "2", "s", "s"))
+}
@@ -0,0 +1,1001 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	ReplyInvalidStreamID = "-ERR Invalid stream ID specified as stream command argument\r\n"
+	ReplyStreamKeyNeeded = "-ERR The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.\r\n"
+)
+
+const (
+	StreamTrimNone = iota
+	StreamTrimMaxLen
+	StreamTrimMinID
+)
+
+// 解析stream ID，只有毫秒部分时序号取missingSeq，strict为true时不允许使用"-"和"+"
+func parseStreamID(s string, missingSeq uint64, strict bool) (StreamID, bool) {
+	if !strict && s == "-" {
+		return StreamIDMin, true
+	} else if !strict && s == "+" {
+		return StreamIDMax, true
+	}
+
+	msPart, seqPart, found := strings.Cut(s, "-")
+	ms, err := strconv.ParseUint(msPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	if !found {
+		return StreamID{ms, missingSeq}, true
+	}
+	seq, err := strconv.ParseUint(seqPart, 10, 64)
+	if err != nil {
+		return StreamID{}, false
+	}
+	return StreamID{ms, seq}, true
+}
+
+func parseStreamIDOrReply(c *GodisClient, o *Gobj, missingSeq uint64, strict bool) (StreamID, bool) {
+	id, ok := parseStreamID(o.StrVal(), missingSeq, strict)
+	if !ok {
+		c.AddReplyStr(ReplyInvalidStreamID)
+	}
+	return id, ok
+}
+
+// 解析范围查询的边界，以"("开头时表示开区间
+func parseStreamRangeIDOrReply(c *GodisClient, o *Gobj, missingSeq uint64, isStart bool) (StreamID, bool) {
+	s := o.StrVal()
+	if !strings.HasPrefix(s, "(") {
+		return parseStreamIDOrReply(c, o, missingSeq, false)
+	}
+
+	id, ok := parseStreamID(s[1:], missingSeq, true)
+	if !ok {
+		c.AddReplyStr(ReplyInvalidStreamID)
+		return id, false
+	}
+	if isStart {
+		if id, ok = id.Incr(); !ok {
+			c.AddReplyError("invalid start ID for the interval")
+		}
+	} else if id, ok = id.Decr(); !ok {
+		c.AddReplyError("invalid end ID for the interval")
+	}
+	return id, ok
+}
+
+// 查找stream对象，key不存在时回复empty并返回nil
+func streamLookupRead(c *GodisClient, key *Gobj, empty string) *Stream {
+	sobj := findKeyRead(c.db, key)
+	if sobj == nil {
+		c.AddReplyStr(empty)
+		return nil
+	}
+	if checkType(c, sobj, GStream) {
+		return nil
+	}
+	return sobj.Val_.(*Stream)
+}
+
+// 查找stream和消费者组，不存在时回复NOGROUP错误
+func streamLookupCGOrReply(c *GodisClient, key, group *Gobj) (*Stream, *StreamCG) {
+	sobj := findKeyRead(c.db, key)
+	if sobj != nil && checkType(c, sobj, GStream) {
+		return nil, nil
+	}
+	var cg *StreamCG
+	if sobj != nil {
+		cg = sobj.Val_.(*Stream).LookupCG(group.StrVal())
+	}
+	if cg == nil {
+		c.AddReplyStr("-NOGROUP No such key '" + key.StrVal() + "' or consumer group '" + group.StrVal() + "'\r\n")
+		return nil, nil
+	}
+	return sobj.Val_.(*Stream), cg
+}
+
+func addReplyStreamEntry(c *GodisClient, e *StreamEntry) {
+	c.AddReplyArrayLen(2)
+	c.AddReplyBulkStr(e.ID.String())
+	// 消息已经被删除但还在pel中时，fields为nil
+	if e.Fields == nil {
+		c.AddReplyStr(ReplyNullArray)
+		return
+	}
+	c.AddReplyArrayLen(len(e.Fields))
+	for _, f := range e.Fields {
+		c.AddReplyBulk(f)
+	}
+}
+
+func addReplyStreamEntries(c *GodisClient, entries []*StreamEntry) {
+	c.AddReplyArrayLen(len(entries))
+	for _, e := range entries {
+		addReplyStreamEntry(c, e)
+	}
+}
+
+type streamAddTrimArgs struct {
+	strategy   int
+	approx     bool
+	maxLen     int64
+	minID      StreamID
+	limit      int64
+	noMkStream bool
+	id         StreamID
+	idGiven    bool
+	seqGiven   bool
+}
+
+func (args *streamAddTrimArgs) trim(s *Stream) int64 {
+	switch args.strategy {
+	case StreamTrimMaxLen:
+		return s.TrimByLen(args.maxLen, args.limit)
+	case StreamTrimMinID:
+		return s.TrimByMinID(args.minID, args.limit)
+	}
+	return 0
+}
+
+// 解析XADD和XTRIM的参数，对于XADD返回ID所在的位置
+// 不保存节点的内部结构，"~"时也会精确地裁剪
+func parseStreamAddOrTrimArgs(c *GodisClient, xadd bool) (*streamAddTrimArgs, int, bool) {
+	args := &streamAddTrimArgs{}
+	limitGiven := false
+	i := 2
+	for ; i < len(c.args); i++ {
+		moreArgs := len(c.args) - 1 - i
+		opt := c.args[i].StrVal()
+		lopt := strings.ToLower(opt)
+		if xadd && opt == "*" {
+			break
+		} else if (lopt == "maxlen" || lopt == "minid") && moreArgs > 0 {
+			if args.strategy != StreamTrimNone {
+				c.AddReplyError("syntax error, MAXLEN and MINID options at the same time are not compatible")
+				return nil, 0, false
+			}
+			next := c.args[i+1].StrVal()
+			if (next == "~" || next == "=") && moreArgs >= 2 {
+				args.approx = next == "~"
+				i++
+			}
+			i++
+			if lopt == "maxlen" {
+				args.strategy = StreamTrimMaxLen
+				var ok bool
+				if args.maxLen, ok = getIntFromObjectOrReply(c, c.args[i]); !ok {
+					return nil, 0, false
+				}
+				if args.maxLen < 0 {
+					c.AddReplyError("The MAXLEN argument must be >= 0.")
+					return nil, 0, false
+				}
+			} else {
+				args.strategy = StreamTrimMinID
+				var ok bool
+				if args.minID, ok = parseStreamIDOrReply(c, c.args[i], 0, true); !ok {
+					return nil, 0, false
+				}
+			}
+		} else if lopt == "limit" && moreArgs > 0 {
+			var ok bool
+			if args.limit, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return nil, 0, false
+			}
+			if args.limit < 0 {
+				c.AddReplyError("The LIMIT argument must be >= 0.")
+				return nil, 0, false
+			}
+			limitGiven = true
+			i++
+		} else if xadd && lopt == "nomkstream" {
+			args.noMkStream = true
+		} else if xadd {
+			// 第一个不是选项的参数就是ID，可以是"ms-*"的形式
+			args.idGiven = true
+			args.seqGiven = true
+			if ms, ok := strings.CutSuffix(opt, "-*"); ok {
+				args.seqGiven = false
+				opt = ms
+			}
+			id, ok := parseStreamID(opt, 0, true)
+			if !ok {
+				c.AddReplyStr(ReplyInvalidStreamID)
+				return nil, 0, false
+			}
+			args.id = id
+			break
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return nil, 0, false
+		}
+	}
+
+	if limitGiven && !args.approx {
+		c.AddReplyError("syntax error, LIMIT cannot be used without the special ~ option")
+		return nil, 0, false
+	}
+	if !xadd && args.strategy == StreamTrimNone {
+		c.AddReplyStr(ReplySyntaxErr)
+		return nil, 0, false
+	}
+	return args, i, true
+}
+
+func xaddCommand(c *GodisClient) {
+	args, idx, ok := parseStreamAddOrTrimArgs(c, true)
+	if !ok {
+		return
+	}
+
+	fieldPos := idx + 1
+	if fieldPos >= len(c.args) || (len(c.args)-fieldPos)%2 != 0 {
+		c.AddReplyError("wrong number of arguments for 'xadd' command")
+		return
+	}
+	if args.idGiven && args.seqGiven && args.id == StreamIDMin {
+		c.AddReplyError("The ID specified in XADD must be greater than 0-0")
+		return
+	}
+
+	key := c.args[1]
+	sobj := findKeyWrite(c.db, key)
+	if sobj == nil {
+		if args.noMkStream {
+			c.AddReplyStr(ReplyNull)
+			return
+		}
+		sobj = CreateStreamObject()
+		setKey(c.db, key, sobj)
+		sobj.DecrRefCount()
+	} else if checkType(c, sobj, GStream) {
+		return
+	}
+
+	s := sobj.Val_.(*Stream)
+	if s.lastID == StreamIDMax {
+		c.AddReplyError("The stream has exhausted the last possible ID, unable to add more items")
+		return
+	}
+	id, ok := s.NextID(args.id, args.idGiven, args.seqGiven)
+	if !ok {
+		c.AddReplyError("The ID specified in XADD is equal or smaller than the target stream top item")
+		return
+	}
+
+	fields := make([]*Gobj, len(c.args)-fieldPos)
+	copy(fields, c.args[fieldPos:])
+	s.Append(id, fields)
+	args.trim(s)
+	// stream已经存在时setKey不会被调用，需要单独唤醒阻塞在XREAD上的客户端
+	signalKeyAsReady(c.db, key)
+	c.AddReplyBulkStr(id.String())
+}
+
+func xlenCommand(c *GodisClient) {
+	s := streamLookupRead(c, c.args[1], ":0\r\n")
+	if s == nil {
+		return
+	}
+	c.AddReplyInt(s.Length())
+}
+
+func xrangeGenericCommand(c *GodisClient, reverse bool) {
+	startArg, endArg := c.args[2], c.args[3]
+	if reverse {
+		startArg, endArg = endArg, startArg
+	}
+	start, ok := parseStreamRangeIDOrReply(c, startArg, 0, true)
+	if !ok {
+		return
+	}
+	end, ok := parseStreamRangeIDOrReply(c, endArg, StreamIDMax.Seq, false)
+	if !ok {
+		return
+	}
+
+	count := int64(-1)
+	for i := 4; i < len(c.args); i++ {
+		if strings.ToLower(c.args[i].StrVal()) == "count" && i+1 < len(c.args) {
+			if count, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if count < 0 {
+				count = 0
+			}
+			i++
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	s := streamLookupRead(c, c.args[1], ReplyEmptyArray)
+	if s == nil {
+		return
+	}
+	if count == 0 {
+		c.AddReplyStr(ReplyEmptyArray)
+		return
+	} else if count < 0 {
+		count = 0
+	}
+	addReplyStreamEntries(c, s.Range(start, end, count, reverse))
+}
+
+func xrangeCommand(c *GodisClient) {
+	xrangeGenericCommand(c, false)
+}
+
+func xrevrangeCommand(c *GodisClient) {
+	xrangeGenericCommand(c, true)
+}
+
+func xdelCommand(c *GodisClient) {
+	ids := make([]StreamID, 0, len(c.args)-2)
+	for _, arg := range c.args[2:] {
+		id, ok := parseStreamIDOrReply(c, arg, 0, true)
+		if !ok {
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	s := streamLookupRead(c, c.args[1], ":0\r\n")
+	if s == nil {
+		return
+	}
+	var deleted int64
+	for _, id := range ids {
+		if s.Delete(id) {
+			deleted++
+		}
+	}
+	c.AddReplyInt(deleted)
+}
+
+func xtrimCommand(c *GodisClient) {
+	args, _, ok := parseStreamAddOrTrimArgs(c, false)
+	if !ok {
+		return
+	}
+	s := streamLookupRead(c, c.args[1], ":0\r\n")
+	if s == nil {
+		return
+	}
+	c.AddReplyInt(args.trim(s))
+}
+
+type streamReadResult struct {
+	key     *Gobj
+	entries []*StreamEntry
+}
+
+// xreadGenericCommand 处理XREAD和XREADGROUP，先校验所有的key和ID再读取数据
+func xreadGenericCommand(c *GodisClient, xreadgroup bool) {
+	var group, consumerName *Gobj
+	var count, timeout int64
+	noAck, block := false, false
+	streamsArg := 0
+	for i := 1; i < len(c.args); i++ {
+		moreArgs := len(c.args) - 1 - i
+		opt := strings.ToLower(c.args[i].StrVal())
+		if opt == "count" && moreArgs > 0 {
+			var ok bool
+			if count, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if count < 0 {
+				count = 0
+			}
+			i++
+		} else if opt == "block" && moreArgs > 0 {
+			var ok bool
+			if timeout, ok = getMsTimeoutFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			block = true
+			i++
+		} else if opt == "streams" && moreArgs > 0 {
+			streamsArg = i + 1
+			break
+		} else if xreadgroup && opt == "group" && moreArgs >= 2 {
+			group, consumerName = c.args[i+1], c.args[i+2]
+			i += 2
+		} else if xreadgroup && opt == "noack" {
+			noAck = true
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	cmdName := strings.ToLower(c.args[0].StrVal())
+	if streamsArg == 0 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+	if (len(c.args)-streamsArg)%2 != 0 {
+		c.AddReplyError("Unbalanced '" + cmdName + "' list of streams: for each stream key an ID or '$' must be specified.")
+		return
+	}
+	if xreadgroup && group == nil {
+		c.AddReplyError("Missing GROUP option for XREADGROUP")
+		return
+	}
+
+	numKeys := (len(c.args) - streamsArg) / 2
+	streams := make([]*Stream, numKeys)
+	groups := make([]*StreamCG, numKeys)
+	ids := make([]StreamID, numKeys)
+	newOnly := make([]bool, numKeys)
+	for i := 0; i < numKeys; i++ {
+		key := c.args[streamsArg+i]
+		idArg := c.args[streamsArg+numKeys+i]
+		sobj := findKeyRead(c.db, key)
+		if sobj != nil {
+			if checkType(c, sobj, GStream) {
+				return
+			}
+			streams[i] = sobj.Val_.(*Stream)
+		}
+
+		if xreadgroup {
+			if streams[i] != nil {
+				groups[i] = streams[i].LookupCG(group.StrVal())
+			}
+			if groups[i] == nil {
+				c.AddReplyStr("-NOGROUP No such key '" + key.StrVal() + "' or consumer group '" +
+					group.StrVal() + "' in XREADGROUP with GROUP option\r\n")
+				return
+			}
+		}
+
+		switch idArg.StrVal() {
+		case "$":
+			if xreadgroup {
+				c.AddReplyError("The $ ID is meaningless in the context of XREADGROUP: you want to read the history " +
+					"of this consumer by specifying a proper ID, or use the > ID to get new messages. " +
+					"The $ ID would just return an empty result set.")
+				return
+			}
+			if c.bstate != nil && c.bstate.streamIDs != nil {
+				// 被唤醒后重新执行，只读取阻塞之后写入的entry
+				ids[i] = c.bstate.streamIDs[i]
+			} else if streams[i] != nil {
+				ids[i] = streams[i].lastID
+			}
+		case ">":
+			if !xreadgroup {
+				c.AddReplyError("The > ID can be specified only when calling XREADGROUP using the GROUP <group> <consumer> option.")
+				return
+			}
+			ids[i] = groups[i].lastID
+			newOnly[i] = true
+		default:
+			id, ok := parseStreamIDOrReply(c, idArg, 0, true)
+			if !ok {
+				return
+			}
+			ids[i] = id
+		}
+	}
+
+	now := GetMsTime()
+	var results []streamReadResult
+	for i := 0; i < numKeys; i++ {
+		key := c.args[streamsArg+i]
+		if xreadgroup && !newOnly[i] {
+			consumer := groups[i].LookupConsumer(consumerName.StrVal(), true)
+			consumer.seenTime = now
+			results = append(results, streamReadResult{key, streamConsumerHistory(streams[i], consumer, ids[i], count, now)})
+			continue
+		}
+
+		if streams[i] == nil {
+			continue
+		}
+		start, ok := ids[i].Incr()
+		if !ok {
+			continue
+		}
+		entries := streams[i].Range(start, StreamIDMax, count, false)
+		if len(entries) == 0 {
+			continue
+		}
+		if xreadgroup {
+			cg := groups[i]
+			consumer := cg.LookupConsumer(consumerName.StrVal(), true)
+			consumer.seenTime = now
+			for _, e := range entries {
+				cg.lastID = e.ID
+				if !noAck {
+					nack := cg.Deliver(e.ID, consumer, now)
+					nack.deliveryCount = 1
+				}
+			}
+		}
+		results = append(results, streamReadResult{key, entries})
+	}
+
+	if len(results) == 0 {
+		if block {
+			blockForKeys(c, GStream, c.args[streamsArg:streamsArg+numKeys], timeout)
+			if c.bstate.streamIDs == nil {
+				c.bstate.streamIDs = ids
+			}
+			return
+		}
+		c.AddReplyStr(ReplyNullArray)
+		return
+	}
+	c.AddReplyArrayLen(len(results))
+	for _, r := range results {
+		c.AddReplyArrayLen(2)
+		c.AddReplyBulk(r.key)
+		addReplyStreamEntries(c, r.entries)
+	}
+}
+
+// 读取消费者pel中ID大于start的消息，这些消息会被重新投递
+func streamConsumerHistory(s *Stream, consumer *StreamConsumer, start StreamID, count, now int64) []*StreamEntry {
+	entries := []*StreamEntry{}
+	start, ok := start.Incr()
+	if !ok {
+		return entries
+	}
+	for _, id := range sortedPEL(consumer.pel, start) {
+		if count > 0 && int64(len(entries)) >= count {
+			break
+		}
+		e := s.Lookup(id)
+		if e == nil {
+			entries = append(entries, &StreamEntry{ID: id})
+			continue
+		}
+		nack := consumer.pel[id]
+		nack.deliveryTime = now
+		nack.deliveryCount++
+		entries = append(entries, e)
+	}
+	return entries
+}
+
+func xreadCommand(c *GodisClient) {
+	xreadGenericCommand(c, false)
+}
+
+func xreadgroupCommand(c *GodisClient) {
+	xreadGenericCommand(c, true)
+}
+
+func xgroupCommand(c *GodisClient) {
+	sub := strings.ToLower(c.args[1].StrVal())
+	argc := len(c.args)
+	if (sub == "create" && argc < 5) || (sub == "setid" && argc != 5) || (sub == "destroy" && argc != 4) ||
+		((sub == "createconsumer" || sub == "delconsumer") && argc != 5) {
+		c.AddReplyError("unknown subcommand or wrong number of arguments for '" + c.args[1].StrVal() + "'")
+		return
+	}
+	switch sub {
+	case "create", "setid", "destroy", "createconsumer", "delconsumer":
+	default:
+		c.AddReplyError("unknown subcommand '" + c.args[1].StrVal() + "'")
+		return
+	}
+
+	mkStream := false
+	for i := 5; i < argc; i++ {
+		if strings.ToLower(c.args[i].StrVal()) == "mkstream" {
+			mkStream = true
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	key, groupName := c.args[2], c.args[3].StrVal()
+	sobj := findKeyWrite(c.db, key)
+	if sobj != nil && checkType(c, sobj, GStream) {
+		return
+	}
+	if sobj == nil && !(sub == "create" && mkStream) {
+		c.AddReplyStr(ReplyStreamKeyNeeded)
+		return
+	}
+
+	var s *Stream
+	var cg *StreamCG
+	if sobj != nil {
+		s = sobj.Val_.(*Stream)
+		cg = s.LookupCG(groupName)
+		if cg == nil && (sub == "setid" || sub == "createconsumer" || sub == "delconsumer") {
+			c.AddReplyStr("-NOGROUP No such consumer group '" + groupName + "' for key name '" + key.StrVal() + "'\r\n")
+			return
+		}
+	}
+
+	var id StreamID
+	if sub == "create" || sub == "setid" {
+		if c.args[4].StrVal() == "$" {
+			if s != nil {
+				id = s.lastID
+			}
+		} else {
+			var ok bool
+			if id, ok = parseStreamIDOrReply(c, c.args[4], 0, true); !ok {
+				return
+			}
+		}
+	}
+
+	switch sub {
+	case "create":
+		if s == nil {
+			sobj = CreateStreamObject()
+			setKey(c.db, key, sobj)
+			sobj.DecrRefCount()
+			s = sobj.Val_.(*Stream)
+		}
+		if s.CreateCG(groupName, id) == nil {
+			c.AddReplyStr("-BUSYGROUP Consumer Group name already exists\r\n")
+			return
+		}
+		c.AddReplyStr(ReplyOK)
+	case "setid":
+		cg.lastID = id
+		c.AddReplyStr(ReplyOK)
+	case "destroy":
+		if s.DestroyCG(groupName) {
+			c.AddReplyInt(1)
+		} else {
+			c.AddReplyInt(0)
+		}
+	case "createconsumer":
+		name := c.args[4].StrVal()
+		if cg.LookupConsumer(name, false) != nil {
+			c.AddReplyInt(0)
+			return
+		}
+		cg.LookupConsumer(name, true)
+		c.AddReplyInt(1)
+	case "delconsumer":
+		c.AddReplyInt(cg.DeleteConsumer(c.args[4].StrVal()))
+	}
+}
+
+func xackCommand(c *GodisClient) {
+	ids := make([]StreamID, 0, len(c.args)-3)
+	for _, arg := range c.args[3:] {
+		id, ok := parseStreamIDOrReply(c, arg, 0, true)
+		if !ok {
+			return
+		}
+		ids = append(ids, id)
+	}
+
+	s := streamLookupRead(c, c.args[1], ":0\r\n")
+	if s == nil {
+		return
+	}
+	cg := s.LookupCG(c.args[2].StrVal())
+	if cg == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	var acked int64
+	for _, id := range ids {
+		if cg.Ack(id) {
+			acked++
+		}
+	}
+	c.AddReplyInt(acked)
+}
+
+func xpendingCommand(c *GodisClient) {
+	argc := len(c.args)
+	extended := argc > 3
+	var minIdle, count int64
+	var start, end StreamID
+	var consumerName *Gobj
+	if extended {
+		i := 3
+		if strings.ToLower(c.args[i].StrVal()) == "idle" && argc > 4 {
+			var ok bool
+			if minIdle, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			i += 2
+		}
+		if argc-i != 3 && argc-i != 4 {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+		var ok bool
+		if start, ok = parseStreamRangeIDOrReply(c, c.args[i], 0, true); !ok {
+			return
+		}
+		if end, ok = parseStreamRangeIDOrReply(c, c.args[i+1], StreamIDMax.Seq, false); !ok {
+			return
+		}
+		if count, ok = getIntFromObjectOrReply(c, c.args[i+2]); !ok {
+			return
+		}
+		if count < 0 {
+			count = 0
+		}
+		if argc-i == 4 {
+			consumerName = c.args[i+3]
+		}
+	}
+
+	_, cg := streamLookupCGOrReply(c, c.args[1], c.args[2])
+	if cg == nil {
+		return
+	}
+
+	if !extended {
+		if len(cg.pel) == 0 {
+			c.AddReplyArrayLen(4)
+			c.AddReplyInt(0)
+			c.AddReplyStr(ReplyNull)
+			c.AddReplyStr(ReplyNull)
+			c.AddReplyStr(ReplyNullArray)
+			return
+		}
+		ids := sortedPEL(cg.pel, StreamIDMin)
+		c.AddReplyArrayLen(4)
+		c.AddReplyInt(int64(len(ids)))
+		c.AddReplyBulkStr(ids[0].String())
+		c.AddReplyBulkStr(ids[len(ids)-1].String())
+		var consumers []*StreamConsumer
+		for _, consumer := range cg.SortedConsumers() {
+			if len(consumer.pel) > 0 {
+				consumers = append(consumers, consumer)
+			}
+		}
+		c.AddReplyArrayLen(len(consumers))
+		for _, consumer := range consumers {
+			c.AddReplyArrayLen(2)
+			c.AddReplyBulkStr(consumer.name)
+			c.AddReplyBulkStr(strconv.Itoa(len(consumer.pel)))
+		}
+		return
+	}
+
+	pel := cg.pel
+	if consumerName != nil {
+		consumer := cg.LookupConsumer(consumerName.StrVal(), false)
+		if consumer == nil {
+			c.AddReplyStr(ReplyEmptyArray)
+			return
+		}
+		pel = consumer.pel
+	}
+
+	now := GetMsTime()
+	var ids []StreamID
+	for _, id := range sortedPEL(pel, start) {
+		if id.Compare(end) > 0 || int64(len(ids)) >= count {
+			break
+		}
+		if now-pel[id].deliveryTime >= minIdle {
+			ids = append(ids, id)
+		}
+	}
+	c.AddReplyArrayLen(len(ids))
+	for _, id := range ids {
+		nack := pel[id]
+		c.AddReplyArrayLen(4)
+		c.AddReplyBulkStr(id.String())
+		c.AddReplyBulkStr(nack.consumer.name)
+		c.AddReplyInt(now - nack.deliveryTime)
+		c.AddReplyInt(nack.deliveryCount)
+	}
+}
+
+func xclaimCommand(c *GodisClient) {
+	minIdle, ok := getIntFromObjectOrReply(c, c.args[4])
+	if !ok {
+		return
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+
+	// ID列表之后是可选参数
+	i := 5
+	var ids []StreamID
+	for ; i < len(c.args); i++ {
+		id, ok := parseStreamID(c.args[i].StrVal(), 0, true)
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+
+	now := GetMsTime()
+	deliveryTime := now
+	retryCount := int64(-1)
+	force, justID := false, false
+	var lastID StreamID
+	lastIDGiven := false
+	for ; i < len(c.args); i++ {
+		moreArgs := len(c.args) - 1 - i
+		opt := strings.ToLower(c.args[i].StrVal())
+		if opt == "force" {
+			force = true
+		} else if opt == "justid" {
+			justID = true
+		} else if opt == "idle" && moreArgs > 0 {
+			idle, ok := getIntFromObjectOrReply(c, c.args[i+1])
+			if !ok {
+				return
+			}
+			deliveryTime = now - idle
+			i++
+		} else if opt == "time" && moreArgs > 0 {
+			if deliveryTime, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			i++
+		} else if opt == "retrycount" && moreArgs > 0 {
+			if retryCount, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			i++
+		} else if opt == "lastid" && moreArgs > 0 {
+			if lastID, ok = parseStreamIDOrReply(c, c.args[i+1], 0, true); !ok {
+				return
+			}
+			lastIDGiven = true
+			i++
+		} else {
+			c.AddReplyError("Unrecognized XCLAIM option '" + c.args[i].StrVal() + "'")
+			return
+		}
+	}
+	if deliveryTime < 0 || deliveryTime > now {
+		deliveryTime = now
+	}
+
+	s, cg := streamLookupCGOrReply(c, c.args[1], c.args[2])
+	if cg == nil {
+		return
+	}
+	if lastIDGiven && lastID.Compare(cg.lastID) > 0 {
+		cg.lastID = lastID
+	}
+
+	consumer := cg.LookupConsumer(c.args[3].StrVal(), true)
+	consumer.seenTime = now
+	var claimed []*StreamEntry
+	for _, id := range ids {
+		e := s.Lookup(id)
+		nack, ok := cg.pel[id]
+		if !ok {
+			// 使用FORCE时，不在pel中但存在于stream中的消息也可以被认领
+			if !force || e == nil {
+				continue
+			}
+		} else {
+			if now-nack.deliveryTime < minIdle {
+				continue
+			}
+			if e == nil {
+				cg.Ack(id)
+				continue
+			}
+		}
+
+		nack = cg.Deliver(id, consumer, deliveryTime)
+		if retryCount >= 0 {
+			nack.deliveryCount = retryCount
+		} else if !justID {
+			nack.deliveryCount++
+		}
+		claimed = append(claimed, e)
+	}
+
+	c.AddReplyArrayLen(len(claimed))
+	for _, e := range claimed {
+		if justID {
+			c.AddReplyBulkStr(e.ID.String())
+		} else {
+			addReplyStreamEntry(c, e)
+		}
+	}
+}
+
+func xautoclaimCommand(c *GodisClient) {
+	minIdle, ok := getIntFromObjectOrReply(c, c.args[4])
+	if !ok {
+		return
+	}
+	if minIdle < 0 {
+		minIdle = 0
+	}
+	start, ok := parseStreamRangeIDOrReply(c, c.args[5], 0, true)
+	if !ok {
+		return
+	}
+
+	count := int64(100)
+	justID := false
+	for i := 6; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		if opt == "count" && i+1 < len(c.args) {
+			if count, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if count < 1 {
+				c.AddReplyError("COUNT must be > 0")
+				return
+			}
+			i++
+		} else if opt == "justid" {
+			justID = true
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	s, cg := streamLookupCGOrReply(c, c.args[1], c.args[2])
+	if cg == nil {
+		return
+	}
+
+	now := GetMsTime()
+	consumer := cg.LookupConsumer(c.args[3].StrVal(), true)
+	consumer.seenTime = now
+
+	// 限制扫描的数量，避免pel很大时阻塞太久
+	attempts := count * 10
+	ids := sortedPEL(cg.pel, start)
+	idx := 0
+	var claimed []*StreamEntry
+	var deleted []StreamID
+	for ; idx < len(ids) && attempts > 0 && int64(len(claimed)) < count; idx++ {
+		attempts--
+		id := ids[idx]
+		if now-cg.pel[id].deliveryTime < minIdle {
+			continue
+		}
+		e := s.Lookup(id)
+		if e == nil {
+			cg.Ack(id)
+			deleted = append(deleted, id)
+			continue
+		}
+		nack := cg.Deliver(id, consumer, now)
+		if !justID {
+			nack.deliveryCount++
+		}
+		claimed = append(claimed, e)
+	}
+
+	next := StreamIDMin
+	if idx < len(ids) {
+		next = ids[idx]
+	}
+	c.AddReplyArrayLen(3)
+	c.AddReplyBulkStr(next.String())
+	c.AddReplyArrayLen(len(claimed))
+	for _, e := range claimed {
+		if justID {
+			c.AddReplyBulkStr(e.ID.String())
+		} else {
+			addReplyStreamEntry(c, e)
+		}
+	}
+	c.AddReplyArrayLen(len(deleted))
+	for _, id := range deleted {
+		c.AddReplyBulkStr(id.String())
+	}
+}
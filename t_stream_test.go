@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStreamID(t *testing.T) {
+	id, ok := parseStreamID("5", 0, true)
+	assert.True(t, ok)
+	assert.Equal(t, StreamID{5, 0}, id)
+	id, ok = parseStreamID("5-3", 0, true)
+	assert.True(t, ok)
+	assert.Equal(t, "5-3", id.String())
+	_, ok = parseStreamID("+", 0, true)
+	assert.False(t, ok)
+	id, ok = parseStreamID("+", 0, false)
+	assert.True(t, ok)
+	assert.Equal(t, StreamIDMax, id)
+
+	next, ok := StreamID{1, StreamIDMax.Seq}.Incr()
+	assert.True(t, ok)
+	assert.Equal(t, StreamID{2, 0}, next)
+	_, ok = StreamIDMax.Incr()
+	assert.False(t, ok)
+	_, ok = StreamIDMin.Decr()
+	assert.False(t, ok)
+}
+
+func TestXAdd(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, "$3\r\n1-1\r\n", execCommand(c, "xadd", "s", "1-1", "f", "v"))
+	assert.Equal(t, "$3\r\n1-2\r\n", execCommand(c, "xadd", "s", "1-*", "f", "v"))
+	assert.Equal(t, "$3\r\n2-0\r\n", execCommand(c, "xadd", "s", "2", "f", "v"))
+	assert.Equal(t, "-ERR The ID specified in XADD is equal or smaller than the target stream top item\r\n",
+		execCommand(c, "xadd", "s", "1-5", "f", "v"))
+	assert.Equal(t, "-ERR The ID specified in XADD must be greater than 0-0\r\n", execCommand(c, "xadd", "s2", "0-0", "f", "v"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'xadd' command\r\n", execCommand(c, "xadd", "s", "*", "f", "v", "g"))
+	assert.Equal(t, ReplyNull, execCommand(c, "xadd", "s2", "nomkstream", "*", "f", "v"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "xlen", "s"))
+
+	reply := execCommand(c, "xadd", "s", "*", "f", "v")
+	assert.NotEqual(t, "-", reply[:1])
+	assert.Equal(t, ":4\r\n", execCommand(c, "xlen", "s"))
+
+	assert.Equal(t, "$3\r\n3-0\r\n", execCommand(c, "xadd", "t", "maxlen", "2", "3-0", "f", "v"))
+	execCommand(c, "xadd", "t", "4-0", "f", "v")
+	execCommand(c, "xadd", "t", "maxlen", "=", "2", "5-0", "f", "v")
+	assert.Equal(t, ":2\r\n", execCommand(c, "xlen", "t"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "xtrim", "t", "minid", "5"))
+	assert.Equal(t, "-ERR syntax error, LIMIT cannot be used without the special ~ option\r\n",
+		execCommand(c, "xtrim", "t", "maxlen", "1", "limit", "1"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "xdel", "t", "5-0", "6-0"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "xlen", "t"))
+
+	execCommand(c, "set", "str", "v")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "xadd", "str", "*", "f", "v"))
+}
+
+func TestXRange(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "xadd", "s", "1-0", "a", "1")
+	execCommand(c, "xadd", "s", "2-0", "b", "2")
+	execCommand(c, "xadd", "s", "3-0", "c", "3")
+
+	entry1 := "*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n"
+	entry2 := "*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"
+	entry3 := "*2\r\n$3\r\n3-0\r\n*2\r\n$1\r\nc\r\n$1\r\n3\r\n"
+	assert.Equal(t, "*3\r\n"+entry1+entry2+entry3, execCommand(c, "xrange", "s", "-", "+"))
+	assert.Equal(t, "*2\r\n"+entry1+entry2, execCommand(c, "xrange", "s", "-", "+", "count", "2"))
+	assert.Equal(t, "*1\r\n"+entry2, execCommand(c, "xrange", "s", "(1-0", "(3-0"))
+	assert.Equal(t, "*2\r\n"+entry3+entry2, execCommand(c, "xrevrange", "s", "+", "2"))
+	assert.Equal(t, ReplyEmptyArray, execCommand(c, "xrange", "none", "-", "+"))
+	assert.Equal(t, ReplyInvalidStreamID, execCommand(c, "xrange", "s", "x", "+"))
+
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*2\r\n"+entry2+entry3, execCommand(c, "xread", "streams", "s", "1"))
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry1, execCommand(c, "xread", "count", "1", "streams", "s", "0"))
+	assert.Equal(t, ReplyNullArray, execCommand(c, "xread", "streams", "s", "none", "$", "0"))
+	assert.Equal(t, "-ERR Unbalanced 'xread' list of streams: for each stream key an ID or '$' must be specified.\r\n",
+		execCommand(c, "xread", "streams", "s", "none", "0"))
+}
+
+func TestXConsumerGroup(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ReplyStreamKeyNeeded, execCommand(c, "xgroup", "create", "s", "g", "$"))
+	assert.Equal(t, ReplyOK, execCommand(c, "xgroup", "create", "s", "g", "$", "mkstream"))
+	assert.Equal(t, "-BUSYGROUP Consumer Group name already exists\r\n", execCommand(c, "xgroup", "create", "s", "g", "0"))
+	execCommand(c, "xadd", "s", "1-0", "a", "1")
+	execCommand(c, "xadd", "s", "2-0", "b", "2")
+
+	entry1 := "*2\r\n$3\r\n1-0\r\n*2\r\n$1\r\na\r\n$1\r\n1\r\n"
+	entry2 := "*2\r\n$3\r\n2-0\r\n*2\r\n$1\r\nb\r\n$1\r\n2\r\n"
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry1,
+		execCommand(c, "xreadgroup", "group", "g", "alice", "count", "1", "streams", "s", ">"))
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry2,
+		execCommand(c, "xreadgroup", "group", "g", "bob", "streams", "s", ">"))
+	assert.Equal(t, ReplyNullArray, execCommand(c, "xreadgroup", "group", "g", "bob", "streams", "s", ">"))
+	// 读取消费者自己的历史消息
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry1,
+		execCommand(c, "xreadgroup", "group", "g", "alice", "streams", "s", "0"))
+	assert.Equal(t, "-NOGROUP No such key 's' or consumer group 'x' in XREADGROUP with GROUP option\r\n",
+		execCommand(c, "xreadgroup", "group", "x", "alice", "streams", "s", ">"))
+
+	assert.Equal(t, "*4\r\n:2\r\n$3\r\n1-0\r\n$3\r\n2-0\r\n*2\r\n*2\r\n$5\r\nalice\r\n$1\r\n1\r\n*2\r\n$3\r\nbob\r\n$1\r\n1\r\n",
+		execCommand(c, "xpending", "s", "g"))
+	reply := execCommand(c, "xpending", "s", "g", "-", "+", "10", "alice")
+	assert.Equal(t, "*1\r\n*4\r\n$3\r\n1-0\r\n$5\r\nalice\r\n:", reply[:29])
+	assert.Equal(t, ":2\r\n", reply[len(reply)-4:])
+
+	assert.Equal(t, "*1\r\n$3\r\n1-0\r\n", execCommand(c, "xclaim", "s", "g", "bob", "0", "1-0", "justid"))
+	assert.Equal(t, ReplyEmptyArray, execCommand(c, "xclaim", "s", "g", "bob", "3600000", "1-0"))
+	assert.Equal(t, "*3\r\n$3\r\n0-0\r\n*2\r\n$3\r\n1-0\r\n$3\r\n2-0\r\n*0\r\n",
+		execCommand(c, "xautoclaim", "s", "g", "alice", "0", "0", "justid"))
+
+	execCommand(c, "xdel", "s", "2-0")
+	assert.Equal(t, "*3\r\n$3\r\n0-0\r\n*1\r\n"+entry1+"*1\r\n$3\r\n2-0\r\n",
+		execCommand(c, "xautoclaim", "s", "g", "bob", "0", "-"))
+
+	assert.Equal(t, ":1\r\n", execCommand(c, "xack", "s", "g", "1-0", "9-0"))
+	assert.Equal(t, "*4\r\n:0\r\n$-1\r\n$-1\r\n*-1\r\n", execCommand(c, "xpending", "s", "g"))
+
+	assert.Equal(t, ":1\r\n", execCommand(c, "xgroup", "createconsumer", "s", "g", "carol"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "xgroup", "delconsumer", "s", "g", "carol"))
+	assert.Equal(t, ReplyOK, execCommand(c, "xgroup", "setid", "s", "g", "0"))
+	assert.Equal(t, "*1\r\n*2\r\n$1\r\ns\r\n*1\r\n"+entry1,
+		execCommand(c, "xreadgroup", "group", "g", "alice", "noack", "streams", "s", ">"))
+	assert.Equal(t, "*4\r\n:0\r\n$-1\r\n$-1\r\n*-1\r\n", execCommand(c, "xpending", "s", "g"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "xgroup", "destroy", "s", "g"))
+	assert.Equal(t, "-NOGROUP No such key 's' or consumer group 'g'\r\n", execCommand(c, "xpending", "s", "g"))
+}
@@ -0,0 +1,485 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// 字符串的最大长度为512MB
+const GodisMaxStringSize int64 = 512 * 1024 * 1024
+
+// SET命令的选项
+const (
+	ObjSetNX = 1 << iota
+	ObjSetXX
+	ObjSetKeepTTL
+	ObjSetGet
+)
+
+func getCommand(c *GodisClient) {
+	key := c.args[1]
+	val := findKeyRead(c.db, key)
+	if val == nil {
+		c.AddReplyStr(ReplyNull)
+	} else if checkType(c, val, GSTR) {
+		return
+	} else {
+		c.AddReplyBulk(val)
+	}
+}
+
+// setGenericCommand when为过期时间的毫秒时间戳，为0表示不设置过期时间。
+// okReply和abortReply分别是写入成功以及因为NX/XX没有写入时的回复，为空时使用SET命令的默认回复
+func setGenericCommand(c *GodisClient, key, val *Gobj, flags int, when int64, okReply, abortReply string) {
+	old := findKeyWrite(c.db, key)
+	var oldStr string
+	if flags&ObjSetGet != 0 && old != nil {
+		if checkType(c, old, GSTR) {
+			return
+		}
+		// 写入新值后旧值可能会被释放，先保存下来
+		oldStr = old.StrVal()
+	}
+
+	if (flags&ObjSetNX != 0 && old != nil) || (flags&ObjSetXX != 0 && old == nil) {
+		if abortReply != "" {
+			c.AddReplyStr(abortReply)
+		} else if flags&ObjSetGet != 0 && old != nil {
+			c.AddReplyBulkStr(oldStr)
+		} else {
+			c.AddReplyStr(ReplyNull)
+		}
+		return
+	}
+
+	setKey(c.db, key, val)
+	if when > 0 {
+		setExpire(c.db, key, when)
+	} else if flags&ObjSetKeepTTL == 0 {
+		removeExpire(c.db, key)
+	}
+
+	if okReply != "" {
+		c.AddReplyStr(okReply)
+	} else if flags&ObjSetGet == 0 {
+		c.AddReplyStr(ReplyOK)
+	} else if old == nil {
+		c.AddReplyStr(ReplyNull)
+	} else {
+		c.AddReplyBulkStr(oldStr)
+	}
+}
+
+// 将EX/PX/EXAT/PXAT的参数转换为毫秒时间戳，出错时回复错误并返回false
+func parseExpireTime(c *GodisClient, unit string, arg *Gobj) (int64, bool) {
+	val, ok := getIntFromObjectOrReply(c, arg)
+	if !ok {
+		return 0, false
+	}
+
+	invalid := val <= 0
+	if unit == "ex" || unit == "exat" {
+		invalid = invalid || val > math.MaxInt64/1000
+		val *= 1000
+	}
+	if unit == "ex" || unit == "px" {
+		invalid = invalid || val > math.MaxInt64-GetMsTime()
+		val += GetMsTime()
+	}
+	if invalid {
+		c.AddReplyError("invalid expire time in '" + strings.ToLower(c.args[0].StrVal()) + "' command")
+		return 0, false
+	}
+	return val, true
+}
+
+func setCommand(c *GodisClient) {
+	flags := 0
+	var when int64
+	hasExpire := false
+	for i := 3; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		if opt == "nx" && flags&ObjSetXX == 0 {
+			flags |= ObjSetNX
+		} else if opt == "xx" && flags&ObjSetNX == 0 {
+			flags |= ObjSetXX
+		} else if opt == "get" {
+			flags |= ObjSetGet
+		} else if opt == "keepttl" && !hasExpire {
+			flags |= ObjSetKeepTTL
+		} else if (opt == "ex" || opt == "px" || opt == "exat" || opt == "pxat") &&
+			!hasExpire && flags&ObjSetKeepTTL == 0 && i+1 < len(c.args) {
+			var ok bool
+			if when, ok = parseExpireTime(c, opt, c.args[i+1]); !ok {
+				return
+			}
+			hasExpire = true
+			i++
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	setGenericCommand(c, c.args[1], c.args[2], flags, when, "", "")
+}
+
+func setnxCommand(c *GodisClient) {
+	setGenericCommand(c, c.args[1], c.args[2], ObjSetNX, 0, ":1\r\n", ":0\r\n")
+}
+
+func setexGenericCommand(c *GodisClient, unit string) {
+	when, ok := parseExpireTime(c, unit, c.args[2])
+	if !ok {
+		return
+	}
+	setGenericCommand(c, c.args[1], c.args[3], 0, when, "", "")
+}
+
+func setexCommand(c *GodisClient) {
+	setexGenericCommand(c, "ex")
+}
+
+func psetexCommand(c *GodisClient) {
+	setexGenericCommand(c, "px")
+}
+
+func getsetCommand(c *GodisClient) {
+	setGenericCommand(c, c.args[1], c.args[2], ObjSetGet, 0, "", "")
+}
+
+// 查找字符串对象，key不存在时回复empty并返回nil
+func stringLookupRead(c *GodisClient, key *Gobj, empty string) *Gobj {
+	o := findKeyRead(c.db, key)
+	if o == nil {
+		c.AddReplyStr(empty)
+		return nil
+	}
+	if checkType(c, o, GSTR) {
+		return nil
+	}
+	return o
+}
+
+func getdelCommand(c *GodisClient) {
+	o := stringLookupRead(c, c.args[1], ReplyNull)
+	if o == nil {
+		return
+	}
+	// 先回复再删除，删除会释放o
+	c.AddReplyBulk(o)
+	deleteKey(c.db, c.args[1])
+}
+
+func getexCommand(c *GodisClient) {
+	var when int64
+	persist := false
+	for i := 2; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		if opt == "persist" && when == 0 && !persist {
+			persist = true
+		} else if (opt == "ex" || opt == "px" || opt == "exat" || opt == "pxat") &&
+			when == 0 && !persist && i+1 < len(c.args) {
+			var ok bool
+			if when, ok = parseExpireTime(c, opt, c.args[i+1]); !ok {
+				return
+			}
+			i++
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	o := stringLookupRead(c, c.args[1], ReplyNull)
+	if o == nil {
+		return
+	}
+	if when > 0 {
+		setExpire(c.db, c.args[1], when)
+	} else if persist {
+		removeExpire(c.db, c.args[1])
+	}
+	c.AddReplyBulk(o)
+}
+
+func strlenCommand(c *GodisClient) {
+	o := stringLookupRead(c, c.args[1], ":0\r\n")
+	if o == nil {
+		return
+	}
+	c.AddReplyInt(int64(len(o.StrVal())))
+}
+
+// 检查size+append是否超出限制，先做减法避免溢出
+func checkStringLength(c *GodisClient, size, append int64) bool {
+	if size > GodisMaxStringSize-append {
+		c.AddReplyError("string exceeds maximum allowed size (proto-max-bulk-len)")
+		return false
+	}
+	return true
+}
+
+// 修改字符串的值并保留过期时间，只被数据库持有的对象直接原地修改
+func updateStringValue(db *GodisDB, key, o *Gobj, val string) {
+	if o.refCount == 1 {
+		o.Val_ = val
+		return
+	}
+	newObj := CreateObject(GSTR, val)
+	setKey(db, key, newObj)
+	newObj.DecrRefCount()
+}
+
+func appendCommand(c *GodisClient) {
+	key := c.args[1]
+	o := findKeyWrite(c.db, key)
+	if o == nil {
+		setKey(c.db, key, c.args[2])
+		c.AddReplyInt(int64(len(c.args[2].StrVal())))
+		return
+	}
+	if checkType(c, o, GSTR) {
+		return
+	}
+
+	str, appendStr := o.StrVal(), c.args[2].StrVal()
+	if !checkStringLength(c, int64(len(str)), int64(len(appendStr))) {
+		return
+	}
+	val := str + appendStr
+	updateStringValue(c.db, key, o, val)
+	c.AddReplyInt(int64(len(val)))
+}
+
+func getrangeCommand(c *GodisClient) {
+	start, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	end, ok := getIntFromObjectOrReply(c, c.args[3])
+	if !ok {
+		return
+	}
+
+	o := stringLookupRead(c, c.args[1], "$0\r\n\r\n")
+	if o == nil {
+		return
+	}
+
+	str := o.StrVal()
+	length := int64(len(str))
+	if start < 0 && end < 0 && start > end {
+		c.AddReplyBulkStr("")
+		return
+	}
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end < 0 {
+		end = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end || length == 0 {
+		c.AddReplyBulkStr("")
+		return
+	}
+	c.AddReplyBulkStr(str[start : end+1])
+}
+
+func setrangeCommand(c *GodisClient) {
+	offset, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	if offset < 0 {
+		c.AddReplyError("offset is out of range")
+		return
+	}
+
+	key := c.args[1]
+	val := c.args[3].StrVal()
+	o := findKeyWrite(c.db, key)
+	if o != nil && checkType(c, o, GSTR) {
+		return
+	}
+
+	var str string
+	if o != nil {
+		str = o.StrVal()
+	}
+	// value为空时不修改，也不会创建key
+	if len(val) == 0 {
+		c.AddReplyInt(int64(len(str)))
+		return
+	}
+	if !checkStringLength(c, offset, int64(len(val))) {
+		return
+	}
+
+	buf := []byte(str)
+	if need := int(offset) + len(val); need > len(buf) {
+		// 超出原字符串长度的部分用0填充
+		buf = append(buf, make([]byte, need-len(buf))...)
+	}
+	copy(buf[offset:], val)
+
+	if o == nil {
+		newObj := CreateObject(GSTR, string(buf))
+		setKey(c.db, key, newObj)
+		newObj.DecrRefCount()
+	} else {
+		updateStringValue(c.db, key, o, string(buf))
+	}
+	c.AddReplyInt(int64(len(buf)))
+}
+
+func incrDecrCommand(c *GodisClient, incr int64) {
+	key := c.args[1]
+	o := findKeyWrite(c.db, key)
+	if o != nil && checkType(c, o, GSTR) {
+		return
+	}
+
+	var val int64
+	if o != nil {
+		var ok bool
+		if val, ok = getIntFromObjectOrReply(c, o); !ok {
+			return
+		}
+	}
+
+	if (incr > 0 && val > math.MaxInt64-incr) || (incr < 0 && val < math.MinInt64-incr) {
+		c.AddReplyError("increment or decrement would overflow")
+		return
+	}
+	val += incr
+
+	// 只被数据库持有的整数对象可以直接原地修改，过期时间保持不变
+	if o != nil && o.refCount == 1 && o.isIntEncoded() {
+		o.Val_ = val
+	} else {
+		newObj := CreateFromInt(val)
+		setKey(c.db, key, newObj)
+		newObj.DecrRefCount()
+	}
+	c.AddReplyInt(val)
+}
+
+func incrCommand(c *GodisClient) {
+	incrDecrCommand(c, 1)
+}
+
+func decrCommand(c *GodisClient) {
+	incrDecrCommand(c, -1)
+}
+
+func incrbyCommand(c *GodisClient) {
+	incr, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	incrDecrCommand(c, incr)
+}
+
+func decrbyCommand(c *GodisClient) {
+	decr, ok := getIntFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+	if decr == math.MinInt64 {
+		c.AddReplyError("decrement would overflow")
+		return
+	}
+	incrDecrCommand(c, -decr)
+}
+
+func incrbyfloatCommand(c *GodisClient) {
+	incr, ok := getFloatFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+
+	key := c.args[1]
+	o := findKeyWrite(c.db, key)
+	if o != nil && checkType(c, o, GSTR) {
+		return
+	}
+
+	var val float64
+	if o != nil {
+		if val, ok = getFloatFromObjectOrReply(c, o); !ok {
+			return
+		}
+	}
+
+	val += incr
+	if math.IsNaN(val) || math.IsInf(val, 0) {
+		c.AddReplyError("increment would produce NaN or Infinity")
+		return
+	}
+
+	// 浮点数结果以字符串的形式保存，不使用指数形式
+	newObj := CreateObject(GSTR, strconv.FormatFloat(val, 'f', -1, 64))
+	setKey(c.db, key, newObj)
+	c.AddReplyBulk(newObj)
+	newObj.DecrRefCount()
+}
+
+func mgetCommand(c *GodisClient) {
+	c.AddReplyArrayLen(len(c.args) - 1)
+	for _, key := range c.args[1:] {
+		o := findKeyRead(c.db, key)
+		// 不是字符串的key与不存在的key一样返回nil
+		if o == nil || o.Type_ != GSTR {
+			c.AddReplyStr(ReplyNull)
+		} else {
+			c.AddReplyBulk(o)
+		}
+	}
+}
+
+func msetGenericCommand(c *GodisClient, nx bool) {
+	if len(c.args)%2 == 0 {
+		c.AddReplyError("wrong number of arguments for '" + c.args[0].StrVal() + "' command")
+		return
+	}
+
+	// MSETNX只要有一个key存在就不做任何修改
+	if nx {
+		for i := 1; i < len(c.args); i += 2 {
+			if findKeyWrite(c.db, c.args[i]) != nil {
+				c.AddReplyInt(0)
+				return
+			}
+		}
+	}
+
+	for i := 1; i < len(c.args); i += 2 {
+		setKey(c.db, c.args[i], c.args[i+1])
+		removeExpire(c.db, c.args[i])
+	}
+
+	if nx {
+		c.AddReplyInt(1)
+	} else {
+		c.AddReplyStr(ReplyOK)
+	}
+}
+
+func msetCommand(c *GodisClient) {
+	msetGenericCommand(c, false)
+}
+
+func msetnxCommand(c *GodisClient) {
+	msetGenericCommand(c, true)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetOptions(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, "$-1\r\n", execCommand(c, "set", "k", "v1", "xx"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "set", "k", "v1", "nx", "px", "30000"))
+	assert.NotNil(t, c.db.expire.Find(CreateObject(GSTR, "k")))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "set", "k", "v2", "nx"))
+	assert.Equal(t, "$2\r\nv1\r\n", execCommand(c, "set", "k", "v2", "nx", "get"))
+	assert.Equal(t, "$2\r\nv1\r\n", execCommand(c, "set", "k", "v2", "xx", "get", "keepttl"))
+	assert.NotNil(t, c.db.expire.Find(CreateObject(GSTR, "k")))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "set", "k", "v3"))
+	assert.Nil(t, c.db.expire.Find(CreateObject(GSTR, "k")))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "set", "n", "v", "get"))
+
+	assert.Equal(t, "+OK\r\n", execCommand(c, "set", "k", "v", "pxat", "1"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "get", "k"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "set", "k", "v", "exat", "99999999999"))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "get", "k"))
+
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "set", "k", "v", "nx", "xx"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "set", "k", "v", "ex", "10", "keepttl"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "set", "k", "v", "ex"))
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "set", "k", "v", "ex", "a"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", execCommand(c, "set", "k", "v", "ex", "0"))
+	assert.Equal(t, "-ERR invalid expire time in 'set' command\r\n", execCommand(c, "SET", "k", "v", "EX", "0"))
+
+	execCommand(c, "rpush", "l", "a")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "set", "l", "v", "get"))
+	assert.Equal(t, "+OK\r\n", execCommand(c, "set", "l", "v"))
+}
+
+func TestIncrDecr(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":1\r\n", execCommand(c, "incr", "n"))
+	assert.Equal(t, ":11\r\n", execCommand(c, "incrby", "n", "10"))
+	assert.Equal(t, ":10\r\n", execCommand(c, "decr", "n"))
+	assert.Equal(t, ":-5\r\n", execCommand(c, "decrby", "n", "15"))
+	assert.Equal(t, "$2\r\n-5\r\n", execCommand(c, "get", "n"))
+	assert.True(t, c.db.data.Get(CreateObject(GSTR, "n")).isIntEncoded())
+
+	execCommand(c, "set", "n", "100", "ex", "100")
+	assert.Equal(t, ":101\r\n", execCommand(c, "incr", "n"))
+	assert.NotNil(t, c.db.expire.Find(CreateObject(GSTR, "n")))
+
+	execCommand(c, "set", "max", "9223372036854775807")
+	assert.Equal(t, "-ERR increment or decrement would overflow\r\n", execCommand(c, "incr", "max"))
+	assert.Equal(t, "-ERR decrement would overflow\r\n", execCommand(c, "decrby", "n", "-9223372036854775808"))
+	execCommand(c, "set", "s", "abc")
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "incr", "s"))
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "incrby", "n", "1.5"))
+	execCommand(c, "set", "s", "007")
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "incr", "s"))
+	assert.Equal(t, ReplyNotInteger, execCommand(c, "incrby", "n", "+5"))
+	execCommand(c, "rpush", "l", "a")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "decr", "l"))
+}
+
+func TestIncrByFloat(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, "$4\r\n10.5\r\n", execCommand(c, "incrbyfloat", "f", "10.5"))
+	assert.Equal(t, "$4\r\n10.6\r\n", execCommand(c, "incrbyfloat", "f", "0.1"))
+	assert.Equal(t, "$4\r\n5000\r\n", execCommand(c, "incrbyfloat", "i", "5.0e3"))
+	assert.Equal(t, ":5001\r\n", execCommand(c, "incr", "i"))
+	assert.Equal(t, "-ERR increment would produce NaN or Infinity\r\n", execCommand(c, "incrbyfloat", "f", "inf"))
+	assert.Equal(t, ReplyNotFloat, execCommand(c, "incrbyfloat", "f", "x"))
+	execCommand(c, "set", "s", "abc")
+	assert.Equal(t, ReplyNotFloat, execCommand(c, "incrbyfloat", "s", "1"))
+}
+
+func TestStringCommands(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":5\r\n", execCommand(c, "append", "s", "hello"))
+	assert.Equal(t, ":11\r\n", execCommand(c, "append", "s", " world"))
+	assert.Equal(t, ":11\r\n", execCommand(c, "strlen", "s"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "strlen", "none"))
+	assert.Equal(t, "$5\r\nworld\r\n", execCommand(c, "getrange", "s", "-5", "-1"))
+	assert.Equal(t, "$5\r\nhello\r\n", execCommand(c, "getrange", "s", "0", "4"))
+	assert.Equal(t, "$0\r\n\r\n", execCommand(c, "getrange", "s", "5", "3"))
+	assert.Equal(t, "$11\r\nhello world\r\n", execCommand(c, "getrange", "s", "-100", "100"))
+
+	assert.Equal(t, ":11\r\n", execCommand(c, "setrange", "s", "6", "redis"))
+	assert.Equal(t, "$11\r\nhello redis\r\n", execCommand(c, "get", "s"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "setrange", "pad", "2", "x"))
+	assert.Equal(t, "$3\r\n\x00\x00x\r\n", execCommand(c, "get", "pad"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "setrange", "empty", "2", ""))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "get", "empty"))
+	assert.Equal(t, "-ERR offset is out of range\r\n", execCommand(c, "setrange", "s", "-1", "x"))
+	assert.Equal(t, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n", execCommand(c, "setrange", "s", "536870912", "x"))
+	assert.Equal(t, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n", execCommand(c, "setrange", "s", "9223372036854775807", "a"))
+	assert.Equal(t, "-ERR string exceeds maximum allowed size (proto-max-bulk-len)\r\n", execCommand(c, "setrange", "s", "9223372036854775800", "abcdefghijkl"))
+
+	execCommand(c, "set", "n", "1")
+	assert.Equal(t, ":2\r\n", execCommand(c, "append", "n", "0"))
+	assert.Equal(t, ":11\r\n", execCommand(c, "incr", "n"))
+}
+
+func TestGetSetFamily(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":1\r\n", execCommand(c, "setnx", "k", "v1"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "setnx", "k", "v2"))
+	assert.Equal(t, "$2\r\nv1\r\n", execCommand(c, "getset", "k", "v2"))
+	assert.Equal(t, "$2\r\nv2\r\n", execCommand(c, "getdel", "k"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "getdel", "k"))
+
+	key := CreateObject(GSTR, "k")
+	assert.Equal(t, "+OK\r\n", execCommand(c, "setex", "k", "100", "v"))
+	assert.True(t, c.db.expire.Get(key) > GetMsTime()+90000)
+	assert.Equal(t, "+OK\r\n", execCommand(c, "psetex", "k", "100", "v"))
+	assert.True(t, c.db.expire.Get(key) <= GetMsTime()+100)
+	assert.Equal(t, "-ERR invalid expire time in 'setex' command\r\n", execCommand(c, "setex", "k", "-1", "v"))
+
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "getex", "k", "persist"))
+	assert.Nil(t, c.db.expire.Find(key))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "getex", "k", "ex", "100"))
+	assert.NotNil(t, c.db.expire.Find(key))
+	assert.Equal(t, "$1\r\nv\r\n", execCommand(c, "getex", "k"))
+	assert.NotNil(t, c.db.expire.Find(key))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "getex", "k", "ex", "100", "persist"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "getex", "none", "persist"))
+}
+
+func TestMultiKey(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "set", "k1", "old", "ex", "100")
+	assert.Equal(t, "+OK\r\n", execCommand(c, "mset", "k1", "v1", "k2", "v2"))
+	assert.Nil(t, c.db.expire.Find(CreateObject(GSTR, "k1")))
+	execCommand(c, "rpush", "l", "a")
+	assert.Equal(t, "*4\r\n$2\r\nv1\r\n$-1\r\n$2\r\nv2\r\n$-1\r\n", execCommand(c, "mget", "k1", "none", "k2", "l"))
+	assert.Equal(t, "-ERR wrong number of arguments for 'mset' command\r\n", execCommand(c, "mset", "k1", "v1", "k2"))
+
+	assert.Equal(t, ":0\r\n", execCommand(c, "msetnx", "k3", "v3", "k1", "v"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "get", "k3"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "msetnx", "k3", "v3", "k4", "v4"))
+	assert.Equal(t, "*2\r\n$2\r\nv3\r\n$2\r\nv4\r\n", execCommand(c, "mget", "k3", "k4"))
+}
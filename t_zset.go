@@ -0,0 +1,1243 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ZSet 元素较少时使用listpack编码，member和score相邻保存并按照(score, member)排序；
+// member超过zset-max-listpack-value或元素数量超过zset-max-listpack-entries时
+// 转换为dict+跳表，dict保存member到score的映射，跳表维护顺序
+type ZSet struct {
+	lp   *Listpack
+	dict *Dict[*Gobj, float64]
+	zsl  *SkipList
+}
+
+// member与跳表节点共享同一个Gobj
+var zsetDictType = DictType[*Gobj, float64]{
+	HashFunc:      GStrHash,
+	EqualFunc:     GStrEqual,
+	KeyDup:        gobjDup,
+	KeyDestructor: gobjRelease,
+}
+
+// 范围查询返回的元素
+type zsetItem struct {
+	member *Gobj
+	score  float64
+}
+
+// ZADD的输入标志
+const (
+	ZAddIncr = 1 << iota
+	ZAddNX
+	ZAddXX
+	ZAddGT
+	ZAddLT
+)
+
+// ZSet.Add的输出结果
+const (
+	ZAddNaN = 1 << iota
+	ZAddAdded
+	ZAddUpdated
+	ZAddNoop
+)
+
+type zrangeType int
+
+const (
+	ZRangeRank zrangeType = iota
+	ZRangeScore
+	ZRangeLex
+)
+
+func ZSetCreate() *ZSet {
+	return &ZSet{lp: ListpackCreate()}
+}
+
+// 转换为dict+跳表编码
+func (zs *ZSet) convert() {
+	items := zs.lpItems()
+	zs.lp = nil
+	zs.dict = DictCreate(zsetDictType)
+	zs.zsl = SkipListCreate()
+	for _, item := range items {
+		zs.zsl.Insert(item.score, item.member)
+		zs.dict.Add(item.member, item.score)
+		item.member.DecrRefCount()
+	}
+}
+
+// 按顺序读取listpack中的所有元素
+func (zs *ZSet) lpItems() []zsetItem {
+	items := make([]zsetItem, 0, zs.lp.Len()/2)
+	for p := zs.lp.First(); p != -1; p = zs.lp.Next(zs.lp.Next(p)) {
+		items = append(items, zsetItem{
+			member: createObjectFromListpack(zs.lp, p),
+			score:  zs.lpScore(zs.lp.Next(p)),
+		})
+	}
+	return items
+}
+
+func (zs *ZSet) lpScore(p int) float64 {
+	s, v, isInt := zs.lp.Get(p)
+	if isInt {
+		return float64(v)
+	}
+	score, _ := strconv.ParseFloat(s, 64)
+	return score
+}
+
+// 返回member在listpack中的偏移量，不存在时返回-1
+func (zs *ZSet) lpFind(member *Gobj) int {
+	return zs.lp.Find(zs.lp.First(), member.StrVal(), 1)
+}
+
+// 按照(score, member)的顺序插入listpack，调用方需要保证member不存在
+func (zs *ZSet) lpInsert(score float64, member *Gobj) {
+	m := member.StrVal()
+	for p := zs.lp.First(); p != -1; p = zs.lp.Next(zs.lp.Next(p)) {
+		cur := zs.lpScore(zs.lp.Next(p))
+		if cur > score || (cur == score && zs.lp.GetString(p) > m) {
+			p = zs.lp.Insert(p, m, false)
+			zs.lp.Insert(p, FormatFloat(score), true)
+			return
+		}
+	}
+	zs.lp.Append(m)
+	zs.lp.Append(FormatFloat(score))
+}
+
+func (zs *ZSet) Dup() *ZSet {
+	if zs.lp != nil {
+		return &ZSet{lp: zs.lp.Dup()}
+	}
+	dup := ZSetCreate()
+	dup.convert()
+	for n := zs.zsl.First(); n != nil; n = n.Next() {
+		dup.Add(n.Score, n.Member, 0)
+	}
+	return dup
+}
+
+func (zs *ZSet) Length() int64 {
+	if zs.lp != nil {
+		return int64(zs.lp.Len() / 2)
+	}
+	return zs.zsl.Length()
+}
+
+func (zs *ZSet) Score(member *Gobj) (float64, bool) {
+	if zs.lp != nil {
+		p := zs.lpFind(member)
+		if p == -1 {
+			return 0, false
+		}
+		return zs.lpScore(zs.lp.Next(p)), true
+	}
+	entry := zs.dict.Find(member)
+	if entry == nil {
+		return 0, false
+	}
+	return entry.Val, true
+}
+
+// Add 添加或更新member，返回最新的score以及结果标志
+func (zs *ZSet) Add(score float64, member *Gobj, flags int) (float64, int) {
+	if math.IsNaN(score) {
+		return 0, ZAddNaN
+	}
+
+	curScore, exists := zs.Score(member)
+	if exists {
+		if flags&ZAddNX != 0 {
+			return curScore, ZAddNoop
+		}
+		if flags&ZAddIncr != 0 {
+			score += curScore
+			if math.IsNaN(score) {
+				return 0, ZAddNaN
+			}
+		}
+		if (flags&ZAddLT != 0 && score >= curScore) || (flags&ZAddGT != 0 && score <= curScore) {
+			return curScore, ZAddNoop
+		}
+		if score == curScore {
+			return score, 0
+		}
+
+		if zs.lp != nil {
+			// 删除之后重新插入，保证顺序
+			zs.lp.DeleteRange(zs.lpFind(member), 2)
+			zs.lpInsert(score, member)
+		} else {
+			zs.zsl.UpdateScore(curScore, member, score)
+			zs.dict.Set(member, score)
+		}
+		return score, ZAddUpdated
+	}
+
+	if flags&ZAddXX != 0 {
+		return 0, ZAddNoop
+	}
+	if zs.lp != nil {
+		if zs.Length()+1 <= int64(server.zsetMaxListpackEntries) &&
+			len(member.StrVal()) <= server.zsetMaxListpackValue {
+			zs.lpInsert(score, member)
+			return score, ZAddAdded
+		}
+		zs.convert()
+	}
+	zs.zsl.Insert(score, member)
+	zs.dict.Add(member, score)
+	return score, ZAddAdded
+}
+
+func (zs *ZSet) Delete(member *Gobj) bool {
+	if zs.lp != nil {
+		p := zs.lpFind(member)
+		if p == -1 {
+			return false
+		}
+		zs.lp.DeleteRange(p, 2)
+		return true
+	}
+	score, exists := zs.Score(member)
+	if !exists {
+		return false
+	}
+	zs.zsl.Delete(score, member)
+	zs.dict.Delete(member)
+	return true
+}
+
+// Rank 获取member的排名，从0开始
+func (zs *ZSet) Rank(member *Gobj, reverse bool) (int64, bool) {
+	var rank int64
+	if zs.lp != nil {
+		p := zs.lpFind(member)
+		if p == -1 {
+			return 0, false
+		}
+		for q := zs.lp.First(); q != p; q = zs.lp.Next(zs.lp.Next(q)) {
+			rank++
+		}
+		rank++
+	} else {
+		score, exists := zs.Score(member)
+		if !exists {
+			return 0, false
+		}
+		rank = zs.zsl.GetRank(score, member)
+	}
+	if reverse {
+		return zs.Length() - rank, true
+	}
+	return rank - 1, true
+}
+
+// ForEach 按照score从小到大遍历，回调中不能修改zset。member只在回调中有效，需要保留时增加引用计数
+func (zs *ZSet) ForEach(fn func(member *Gobj, score float64)) {
+	if zs.lp != nil {
+		for _, item := range zs.lpItems() {
+			fn(item.member, item.score)
+			item.member.DecrRefCount()
+		}
+		return
+	}
+	for n := zs.zsl.First(); n != nil; n = n.Next() {
+		fn(n.Member, n.Score)
+	}
+}
+
+// RangeByRank 获取排名在[start, end]内的元素，reverse为true时按照score从大到小排名
+func (zs *ZSet) RangeByRank(start, end int64, reverse bool) []zsetItem {
+	start, end, ok := normalizeRange(start, end, zs.Length())
+	if !ok {
+		return nil
+	}
+
+	items := make([]zsetItem, 0, end-start+1)
+	if zs.lp != nil {
+		all := zs.lpItems()
+		for i := start; i <= end; i++ {
+			if reverse {
+				items = append(items, all[int64(len(all))-1-i])
+			} else {
+				items = append(items, all[i])
+			}
+		}
+		return items
+	}
+
+	var n *SkipListNode
+	if reverse {
+		n = zs.zsl.GetByRank(zs.Length() - start)
+	} else {
+		n = zs.zsl.GetByRank(start + 1)
+	}
+	for i := start; i <= end; i++ {
+		items = append(items, zsetItem{n.Member, n.Score})
+		if reverse {
+			n = n.Prev()
+		} else {
+			n = n.Next()
+		}
+	}
+	return items
+}
+
+// 在范围内的元素中跳过offset个后最多返回count个，count<0表示不限制。
+// first为范围内的第一个节点，inRange判断元素是否仍然在范围内
+func zsetCollectRange(zs *ZSet, first *SkipListNode, inRange func(item zsetItem) bool,
+	reverse bool, offset, count int64) []zsetItem {
+	var items []zsetItem
+	if offset < 0 {
+		return items
+	}
+
+	if zs.lp != nil {
+		all := zs.lpItems()
+		for i := range all {
+			item := all[i]
+			if reverse {
+				item = all[len(all)-1-i]
+			}
+			if !inRange(item) {
+				continue
+			}
+			if offset > 0 {
+				offset--
+				continue
+			}
+			if count == 0 {
+				break
+			}
+			items = append(items, item)
+			count--
+		}
+		return items
+	}
+
+	n := first
+	for n != nil && offset > 0 {
+		offset--
+		if reverse {
+			n = n.Prev()
+		} else {
+			n = n.Next()
+		}
+	}
+	for n != nil && count != 0 && inRange(zsetItem{n.Member, n.Score}) {
+		items = append(items, zsetItem{n.Member, n.Score})
+		count--
+		if reverse {
+			n = n.Prev()
+		} else {
+			n = n.Next()
+		}
+	}
+	return items
+}
+
+// RangeByScore 获取score在范围内的元素
+func (zs *ZSet) RangeByScore(r *ZRangeSpec, reverse bool, offset, count int64) []zsetItem {
+	var first *SkipListNode
+	inRange := func(item zsetItem) bool { return r.gteMin(item.score) && r.lteMax(item.score) }
+	if zs.lp == nil {
+		if reverse {
+			first = zs.zsl.LastInRange(r)
+		} else {
+			first = zs.zsl.FirstInRange(r)
+		}
+	}
+	return zsetCollectRange(zs, first, inRange, reverse, offset, count)
+}
+
+// RangeByLex 获取member在字典序范围内的元素，所有元素的score应该相同
+func (zs *ZSet) RangeByLex(r *ZLexRangeSpec, reverse bool, offset, count int64) []zsetItem {
+	var first *SkipListNode
+	inRange := func(item zsetItem) bool {
+		m := item.member.StrVal()
+		return r.gteMin(m) && r.lteMax(m)
+	}
+	if zs.lp == nil {
+		if reverse {
+			first = zs.zsl.LastInLexRange(r)
+		} else {
+			first = zs.zsl.FirstInLexRange(r)
+		}
+	}
+	return zsetCollectRange(zs, first, inRange, reverse, offset, count)
+}
+
+// Count 返回score在范围内的元素数量
+func (zs *ZSet) Count(r *ZRangeSpec) int64 {
+	if zs.lp != nil {
+		return int64(len(zs.RangeByScore(r, false, 0, -1)))
+	}
+	// 通过首尾节点的排名计算范围内的节点数
+	first := zs.zsl.FirstInRange(r)
+	if first == nil {
+		return 0
+	}
+	last := zs.zsl.LastInRange(r)
+	return zs.zsl.GetRank(last.Score, last.Member) - zs.zsl.GetRank(first.Score, first.Member) + 1
+}
+
+// DeleteRangeByRank 删除排名在[start, end]内的元素，start和end从0开始并且已经合法
+func (zs *ZSet) DeleteRangeByRank(start, end int64) int64 {
+	if zs.lp != nil {
+		zs.lp.DeleteRange(zs.lp.Seek(int(start*2)), int(end-start+1)*2)
+		return end - start + 1
+	}
+	return zs.zsl.DeleteRangeByRank(start+1, end+1, zs.deleteFromDict)
+}
+
+// DeleteRangeByScore 删除score在范围内的元素
+func (zs *ZSet) DeleteRangeByScore(r *ZRangeSpec) int64 {
+	if zs.lp != nil {
+		var removed int64
+		for p := zs.lp.First(); p != -1; {
+			if score := zs.lpScore(zs.lp.Next(p)); r.gteMin(score) && r.lteMax(score) {
+				p = zs.lp.DeleteRange(p, 2)
+				removed++
+			} else {
+				p = zs.lp.Next(zs.lp.Next(p))
+			}
+		}
+		return removed
+	}
+	return zs.zsl.DeleteRangeByScore(r, zs.deleteFromDict)
+}
+
+// 从跳表删除节点后同步删除dict中的member
+func (zs *ZSet) deleteFromDict(n *SkipListNode) {
+	zs.dict.Delete(n.Member)
+}
+
+// PopOne 弹出score最小或最大的成员，调用方需要释放返回的member
+func (zs *ZSet) PopOne(max bool) (*Gobj, float64) {
+	var member *Gobj
+	var score float64
+	if zs.lp != nil {
+		p := zs.lp.First()
+		if max {
+			p = zs.lp.Prev(zs.lp.Last())
+		}
+		member, score = createObjectFromListpack(zs.lp, p), zs.lpScore(zs.lp.Next(p))
+		zs.lp.DeleteRange(p, 2)
+		return member, score
+	}
+
+	n := zs.zsl.First()
+	if max {
+		n = zs.zsl.Last()
+	}
+	member, score = n.Member, n.Score
+	member.IncrRefCount()
+	zs.Delete(member)
+	return member, score
+}
+
+func parseScoreRangeItem(s string) (float64, bool, bool) {
+	ex := false
+	if strings.HasPrefix(s, "(") {
+		ex = true
+		s = s[1:]
+	}
+	val, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsNaN(val) {
+		return 0, false, false
+	}
+	return val, ex, true
+}
+
+func parseScoreRange(min, max *Gobj) (*ZRangeSpec, bool) {
+	var r ZRangeSpec
+	var ok bool
+	if r.Min, r.MinEx, ok = parseScoreRangeItem(min.StrVal()); !ok {
+		return nil, false
+	}
+	if r.Max, r.MaxEx, ok = parseScoreRangeItem(max.StrVal()); !ok {
+		return nil, false
+	}
+	return &r, true
+}
+
+func parseLexRangeItem(s string) (ZLexBound, bool) {
+	switch {
+	case s == "-":
+		return ZLexBound{Inf: -1}, true
+	case s == "+":
+		return ZLexBound{Inf: 1}, true
+	case strings.HasPrefix(s, "["):
+		return ZLexBound{Val: s[1:]}, true
+	case strings.HasPrefix(s, "("):
+		return ZLexBound{Val: s[1:], Ex: true}, true
+	}
+	return ZLexBound{}, false
+}
+
+func parseLexRange(min, max *Gobj) (*ZLexRangeSpec, bool) {
+	var r ZLexRangeSpec
+	var ok bool
+	if r.Min, ok = parseLexRangeItem(min.StrVal()); !ok {
+		return nil, false
+	}
+	if r.Max, ok = parseLexRangeItem(max.StrVal()); !ok {
+		return nil, false
+	}
+	return &r, true
+}
+
+// 查找zset对象，key不存在时回复empty并返回nil
+func zsetLookupRead(c *GodisClient, key *Gobj, empty string) *ZSet {
+	zobj := findKeyRead(c.db, key)
+	if zobj == nil {
+		c.AddReplyStr(empty)
+		return nil
+	}
+	if checkType(c, zobj, GZSet) {
+		return nil
+	}
+	return zobj.Val_.(*ZSet)
+}
+
+func zaddCommand(c *GodisClient) {
+	idx := 2
+	flags := 0
+	ch := false
+	for ; idx < len(c.args); idx++ {
+		opt := strings.ToLower(c.args[idx].StrVal())
+		if opt == "nx" {
+			flags |= ZAddNX
+		} else if opt == "xx" {
+			flags |= ZAddXX
+		} else if opt == "gt" {
+			flags |= ZAddGT
+		} else if opt == "lt" {
+			flags |= ZAddLT
+		} else if opt == "ch" {
+			ch = true
+		} else if opt == "incr" {
+			flags |= ZAddIncr
+		} else {
+			break
+		}
+	}
+
+	elements := len(c.args) - idx
+	if elements == 0 || elements%2 != 0 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+	incr := flags&ZAddIncr != 0
+	if flags&ZAddNX != 0 && flags&ZAddXX != 0 {
+		c.AddReplyError("XX and NX options at the same time are not compatible")
+		return
+	}
+	if (flags&ZAddGT != 0 && flags&ZAddNX != 0) || (flags&ZAddLT != 0 && flags&ZAddNX != 0) ||
+		(flags&ZAddGT != 0 && flags&ZAddLT != 0) {
+		c.AddReplyError("GT, LT, and/or NX options at the same time are not compatible")
+		return
+	}
+	if incr && elements > 2 {
+		c.AddReplyError("INCR option supports a single increment-element pair")
+		return
+	}
+
+	// 先解析所有score，保证出错时不会修改数据
+	scores := make([]float64, elements/2)
+	for i := range scores {
+		var ok bool
+		if scores[i], ok = getFloatFromObjectOrReply(c, c.args[idx+i*2]); !ok {
+			return
+		}
+	}
+
+	key := c.args[1]
+	zobj := findKeyWrite(c.db, key)
+	if zobj == nil {
+		if flags&ZAddXX != 0 {
+			if incr {
+				c.AddReplyStr(ReplyNull)
+			} else {
+				c.AddReplyInt(0)
+			}
+			return
+		}
+		zobj = CreateZSetObject()
+		setKey(c.db, key, zobj)
+		zobj.DecrRefCount()
+	} else if checkType(c, zobj, GZSet) {
+		return
+	}
+
+	zs := zobj.Val_.(*ZSet)
+	var added, updated int64
+	var score float64
+	var res int
+	for i := range scores {
+		score, res = zs.Add(scores[i], c.args[idx+i*2+1], flags)
+		if res&ZAddNaN != 0 {
+			c.AddReplyError("resulting score is not a number (NaN)")
+			return
+		}
+		if res&ZAddAdded != 0 {
+			added++
+		}
+		if res&ZAddUpdated != 0 {
+			updated++
+		}
+	}
+
+	if incr {
+		if res&ZAddNoop != 0 {
+			c.AddReplyStr(ReplyNull)
+		} else {
+			c.AddReplyBulkStr(FormatFloat(score))
+		}
+	} else if ch {
+		c.AddReplyInt(added + updated)
+	} else {
+		c.AddReplyInt(added)
+	}
+}
+
+func zincrbyCommand(c *GodisClient) {
+	incr, ok := getFloatFromObjectOrReply(c, c.args[2])
+	if !ok {
+		return
+	}
+
+	key := c.args[1]
+	zobj := findKeyWrite(c.db, key)
+	if zobj == nil {
+		zobj = CreateZSetObject()
+		setKey(c.db, key, zobj)
+		zobj.DecrRefCount()
+	} else if checkType(c, zobj, GZSet) {
+		return
+	}
+
+	score, res := zobj.Val_.(*ZSet).Add(incr, c.args[3], ZAddIncr)
+	if res&ZAddNaN != 0 {
+		c.AddReplyError("resulting score is not a number (NaN)")
+		return
+	}
+	c.AddReplyBulkStr(FormatFloat(score))
+}
+
+func zremCommand(c *GodisClient) {
+	key := c.args[1]
+	zobj := findKeyWrite(c.db, key)
+	if zobj == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if checkType(c, zobj, GZSet) {
+		return
+	}
+
+	zs := zobj.Val_.(*ZSet)
+	var deleted int64
+	for _, member := range c.args[2:] {
+		if zs.Delete(member) {
+			deleted++
+		}
+	}
+
+	if zs.Length() == 0 {
+		deleteKey(c.db, key)
+	}
+	c.AddReplyInt(deleted)
+}
+
+func zpopGenericCommand(c *GodisClient, max bool) {
+	if len(c.args) > 3 {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+	var count int64 = 1
+	if len(c.args) == 3 {
+		var ok bool
+		if count, ok = getIntFromObjectOrReply(c, c.args[2]); !ok {
+			return
+		}
+		if count < 0 {
+			c.AddReplyError("value is out of range, must be positive")
+			return
+		}
+	}
+
+	key := c.args[1]
+	zobj := findKeyWrite(c.db, key)
+	if zobj == nil {
+		c.AddReplyStr(ReplyEmptyArray)
+		return
+	}
+	if checkType(c, zobj, GZSet) {
+		return
+	}
+
+	zs := zobj.Val_.(*ZSet)
+	if count > zs.Length() {
+		count = zs.Length()
+	}
+	c.AddReplyArrayLen(int(count * 2))
+	for i := int64(0); i < count; i++ {
+		member, score := zs.PopOne(max)
+		c.AddReplyBulk(member)
+		c.AddReplyBulkStr(FormatFloat(score))
+		member.DecrRefCount()
+	}
+	if zs.Length() == 0 {
+		deleteKey(c.db, key)
+	}
+}
+
+func zpopminCommand(c *GodisClient) {
+	zpopGenericCommand(c, false)
+}
+
+func zpopmaxCommand(c *GodisClient) {
+	zpopGenericCommand(c, true)
+}
+
+// 阻塞版本的ZPOPMIN/ZPOPMAX，依次检查每个key，全部为空时阻塞等待
+func bzpopGenericCommand(c *GodisClient, max bool) {
+	timeout, ok := getTimeoutFromObjectOrReply(c, c.args[len(c.args)-1])
+	if !ok {
+		return
+	}
+
+	keys := c.args[1 : len(c.args)-1]
+	for _, key := range keys {
+		zobj := findKeyWrite(c.db, key)
+		if zobj == nil {
+			continue
+		}
+		if checkType(c, zobj, GZSet) {
+			return
+		}
+
+		zs := zobj.Val_.(*ZSet)
+		member, score := zs.PopOne(max)
+		c.AddReplyArrayLen(3)
+		c.AddReplyBulk(key)
+		c.AddReplyBulk(member)
+		c.AddReplyBulkStr(FormatFloat(score))
+		member.DecrRefCount()
+		if zs.Length() == 0 {
+			deleteKey(c.db, key)
+		}
+		return
+	}
+	blockForKeys(c, GZSet, keys, timeout)
+}
+
+func bzpopminCommand(c *GodisClient) {
+	bzpopGenericCommand(c, false)
+}
+
+func bzpopmaxCommand(c *GodisClient) {
+	bzpopGenericCommand(c, true)
+}
+
+func zcardCommand(c *GodisClient) {
+	zs := zsetLookupRead(c, c.args[1], ":0\r\n")
+	if zs == nil {
+		return
+	}
+	c.AddReplyInt(zs.Length())
+}
+
+func zscoreCommand(c *GodisClient) {
+	zs := zsetLookupRead(c, c.args[1], ReplyNull)
+	if zs == nil {
+		return
+	}
+
+	score, exists := zs.Score(c.args[2])
+	if !exists {
+		c.AddReplyStr(ReplyNull)
+		return
+	}
+	c.AddReplyBulkStr(FormatFloat(score))
+}
+
+func zrankGenericCommand(c *GodisClient, reverse bool) {
+	zs := zsetLookupRead(c, c.args[1], ReplyNull)
+	if zs == nil {
+		return
+	}
+
+	rank, exists := zs.Rank(c.args[2], reverse)
+	if !exists {
+		c.AddReplyStr(ReplyNull)
+		return
+	}
+	c.AddReplyInt(rank)
+}
+
+func zrankCommand(c *GodisClient) {
+	zrankGenericCommand(c, false)
+}
+
+func zrevrankCommand(c *GodisClient) {
+	zrankGenericCommand(c, true)
+}
+
+func zcountCommand(c *GodisClient) {
+	r, ok := parseScoreRange(c.args[2], c.args[3])
+	if !ok {
+		c.AddReplyError("min or max is not a float")
+		return
+	}
+
+	zs := zsetLookupRead(c, c.args[1], ":0\r\n")
+	if zs == nil {
+		return
+	}
+
+	c.AddReplyInt(zs.Count(r))
+}
+
+// zrangeGenericCommand 处理ZRANGE及其衍生命令，allowRangeOpts表示是否接受BYSCORE/BYLEX/REV选项
+func zrangeGenericCommand(c *GodisClient, rangeType zrangeType, reverse, allowRangeOpts bool) {
+	withScores := false
+	hasLimit := false
+	var offset, count int64 = 0, -1
+	for i := 4; i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		if opt == "withscores" {
+			withScores = true
+		} else if opt == "limit" && i+2 < len(c.args) {
+			var ok bool
+			if offset, ok = getIntFromObjectOrReply(c, c.args[i+1]); !ok {
+				return
+			}
+			if count, ok = getIntFromObjectOrReply(c, c.args[i+2]); !ok {
+				return
+			}
+			hasLimit = true
+			i += 2
+		} else if allowRangeOpts && opt == "byscore" {
+			rangeType = ZRangeScore
+		} else if allowRangeOpts && opt == "bylex" {
+			rangeType = ZRangeLex
+		} else if allowRangeOpts && opt == "rev" {
+			reverse = true
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	if hasLimit && rangeType == ZRangeRank {
+		c.AddReplyError("syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX")
+		return
+	}
+	if withScores && rangeType == ZRangeLex {
+		c.AddReplyError("syntax error, WITHSCORES not supported in combination with BYLEX")
+		return
+	}
+
+	// 逆序时参数的顺序为max min
+	minArg, maxArg := c.args[2], c.args[3]
+	if reverse && rangeType != ZRangeRank {
+		minArg, maxArg = maxArg, minArg
+	}
+
+	var scoreRange *ZRangeSpec
+	var lexRange *ZLexRangeSpec
+	var start, end int64
+	var ok bool
+	switch rangeType {
+	case ZRangeRank:
+		if start, ok = getIntFromObjectOrReply(c, minArg); !ok {
+			return
+		}
+		if end, ok = getIntFromObjectOrReply(c, maxArg); !ok {
+			return
+		}
+	case ZRangeScore:
+		if scoreRange, ok = parseScoreRange(minArg, maxArg); !ok {
+			c.AddReplyError("min or max is not a float")
+			return
+		}
+	case ZRangeLex:
+		if lexRange, ok = parseLexRange(minArg, maxArg); !ok {
+			c.AddReplyError("min or max not valid string range item")
+			return
+		}
+	}
+
+	zs := zsetLookupRead(c, c.args[1], ReplyEmptyArray)
+	if zs == nil {
+		return
+	}
+
+	var items []zsetItem
+	switch rangeType {
+	case ZRangeRank:
+		items = zs.RangeByRank(start, end, reverse)
+	case ZRangeScore:
+		items = zs.RangeByScore(scoreRange, reverse, offset, count)
+	case ZRangeLex:
+		items = zs.RangeByLex(lexRange, reverse, offset, count)
+	}
+
+	addReplyZSetItems(c, items, withScores)
+}
+
+func addReplyZSetItems(c *GodisClient, items []zsetItem, withScores bool) {
+	if withScores {
+		c.AddReplyArrayLen(len(items) * 2)
+	} else {
+		c.AddReplyArrayLen(len(items))
+	}
+	for _, item := range items {
+		c.AddReplyBulk(item.member)
+		if withScores {
+			c.AddReplyBulkStr(FormatFloat(item.score))
+		}
+	}
+}
+
+func zrangeCommand(c *GodisClient) {
+	zrangeGenericCommand(c, ZRangeRank, false, true)
+}
+
+func zrevrangeCommand(c *GodisClient) {
+	zrangeGenericCommand(c, ZRangeRank, true, false)
+}
+
+func zrangebyscoreCommand(c *GodisClient) {
+	zrangeGenericCommand(c, ZRangeScore, false, false)
+}
+
+func zrevrangebyscoreCommand(c *GodisClient) {
+	zrangeGenericCommand(c, ZRangeScore, true, false)
+}
+
+func zrangebylexCommand(c *GodisClient) {
+	zrangeGenericCommand(c, ZRangeLex, false, false)
+}
+
+func zrevrangebylexCommand(c *GodisClient) {
+	zrangeGenericCommand(c, ZRangeLex, true, false)
+}
+
+func zremrangeGenericCommand(c *GodisClient, rangeType zrangeType) {
+	var scoreRange *ZRangeSpec
+	var start, end int64
+	var ok bool
+	if rangeType == ZRangeRank {
+		if start, ok = getIntFromObjectOrReply(c, c.args[2]); !ok {
+			return
+		}
+		if end, ok = getIntFromObjectOrReply(c, c.args[3]); !ok {
+			return
+		}
+	} else if scoreRange, ok = parseScoreRange(c.args[2], c.args[3]); !ok {
+		c.AddReplyError("min or max is not a float")
+		return
+	}
+
+	key := c.args[1]
+	zobj := findKeyWrite(c.db, key)
+	if zobj == nil {
+		c.AddReplyInt(0)
+		return
+	}
+	if checkType(c, zobj, GZSet) {
+		return
+	}
+
+	zs := zobj.Val_.(*ZSet)
+	var removed int64
+	if rangeType == ZRangeRank {
+		if start, end, ok = normalizeRange(start, end, zs.Length()); ok {
+			removed = zs.DeleteRangeByRank(start, end)
+		}
+	} else {
+		removed = zs.DeleteRangeByScore(scoreRange)
+	}
+
+	if zs.Length() == 0 {
+		deleteKey(c.db, key)
+	}
+	c.AddReplyInt(removed)
+}
+
+func zremrangebyrankCommand(c *GodisClient) {
+	zremrangeGenericCommand(c, ZRangeRank)
+}
+
+func zremrangebyscoreCommand(c *GodisClient) {
+	zremrangeGenericCommand(c, ZRangeScore)
+}
+
+const (
+	ZAggSum = iota
+	ZAggMin
+	ZAggMax
+)
+
+// ZUNION等命令的输入，可以是zset，也可以是score为1的set
+type zsetSource struct {
+	set    *Gobj
+	zs     *ZSet
+	weight float64
+}
+
+func (src *zsetSource) size() int64 {
+	if src.set != nil {
+		return setTypeSize(src.set)
+	} else if src.zs != nil {
+		return src.zs.Length()
+	}
+	return 0
+}
+
+func (src *zsetSource) score(member *Gobj) (float64, bool) {
+	if src.set != nil {
+		return 1, setTypeIsMember(src.set, member)
+	} else if src.zs != nil {
+		return src.zs.Score(member)
+	}
+	return 0, false
+}
+
+func (src *zsetSource) forEach(fn func(member *Gobj, score float64)) {
+	if src.set != nil {
+		setTypeForEach(src.set, func(member *Gobj) {
+			fn(member, 1)
+		})
+	} else if src.zs != nil {
+		src.zs.ForEach(fn)
+	}
+}
+
+// 0*inf的结果为NaN，与redis一样视为0
+func zsetWeightedScore(score, weight float64) float64 {
+	val := score * weight
+	if math.IsNaN(val) {
+		return 0
+	}
+	return val
+}
+
+func zsetAggregate(agg int, target *float64, val float64) {
+	switch agg {
+	case ZAggSum:
+		*target += val
+		// inf + -inf的结果为NaN
+		if math.IsNaN(*target) {
+			*target = 0
+		}
+	case ZAggMin:
+		if val < *target {
+			*target = val
+		}
+	case ZAggMax:
+		if val > *target {
+			*target = val
+		}
+	}
+}
+
+type zsetAccum struct {
+	member *Gobj
+	score  float64
+}
+
+func zsetUnionInterDiff(srcs []*zsetSource, op, agg int) *ZSet {
+	accums := make(map[string]*zsetAccum)
+	var order []string
+	addAccum := func(member *Gobj, score float64) {
+		if acc, ok := accums[member.StrVal()]; ok {
+			zsetAggregate(agg, &acc.score, score)
+			return
+		}
+		member.IncrRefCount()
+		accums[member.StrVal()] = &zsetAccum{member: member, score: score}
+		order = append(order, member.StrVal())
+	}
+
+	switch op {
+	case SetOpUnion:
+		for _, src := range srcs {
+			src.forEach(func(member *Gobj, score float64) {
+				addAccum(member, zsetWeightedScore(score, src.weight))
+			})
+		}
+	case SetOpInter:
+		// 从最小的输入开始遍历，减少查找次数
+		smallest := 0
+		for i, src := range srcs {
+			if src.size() < srcs[smallest].size() {
+				smallest = i
+			}
+		}
+		srcs[smallest].forEach(func(member *Gobj, score float64) {
+			val := zsetWeightedScore(score, srcs[smallest].weight)
+			for i, other := range srcs {
+				if i == smallest {
+					continue
+				}
+				otherScore, ok := other.score(member)
+				if !ok {
+					return
+				}
+				zsetAggregate(agg, &val, zsetWeightedScore(otherScore, other.weight))
+			}
+			addAccum(member, val)
+		})
+	case SetOpDiff:
+		srcs[0].forEach(func(member *Gobj, score float64) {
+			for _, other := range srcs[1:] {
+				if _, ok := other.score(member); ok {
+					return
+				}
+			}
+			addAccum(member, score)
+		})
+	}
+
+	zs := ZSetCreate()
+	for _, k := range order {
+		zs.Add(accums[k].score, accums[k].member, 0)
+		accums[k].member.DecrRefCount()
+	}
+	return zs
+}
+
+// zunionInterDiffGenericCommand dst为nil时直接回复结果，否则将结果写入dst
+func zunionInterDiffGenericCommand(c *GodisClient, dst *Gobj, numKeysIdx, op int) {
+	numKeys, ok := getIntFromObjectOrReply(c, c.args[numKeysIdx])
+	if !ok {
+		return
+	}
+	if numKeys < 1 {
+		c.AddReplyError(fmt.Sprintf("at least 1 input key is needed for '%v' command", c.args[0].StrVal()))
+		return
+	}
+	if numKeys > int64(len(c.args)-numKeysIdx-1) {
+		c.AddReplyStr(ReplySyntaxErr)
+		return
+	}
+
+	keys := c.args[numKeysIdx+1 : numKeysIdx+1+int(numKeys)]
+	srcs := make([]*zsetSource, len(keys))
+	for i := range srcs {
+		srcs[i] = &zsetSource{weight: 1}
+	}
+
+	agg := ZAggSum
+	withScores := false
+	for i := numKeysIdx + 1 + int(numKeys); i < len(c.args); i++ {
+		opt := strings.ToLower(c.args[i].StrVal())
+		remaining := len(c.args) - i - 1
+		if op != SetOpDiff && opt == "weights" && remaining >= len(keys) {
+			for j := range srcs {
+				weight, err := strconv.ParseFloat(c.args[i+1+j].StrVal(), 64)
+				if err != nil || math.IsNaN(weight) {
+					c.AddReplyError("weight value is not a float")
+					return
+				}
+				srcs[j].weight = weight
+			}
+			i += len(keys)
+		} else if op != SetOpDiff && opt == "aggregate" && remaining >= 1 {
+			switch strings.ToLower(c.args[i+1].StrVal()) {
+			case "sum":
+				agg = ZAggSum
+			case "min":
+				agg = ZAggMin
+			case "max":
+				agg = ZAggMax
+			default:
+				c.AddReplyStr(ReplySyntaxErr)
+				return
+			}
+			i++
+		} else if dst == nil && opt == "withscores" {
+			withScores = true
+		} else {
+			c.AddReplyStr(ReplySyntaxErr)
+			return
+		}
+	}
+
+	for i, key := range keys {
+		obj := findKeyRead(c.db, key)
+		if obj == nil {
+			continue
+		}
+		if obj.Type_ == GSet {
+			srcs[i].set = obj
+		} else if checkType(c, obj, GZSet) {
+			return
+		} else {
+			srcs[i].zs = obj.Val_.(*ZSet)
+		}
+	}
+
+	zs := zsetUnionInterDiff(srcs, op, agg)
+	if dst == nil {
+		addReplyZSetItems(c, zs.RangeByRank(0, -1, false), withScores)
+		return
+	}
+
+	deleteKey(c.db, dst)
+	if zs.Length() > 0 {
+		zobj := CreateObject(GZSet, zs)
+		setKey(c.db, dst, zobj)
+		zobj.DecrRefCount()
+	}
+	c.AddReplyInt(zs.Length())
+}
+
+func zunionCommand(c *GodisClient) {
+	zunionInterDiffGenericCommand(c, nil, 1, SetOpUnion)
+}
+
+func zunionstoreCommand(c *GodisClient) {
+	zunionInterDiffGenericCommand(c, c.args[1], 2, SetOpUnion)
+}
+
+func zinterCommand(c *GodisClient) {
+	zunionInterDiffGenericCommand(c, nil, 1, SetOpInter)
+}
+
+func zinterstoreCommand(c *GodisClient) {
+	zunionInterDiffGenericCommand(c, c.args[1], 2, SetOpInter)
+}
+
+func zdiffCommand(c *GodisClient) {
+	zunionInterDiffGenericCommand(c, nil, 1, SetOpDiff)
+}
+
+func zdiffstoreCommand(c *GodisClient) {
+	zunionInterDiffGenericCommand(c, c.args[1], 2, SetOpDiff)
+}
+
+func zscanCommand(c *GodisClient) {
+	zobj := findKeyRead(c.db, c.args[1])
+	if zobj == nil {
+		c.AddReplyStr(ReplyEmptyScan)
+		return
+	}
+	if checkType(c, zobj, GZSet) {
+		return
+	}
+	scanGenericCommand(c, zobj, 2)
+}
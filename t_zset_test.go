@@ -0,0 +1,93 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestZAdd(t *testing.T) {
+	c := createTestClient(t)
+	assert.Equal(t, ":3\r\n", execCommand(c, "zadd", "z", "1", "a", "2", "b", "3", "c"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "zadd", "z", "nx", "10", "a"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "zadd", "z", "xx", "ch", "10", "a", "4", "d"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "zcard", "d"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "zcard", "z"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "zadd", "z", "gt", "incr", "-1", "a"))
+	assert.Equal(t, "$4\r\n10.5\r\n", execCommand(c, "zadd", "z", "incr", "0.5", "a"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "zadd", "z", "lt", "ch", "0", "a", "5", "b"))
+	assert.Equal(t, "$1\r\n0\r\n", execCommand(c, "zscore", "z", "a"))
+	assert.Equal(t, "$1\r\n5\r\n", execCommand(c, "zincrby", "z", "3", "b"))
+
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "zadd", "z", "nx", "1"))
+	assert.Equal(t, ReplyNotFloat, execCommand(c, "zadd", "z", "x", "a"))
+	assert.Equal(t, "-ERR XX and NX options at the same time are not compatible\r\n", execCommand(c, "zadd", "z", "nx", "xx", "1", "a"))
+	assert.Equal(t, "-ERR GT, LT, and/or NX options at the same time are not compatible\r\n", execCommand(c, "zadd", "z", "gt", "lt", "1", "a"))
+	assert.Equal(t, "-ERR INCR option supports a single increment-element pair\r\n", execCommand(c, "zadd", "z", "incr", "1", "a", "2", "b"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "zadd", "z", "inf", "x"))
+	assert.Equal(t, "-ERR resulting score is not a number (NaN)\r\n", execCommand(c, "zadd", "z", "incr", "-inf", "x"))
+
+	assert.Equal(t, ":0\r\n", execCommand(c, "zrank", "z", "a"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "zrevrank", "z", "b"))
+	assert.Equal(t, "$-1\r\n", execCommand(c, "zrank", "z", "none"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "zrem", "z", "a", "none", "x"))
+	assert.Equal(t, ":2\r\n", execCommand(c, "zrem", "z", "b", "c"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "zcard", "z"))
+
+	execCommand(c, "set", "s", "v")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "zadd", "s", "1", "a"))
+}
+
+func TestZRange(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "zadd", "z", "1", "a", "2", "b", "3", "c", "4", "d")
+	assert.Equal(t, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", execCommand(c, "zrange", "z", "1", "-2"))
+	assert.Equal(t, "*4\r\n$1\r\nd\r\n$1\r\n4\r\n$1\r\nc\r\n$1\r\n3\r\n", execCommand(c, "zrange", "z", "0", "1", "rev", "withscores"))
+	assert.Equal(t, "*2\r\n$1\r\nd\r\n$1\r\nc\r\n", execCommand(c, "zrevrange", "z", "0", "1"))
+	assert.Equal(t, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", execCommand(c, "zrange", "z", "(1", "3", "byscore"))
+	assert.Equal(t, "*1\r\n$1\r\nc\r\n", execCommand(c, "zrange", "z", "+inf", "-inf", "byscore", "rev", "limit", "1", "1"))
+	assert.Equal(t, "*2\r\n$1\r\nc\r\n$1\r\nd\r\n", execCommand(c, "zrangebyscore", "z", "3", "+inf"))
+	assert.Equal(t, "*2\r\n$1\r\nd\r\n$1\r\nc\r\n", execCommand(c, "zrevrangebyscore", "z", "+inf", "3"))
+	assert.Equal(t, ":3\r\n", execCommand(c, "zcount", "z", "(1", "4"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "zcount", "z", "5", "6"))
+	assert.Equal(t, "-ERR min or max is not a float\r\n", execCommand(c, "zcount", "z", "a", "6"))
+	assert.Equal(t, "-ERR syntax error, LIMIT is only supported in combination with either BYSCORE or BYLEX\r\n", execCommand(c, "zrange", "z", "0", "1", "limit", "0", "1"))
+
+	execCommand(c, "zadd", "lex", "0", "a", "0", "b", "0", "c", "0", "d")
+	assert.Equal(t, "*2\r\n$1\r\nb\r\n$1\r\nc\r\n", execCommand(c, "zrange", "lex", "(a", "[c", "bylex"))
+	assert.Equal(t, "*2\r\n$1\r\nd\r\n$1\r\nc\r\n", execCommand(c, "zrevrangebylex", "lex", "+", "(b"))
+	assert.Equal(t, "*1\r\n$1\r\nb\r\n", execCommand(c, "zrangebylex", "lex", "-", "+", "limit", "1", "1"))
+	assert.Equal(t, "-ERR min or max not valid string range item\r\n", execCommand(c, "zrangebylex", "lex", "a", "+"))
+
+	assert.Equal(t, ":2\r\n", execCommand(c, "zremrangebyrank", "z", "0", "1"))
+	assert.Equal(t, ":1\r\n", execCommand(c, "zremrangebyscore", "z", "(3", "inf"))
+	assert.Equal(t, "*1\r\n$1\r\nc\r\n", execCommand(c, "zrange", "z", "0", "-1"))
+}
+
+func TestZUnionInterDiff(t *testing.T) {
+	c := createTestClient(t)
+	execCommand(c, "zadd", "z1", "1", "a", "2", "b", "3", "c")
+	execCommand(c, "zadd", "z2", "10", "b", "20", "c", "30", "d")
+	execCommand(c, "sadd", "s", "c", "d")
+
+	assert.Equal(t, "*8\r\n$1\r\na\r\n$1\r\n1\r\n$1\r\nb\r\n$2\r\n12\r\n$1\r\nc\r\n$2\r\n23\r\n$1\r\nd\r\n$2\r\n30\r\n",
+		execCommand(c, "zunion", "2", "z1", "z2", "withscores"))
+	assert.Equal(t, "*4\r\n$1\r\nb\r\n$2\r\n14\r\n$1\r\nc\r\n$2\r\n26\r\n",
+		execCommand(c, "zinter", "2", "z1", "z2", "weights", "2", "1", "withscores"))
+	assert.Equal(t, "*4\r\n$1\r\nc\r\n$1\r\n1\r\n$1\r\nd\r\n$1\r\n1\r\n",
+		execCommand(c, "zinter", "2", "z2", "s", "aggregate", "min", "withscores"))
+	assert.Equal(t, "*1\r\n$1\r\na\r\n", execCommand(c, "zdiff", "3", "z1", "z2", "none"))
+
+	assert.Equal(t, ":2\r\n", execCommand(c, "zinterstore", "dst", "2", "z1", "z2", "aggregate", "max"))
+	assert.Equal(t, "*4\r\n$1\r\nb\r\n$2\r\n10\r\n$1\r\nc\r\n$2\r\n20\r\n", execCommand(c, "zrange", "dst", "0", "-1", "withscores"))
+	assert.Equal(t, ":4\r\n", execCommand(c, "zunionstore", "dst", "2", "s", "z1"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "zdiffstore", "dst", "2", "s", "z2"))
+	assert.Equal(t, ":0\r\n", execCommand(c, "zcard", "dst"))
+
+	assert.Equal(t, "-ERR at least 1 input key is needed for 'zunion' command\r\n", execCommand(c, "zunion", "0", "z1"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "zunion", "3", "z1", "z2"))
+	assert.Equal(t, ReplySyntaxErr, execCommand(c, "zdiff", "1", "z1", "weights", "1"))
+	assert.Equal(t, "-ERR weight value is not a float\r\n", execCommand(c, "zunion", "1", "z1", "weights", "x"))
+	execCommand(c, "set", "str", "v")
+	assert.Equal(t, ReplyWrongType, execCommand(c, "zunion", "2", "z1", "str"))
+}
@@ -0,0 +1,133 @@
+package main
+
+import "strconv"
+
+// 嵌套的'*'过多时直接认为不匹配，防止恶意的pattern耗尽资源
+const stringMatchMaxNesting = 1000
+
+func toLowerByte(b byte) byte {
+	if b >= 'A' && b <= 'Z' {
+		return b + ('a' - 'A')
+	}
+	return b
+}
+
+func equalByte(a, b byte, nocase bool) bool {
+	if nocase {
+		return toLowerByte(a) == toLowerByte(b)
+	}
+	return a == b
+}
+
+// stringMatch 判断str是否匹配glob风格的pattern，与redis的stringmatchlen兼容
+// 支持*、?、[abc]、[^a]、[a-z]以及使用\转义，nocase为true时忽略大小写
+func stringMatch(pattern, str string, nocase bool) bool {
+	skipLongerMatches := false
+	return stringMatchImpl(pattern, str, nocase, &skipLongerMatches, 0)
+}
+
+func stringMatchImpl(pattern, str string, nocase bool, skipLongerMatches *bool, nesting int) bool {
+	if nesting > stringMatchMaxNesting {
+		return false
+	}
+
+	p, s := 0, 0
+	for p < len(pattern) && s < len(str) {
+		switch pattern[p] {
+		case '*':
+			for p+1 < len(pattern) && pattern[p+1] == '*' {
+				p++
+			}
+			if p == len(pattern)-1 {
+				return true
+			}
+			for s < len(str) {
+				if stringMatchImpl(pattern[p+1:], str[s:], nocase, skipLongerMatches, nesting+1) {
+					return true
+				}
+				if *skipLongerMatches {
+					return false
+				}
+				s++
+			}
+			// 剩余的pattern从任何位置开始都无法匹配，前面的'*'匹配更长的内容也没有意义
+			*skipLongerMatches = true
+			return false
+		case '?':
+			s++
+		case '[':
+			p++
+			not := p < len(pattern) && pattern[p] == '^'
+			if not {
+				p++
+			}
+			match := false
+			for {
+				if p < len(pattern) && pattern[p] == '\\' && len(pattern)-p >= 2 {
+					p++
+					if pattern[p] == str[s] {
+						match = true
+					}
+				} else if p < len(pattern) && pattern[p] == ']' {
+					break
+				} else if p >= len(pattern) {
+					// 没有闭合的']'，把pattern的结尾当作']'
+					p--
+					break
+				} else if len(pattern)-p >= 3 && pattern[p+1] == '-' {
+					start, end, c := pattern[p], pattern[p+2], str[s]
+					if start > end {
+						start, end = end, start
+					}
+					if nocase {
+						start, end, c = toLowerByte(start), toLowerByte(end), toLowerByte(c)
+					}
+					p += 2
+					if c >= start && c <= end {
+						match = true
+					}
+				} else if equalByte(pattern[p], str[s], nocase) {
+					match = true
+				}
+				p++
+			}
+			if not {
+				match = !match
+			}
+			if !match {
+				return false
+			}
+			s++
+		case '\\':
+			if len(pattern)-p >= 2 {
+				p++
+			}
+			fallthrough
+		default:
+			if !equalByte(pattern[p], str[s], nocase) {
+				return false
+			}
+			s++
+		}
+		p++
+		if s == len(str) {
+			for p < len(pattern) && pattern[p] == '*' {
+				p++
+			}
+			break
+		}
+	}
+	return p == len(pattern) && s == len(str)
+}
+
+// stringToInt64 字符串能无损地转换为int64时返回true，例如"01"、"+1"、" 1"都不能转换
+func stringToInt64(s string) (int64, bool) {
+	if len(s) == 0 || len(s) > 20 {
+		return 0, false
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || strconv.FormatInt(v, 10) != s {
+		return 0, false
+	}
+	return v, true
+}
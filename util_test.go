@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringMatch(t *testing.T) {
+	cases := []struct {
+		pattern, str string
+		nocase       bool
+		match        bool
+	}{
+		{"*", "hello", false, true},
+		{"h?llo", "hello", false, true},
+		{"h?llo", "hllo", false, false},
+		{"h*llo", "heeeello", false, true},
+		{"h*llo", "hello world", false, false},
+		{"h[ae]llo", "hallo", false, true},
+		{"h[ae]llo", "hillo", false, false},
+		{"h[^e]llo", "hallo", false, true},
+		{"h[^e]llo", "hello", false, false},
+		{"h[a-b]llo", "hbllo", false, true},
+		{"h[b-a]llo", "hallo", false, true},
+		{"h[a-b]llo", "hcllo", false, false},
+		{"h\\*llo", "h*llo", false, true},
+		{"h\\*llo", "hello", false, false},
+		{"h[\\]]llo", "h]llo", false, true},
+		{"HELLO", "hello", false, false},
+		{"HELLO", "hello", true, true},
+		{"H[A-C]LLO", "hbllo", true, true},
+		{"a*", "a", false, true},
+		{"a**", "a", false, true},
+		{"*a*b", "xaxb", false, true},
+		{"[abc", "a", false, true},
+		{"[", "a", false, false},
+		{"abc", "ab", false, false},
+		{"ab", "abc", false, false},
+	}
+	for _, tc := range cases {
+		assert.Equal(t, tc.match, stringMatch(tc.pattern, tc.str, tc.nocase), tc.pattern+" "+tc.str)
+	}
+
+	// 大量的'*'不会导致指数级的回溯
+	pattern := strings.Repeat("a*", 50) + "b"
+	assert.False(t, stringMatch(pattern, strings.Repeat("a", 100), false))
+}